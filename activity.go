@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activityTracker counts the matched txs and the net value per monitored address to periodically report the most
+// active addresses of the last interval to the operators.
+type activityTracker struct {
+	interval time.Duration
+	// the number of addresses reported
+	top int
+
+	mu       sync.Mutex
+	activity map[string]*addrActivity
+	since    time.Time
+}
+
+// addrActivity is the activity of a monitored address within a report's interval.
+type addrActivity struct {
+	Address string `json:"address"`
+	Group   string `json:"group"`
+	Txs     int    `json:"txs"`
+	Value   int64  `json:"value"`
+}
+
+// topAddressesEvent is the generic webhook payload of a report of the most active monitored addresses.
+type topAddressesEvent struct {
+	Event     string          `json:"event"`
+	Since     time.Time       `json:"since"`
+	Addresses []*addrActivity `json:"addresses"`
+	// the number of active monitored addresses, including the ones not reported
+	Active int       `json:"active"`
+	Time   time.Time `json:"time"`
+}
+
+var topAddressesTemplate = `monitoring: the %d most active of %d active monitored address(es) since %s
+%s`
+
+func newActivityTracker(interval time.Duration, top int) *activityTracker {
+	return &activityTracker{interval: interval, top: top, activity: make(map[string]*addrActivity), since: time.Now()}
+}
+
+// observe records a matched tx of the given group on the given monitored address transferring the given value.
+func (t *activityTracker) observe(group string, addr string, value int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	activity, has := t.activity[addr]
+	if !has {
+		activity = &addrActivity{Address: addr, Group: group}
+		t.activity[addr] = activity
+	}
+	activity.Txs++
+	activity.Value += value
+}
+
+// report returns the report of the most active addresses since the last one and starts a new interval,
+// nil if no monitored address was active.
+func (t *activityTracker) report(now time.Time) *topAddressesEvent {
+	t.mu.Lock()
+	activity, since := t.activity, t.since
+	t.activity, t.since = make(map[string]*addrActivity), now
+	t.mu.Unlock()
+	if len(activity) == 0 {
+		return nil
+	}
+
+	addrs := make([]*addrActivity, 0, len(activity))
+	for _, a := range activity {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Txs != addrs[j].Txs {
+			return addrs[i].Txs > addrs[j].Txs
+		}
+		return addrs[i].Address < addrs[j].Address
+	})
+	if len(addrs) > t.top {
+		addrs = addrs[:t.top]
+	}
+	return &topAddressesEvent{Event: "top_addresses", Since: since, Addresses: addrs, Active: len(activity), Time: alertTime()}
+}
+
+// watch reports the most active addresses to the operators every interval, until the given context is done.
+func (t *activityTracker) watch(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		event := t.report(time.Now())
+		if event == nil {
+			infof("no monitored address was active within the last %v, skipping the top addresses report", t.interval)
+			continue
+		}
+		var lines strings.Builder
+		for _, a := range event.Addresses {
+			fmt.Fprintf(&lines, "- %s (group %s): %d tx(s), net value %d\n", explorerLink(*addrExplorerURI, *addrMirrorURI, a.Address), a.Group, a.Txs, a.Value)
+		}
+		infof("reporting the %d most active of %d active monitored address(es)", len(event.Addresses), event.Active)
+		notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(topAddressesTemplate, len(event.Addresses), event.Active, event.Since.Format(time.RFC3339), lines.String())), event)
+	}
+}
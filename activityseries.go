@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// activityResolution is the resolution of the activity kept by the activityStore, the buckets of /activity queries
+// must be multiples of it.
+const activityResolution = time.Minute
+
+// activityStore keeps the matched txs and the net value per monitored address and minute within the retention, for
+// dashboards to query the bucketed activity of the addresses via /activity.
+type activityStore struct {
+	retention time.Duration
+
+	mu sync.Mutex
+	// the activity by address and start of its minute (unix seconds)
+	activity map[string]map[int64]*activityCount
+	groups   map[string]string
+}
+
+type activityCount struct {
+	Txs   int   `json:"txs"`
+	Value int64 `json:"value"`
+}
+
+// activitySeries is the store of the activity served via /activity, nil unless an -activityRetention is set.
+var activitySeries *activityStore
+
+// activityBucket is the activity of an address within the bucket starting at its time.
+type activityBucket struct {
+	Time time.Time `json:"time"`
+	activityCount
+}
+
+// addrActivitySeries is the bucketed activity of a monitored address, listing only the buckets with activity.
+type addrActivitySeries struct {
+	Address string           `json:"address"`
+	Group   string           `json:"group"`
+	Txs     int              `json:"txs"`
+	Value   int64            `json:"value"`
+	Buckets []activityBucket `json:"buckets"`
+}
+
+type activityResponse struct {
+	Bucket    string                `json:"bucket"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Addresses []*addrActivitySeries `json:"addresses"`
+}
+
+func newActivityStore(retention time.Duration) *activityStore {
+	return &activityStore{retention: retention, activity: make(map[string]map[int64]*activityCount), groups: make(map[string]string)}
+}
+
+// observe records a matched tx of the given group on the given monitored address transferring the given value,
+// dropping the address's activity older than the retention.
+func (s *activityStore) observe(group string, addr string, value int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	minutes, has := s.activity[addr]
+	if !has {
+		minutes = make(map[int64]*activityCount)
+		s.activity[addr] = minutes
+	}
+	s.groups[addr] = group
+	minute := now.Truncate(activityResolution).Unix()
+	count, has := minutes[minute]
+	if !has {
+		count = &activityCount{}
+		minutes[minute] = count
+		s.prune(addr, now)
+	}
+	count.Txs++
+	count.Value += value
+}
+
+// prune drops the activity of the given address older than the retention, the address itself once it's inactive.
+func (s *activityStore) prune(addr string, now time.Time) {
+	oldest := now.Add(-s.retention).Unix()
+	for minute := range s.activity[addr] {
+		if minute < oldest {
+			delete(s.activity[addr], minute)
+		}
+	}
+	if len(s.activity[addr]) == 0 {
+		delete(s.activity, addr)
+		delete(s.groups, addr)
+	}
+}
+
+// series returns the activity of the monitored addresses (or only the given one) of the given window until now in
+// buckets of the given size aligned to the unix epoch, the most active addresses first.
+func (s *activityStore) series(bucket time.Duration, window time.Duration, addr string, now time.Time) *activityResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &activityResponse{Bucket: bucket.String(), From: now.Add(-window).Truncate(bucket), To: now, Addresses: []*addrActivitySeries{}}
+	for address := range s.activity {
+		if addr != "" && address != addr {
+			continue
+		}
+		s.prune(address, now)
+		series := &addrActivitySeries{Address: address, Group: s.groups[address]}
+		buckets := make(map[int64]*activityBucket)
+		for minute, count := range s.activity[address] {
+			t := time.Unix(minute, 0)
+			if t.Before(res.From) {
+				continue
+			}
+			start := t.Truncate(bucket)
+			b, has := buckets[start.Unix()]
+			if !has {
+				b = &activityBucket{Time: start}
+				buckets[start.Unix()] = b
+			}
+			b.Txs += count.Txs
+			b.Value += count.Value
+			series.Txs += count.Txs
+			series.Value += count.Value
+		}
+		if len(buckets) == 0 {
+			continue
+		}
+		for _, b := range buckets {
+			series.Buckets = append(series.Buckets, *b)
+		}
+		sort.Slice(series.Buckets, func(i, j int) bool {
+			return series.Buckets[i].Time.Before(series.Buckets[j].Time)
+		})
+		res.Addresses = append(res.Addresses, series)
+	}
+	sort.Slice(res.Addresses, func(i, j int) bool {
+		if res.Addresses[i].Txs != res.Addresses[j].Txs {
+			return res.Addresses[i].Txs > res.Addresses[j].Txs
+		}
+		return res.Addresses[i].Address < res.Addresses[j].Address
+	})
+	return res
+}
+
+// serveActivity responds with the bucketed activity of the monitored addresses as queried by the 'bucket' (1h by
+// default), 'window' (the retention by default, at most the retention) and optional 'address' parameters.
+func serveActivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	bucket, err := activityQueryDuration(query.Get("bucket"), time.Hour)
+	if err != nil || bucket%activityResolution != 0 {
+		http.Error(w, fmt.Sprintf("bucket must be a positive multiple of %v", activityResolution), http.StatusBadRequest)
+		return
+	}
+	window, err := activityQueryDuration(query.Get("window"), activitySeries.retention)
+	if err != nil || window > activitySeries.retention {
+		http.Error(w, fmt.Sprintf("window must be positive and at most the -activityRetention of %v", activitySeries.retention), http.StatusBadRequest)
+		return
+	}
+	var addr string
+	if query.Get("address") != "" {
+		if addr, err = normalizeAddr(query.Get("address")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(activitySeries.series(bucket, window, addr, time.Now())); err != nil {
+		errorf("could not write activity response: %s", err)
+	}
+}
+
+// activityQueryDuration parses the given positive duration of an /activity query, returning the given default if empty.
+func activityQueryDuration(str string, def time.Duration) (time.Duration, error) {
+	if str == "" {
+		return def, nil
+	}
+	dur, err := time.ParseDuration(str)
+	if err == nil && dur <= 0 {
+		err = fmt.Errorf("duration must be positive")
+	}
+	return dur, err
+}
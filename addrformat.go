@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/guards"
+)
+
+const (
+	addrFormatLegacy    = "legacy"
+	addrFormatChrysalis = "chrysalis"
+)
+
+// normalizeAddr validates the given configured address according to the address format and returns it as the
+// 81 tryte address it appears as in the ZMQ stream.
+//
+// Legacy addresses are given as 81 trytes or 90 trytes including their checksum. In the chrysalis format, bech32
+// encoded Ed25519 addresses (iota1... or atoi1...) are additionally accepted, which are monitored via their
+// migration address.
+func normalizeAddr(addr string) (string, error) {
+	addr, _ = cleanAddr(addr)
+	if *addrFormat == addrFormatChrysalis && (strings.HasPrefix(addr, "iota1") || strings.HasPrefix(addr, "atoi1")) {
+		ed25519Addr, err := decodeBech32Ed25519(addr)
+		if err != nil {
+			return "", fmt.Errorf("address '%s' is not a valid bech32 Ed25519 address: %w", addr, err)
+		}
+		return address.GenerateMigrationAddress(ed25519Addr)
+	}
+
+	switch len(addr) {
+	case 90:
+		if err := address.ValidAddress(addr); err != nil {
+			return "", fmt.Errorf("address '%s' has an invalid checksum", addr)
+		}
+		return addr[:81], nil
+	case 81:
+		if !guards.IsTrytes(addr) {
+			return "", fmt.Errorf("address '%s' is not trytes", addr)
+		}
+		return addr, nil
+	}
+	return "", fmt.Errorf("address '%s' is not 81 trytes (or 90 including the checksum)", addr)
+}
+
+// cleanAddr cleans up the given configured address, e.g. pasted from elsewhere, if enabled: characters which can't be
+// part of the address (e.g. whitespace, punctuation or invisible characters) are stripped and tryte addresses are
+// uppercased (bech32 addresses lowercased). Returns the cleaned address and a description of every applied cleanup.
+func cleanAddr(addr string) (string, []string) {
+	if !*cleanAddrs {
+		return addr, nil
+	}
+	lower := strings.ToLower(addr)
+	bech32 := *addrFormat == addrFormatChrysalis && (strings.Contains(lower, "iota1") || strings.Contains(lower, "atoi1"))
+	var cleaned, stripped strings.Builder
+	for _, r := range addr {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r == '9', bech32 && r >= '0' && r <= '9':
+			cleaned.WriteRune(r)
+		default:
+			stripped.WriteRune(r)
+		}
+	}
+	var applied []string
+	if stripped.Len() > 0 {
+		applied = append(applied, fmt.Sprintf("stripped the invalid character(s) %q", stripped.String()))
+	}
+	result := strings.ToUpper(cleaned.String())
+	if bech32 {
+		result = strings.ToLower(cleaned.String())
+	}
+	if result != cleaned.String() {
+		if bech32 {
+			applied = append(applied, "lowercased it")
+		} else {
+			applied = append(applied, "uppercased it")
+		}
+	}
+	return result, applied
+}
+
+// logAddrCleanups logs the cleanups applied to the given configured addresses from the given source.
+func logAddrCleanups(source string, addrs ...string) {
+	for _, addr := range addrs {
+		if cleaned, applied := cleanAddr(addr); len(applied) > 0 {
+			warnf("warning: cleaned up address %q from %s to %s: %s", addr, source, cleaned, strings.Join(applied, ", "))
+		}
+	}
+}
+
+// normalizeAddrs normalizes the given addresses, which must have been validated beforehand.
+func normalizeAddrs(addrs []string) []string {
+	normalized := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if n, err := normalizeAddr(addr); err == nil {
+			normalized = append(normalized, n)
+		}
+	}
+	return normalized
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// ed25519AddrType is the type byte of Ed25519 addresses in the bech32 encoded Chrysalis address format.
+const ed25519AddrType = 0
+
+// decodeBech32Ed25519 decodes the given bech32 encoded Chrysalis Ed25519 address.
+func decodeBech32Ed25519(addr string) ([32]byte, error) {
+	var ed25519Addr [32]byte
+	addr = strings.ToLower(addr)
+	sep := strings.LastIndexByte(addr, '1')
+	if sep < 1 || len(addr)-sep-1 < 6 {
+		return ed25519Addr, errors.New("missing separator or checksum")
+	}
+	hrp, dataPart := addr[:sep], addr[sep+1:]
+
+	data := make([]byte, len(dataPart))
+	for i := 0; i < len(dataPart); i++ {
+		v := strings.IndexByte(bech32Charset, dataPart[i])
+		if v < 0 {
+			return ed25519Addr, fmt.Errorf("invalid character '%c'", dataPart[i])
+		}
+		data[i] = byte(v)
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), data...)) != 1 {
+		return ed25519Addr, errors.New("invalid checksum")
+	}
+
+	// regroup the 5 bit groups (excluding the checksum) into bytes
+	var decoded []byte
+	var acc, bits uint
+	for _, v := range data[:len(data)-6] {
+		acc = acc<<5 | uint(v)
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			decoded = append(decoded, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 {
+		return ed25519Addr, errors.New("invalid padding")
+	}
+	if len(decoded) != 33 || decoded[0] != ed25519AddrType {
+		return ed25519Addr, errors.New("not an Ed25519 address")
+	}
+	copy(ed25519Addr[:], decoded[1:])
+	return ed25519Addr, nil
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// qrCodeURL returns the URL of the QR code of the given address (including its checksum) on the -qrCodeURI service.
+func qrCodeURL(addr string) string {
+	if checksum, err := address.Checksum(addr); err == nil {
+		addr = addr[:81] + checksum
+	}
+	return explorerURL(*qrCodeURI, addr)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// maxRecordedSpendBundles is the number of most recent bundles recorded per spent address to recognize the
+// reattachments of its spends.
+const maxRecordedSpendBundles = 10
+
+// spentAddrsStore keeps track of the bundles the monitored addresses were spent from in, persisted as a JSON object
+// by address so that it survives restarts. As the addresses of the legacy signature scheme reveal part of their
+// private key with every spend, an address spent from in a second bundle puts its remaining funds at risk.
+type spentAddrsStore struct {
+	path  string
+	spent map[string]*spentAddr
+	// whether the spends are only kept in memory, not written to the file
+	inMemory bool
+}
+
+type spentAddr struct {
+	// the number of distinct bundles the address was spent from in
+	Spends int `json:"spends"`
+	// the most recent of these bundles
+	Bundles []string `json:"bundles"`
+}
+
+// addrReuseEvent is the generic webhook payload of an address reuse alert.
+type addrReuseEvent struct {
+	Event   string `json:"event"`
+	Group   string `json:"group"`
+	Address string `json:"address"`
+	Tx      string `json:"tx"`
+	Bundle  string `json:"bundle"`
+	Value   int64  `json:"value"`
+	// the number of distinct bundles the address was spent from in, including this one
+	Spends int `json:"spends"`
+	// the most recent earlier bundles the address was spent from in
+	PreviousBundles []string  `json:"previousBundles"`
+	Time            time.Time `json:"time"`
+}
+
+var addrReuseTemplate = `monitoring: *ADDRESS REUSE* of monitored address %s
+- spent from for the %s time, in bundle %s (tx %s) spending %d
+- previously spent from in bundle(s) %s
+`
+
+// loadSpentAddrsStore loads the store from the given file, which doesn't need to exist yet. With inMemory (e.g. for
+// replays), the file is only read, the recorded spends are kept in memory.
+func loadSpentAddrsStore(path string, inMemory bool) (*spentAddrsStore, error) {
+	s := &spentAddrsStore{path: path, spent: make(map[string]*spentAddr), inMemory: inMemory}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unable to read spent addresses file: %w", err)
+	}
+	if err := json.Unmarshal(content, &s.spent); err != nil {
+		return nil, fmt.Errorf("unable to parse spent addresses file: %w", err)
+	}
+	return s, nil
+}
+
+// markSpent records the given tx spending from a monitored address, returning the address reuse event if the
+// address was already spent from in another bundle.
+func (s *spentAddrsStore) markSpent(tx *transaction.Transaction) *addrReuseEvent {
+	addr, has := s.spent[tx.Address]
+	if !has {
+		addr = &spentAddr{}
+		s.spent[tx.Address] = addr
+	}
+	for _, bundle := range addr.Bundles {
+		if bundle == tx.Bundle {
+			// a reattachment (or another input tx) of a known spend
+			return nil
+		}
+	}
+	var event *addrReuseEvent
+	if addr.Spends > 0 {
+		event = &addrReuseEvent{
+			Event: "address_reuse", Address: tx.Address, Tx: tx.Hash, Bundle: tx.Bundle, Value: tx.Value,
+			Spends: addr.Spends + 1, PreviousBundles: append([]string(nil), addr.Bundles...), Time: alertTime(),
+		}
+	}
+	addr.Spends++
+	addr.Bundles = append(addr.Bundles, tx.Bundle)
+	if len(addr.Bundles) > maxRecordedSpendBundles {
+		addr.Bundles = addr.Bundles[len(addr.Bundles)-maxRecordedSpendBundles:]
+	}
+	if s.inMemory {
+		return event
+	}
+	if err := s.persist(); err != nil {
+		errorf("could not persist spent addresses: %s", err)
+	}
+	return event
+}
+
+// persist writes the spent addresses to a temporary file which then replaces the store's file.
+func (s *spentAddrsStore) persist() error {
+	content, err := json.Marshal(s.spent)
+	if err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// ordinal returns the given number as an English ordinal, e.g. '2nd'.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func addrReuseSeverityInput(event *addrReuseEvent) *severityInput {
+	return &severityInput{
+		group: event.Group, direction: "out", value: -event.Value,
+		tx: event.Tx, bundle: event.Bundle, address: event.Address,
+	}
+}
+
+// notifyAddrReuse sends the address reuse alert to the group's spend notifier and other notification targets.
+func (g *watchGroup) notifyAddrReuse(event *addrReuseEvent) {
+	var notifications []notification
+	if g.spendNotifier != nil {
+		previous := make([]string, 0, len(event.PreviousBundles))
+		for _, bundle := range event.PreviousBundles {
+			previous = append(previous, explorerLink(*bundleExplorerURI, *bundleMirrorURI, bundle))
+		}
+		text := renderSlackText(event.Event, event, fmt.Sprintf(addrReuseTemplate, explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address),
+			ordinal(event.Spends), explorerLink(*bundleExplorerURI, *bundleMirrorURI, event.Bundle), explorerLink(*txExplorerURI, *txMirrorURI, event.Tx),
+			event.Value, strings.Join(previous, ", ")))
+		color := slackColor(addrReuseSeverityInput(event), true)
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.spendNotifier.notifyEvent(ctx, text, color, event)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("monitored address %s spent from for the %s time, in bundle %s (group %s)", event.Address, ordinal(event.Spends), event.Bundle, g.Name)
+			return sendPagerDutyEvent(ctx, text, addrReuseSeverityInput(event), event)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("monitored address %s spent from for the %s time, in bundle %s (group %s)", event.Address, ordinal(event.Spends), event.Bundle, g.Name)
+			return sendOpsgenieAlert(ctx, text, addrReuseSeverityInput(event), event)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}
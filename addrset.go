@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+const (
+	addrSetMap   = "map"
+	addrSetBloom = "bloom"
+)
+
+// addrLookup is a set of monitored addresses.
+type addrLookup interface {
+	has(addr string) bool
+	len() int
+}
+
+// newAddrLookup builds the set of the given addresses using the configured backend.
+func newAddrLookup(addrs []string) addrLookup {
+	if *addrSetBackend == addrSetBloom {
+		return newBloomAddrSet(addrs, *bloomFPRate)
+	}
+	return mapAddrSet(addrSet(addrs))
+}
+
+type mapAddrSet map[string]struct{}
+
+func (s mapAddrSet) has(addr string) bool {
+	_, has := s[addr]
+	return has
+}
+
+func (s mapAddrSet) len() int {
+	return len(s)
+}
+
+// packedAddrSize is the size of an 81 tryte address packed into 2 bytes per 3 trytes.
+const packedAddrSize = 54
+
+// bloomAddrSet is an address set for huge watch lists. A bloom filter answers the lookups of the vast majority of
+// (not monitored) addresses without touching the actual set. The actual set confirming positive answers of the filter
+// holds 81 tryte addresses as a sorted slice of packed addresses, which takes about a third of the memory of a map.
+type bloomAddrSet struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+	// sorted addresses packed by packAddr
+	packed []byte
+	// addresses which aren't 81 trytes
+	other map[string]struct{}
+}
+
+func newBloomAddrSet(addrs []string, fpRate float64) *bloomAddrSet {
+	n := float64(len(addrs))
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / n * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	s := &bloomAddrSet{bits: make([]uint64, (m+63)/64), m: m, k: k, other: make(map[string]struct{})}
+	var packed [][packedAddrSize]byte
+	for _, addr := range addrs {
+		h1, h2 := addrHashes(addr)
+		for i := uint64(0); i < s.k; i++ {
+			bit := (h1 + i*h2) % s.m
+			s.bits[bit/64] |= 1 << (bit % 64)
+		}
+		p, ok := packAddr(addr)
+		if !ok {
+			s.other[addr] = struct{}{}
+			continue
+		}
+		packed = append(packed, p)
+	}
+
+	sort.Slice(packed, func(i, j int) bool { return bytes.Compare(packed[i][:], packed[j][:]) < 0 })
+	s.packed = make([]byte, 0, len(packed)*packedAddrSize)
+	for i, p := range packed {
+		if i > 0 && p == packed[i-1] {
+			continue
+		}
+		s.packed = append(s.packed, p[:]...)
+	}
+	return s
+}
+
+func (s *bloomAddrSet) has(addr string) bool {
+	h1, h2 := addrHashes(addr)
+	for i := uint64(0); i < s.k; i++ {
+		bit := (h1 + i*h2) % s.m
+		if s.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	p, ok := packAddr(addr)
+	if !ok {
+		_, has := s.other[addr]
+		return has
+	}
+	n := len(s.packed) / packedAddrSize
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(s.packed[i*packedAddrSize:(i+1)*packedAddrSize], p[:]) >= 0
+	})
+	return i < n && bytes.Equal(s.packed[i*packedAddrSize:(i+1)*packedAddrSize], p[:])
+}
+
+func (s *bloomAddrSet) len() int {
+	return len(s.packed)/packedAddrSize + len(s.other)
+}
+
+// addrHashes computes the two hashes of the given address used for double hashing in the bloom filter.
+func addrHashes(addr string) (uint64, uint64) {
+	h := uint64(len(addr))
+	i := 0
+	for ; i+8 <= len(addr); i += 8 {
+		w := uint64(addr[i]) | uint64(addr[i+1])<<8 | uint64(addr[i+2])<<16 | uint64(addr[i+3])<<24 |
+			uint64(addr[i+4])<<32 | uint64(addr[i+5])<<40 | uint64(addr[i+6])<<48 | uint64(addr[i+7])<<56
+		h = (h ^ w) * 0x9fb21c651e98df25
+		h ^= h >> 29
+	}
+	for ; i < len(addr); i++ {
+		h = (h ^ uint64(addr[i])) * 0x9fb21c651e98df25
+	}
+	h1 := mix64(h)
+	// an odd step visits distinct bits for every i
+	return h1, mix64(h1^0x9e3779b97f4a7c15) | 1
+}
+
+// mix64 is the finalizer of MurmurHash3.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// packAddr packs the given 81 tryte address into 2 bytes per 3 trytes, returning false if it isn't 81 trytes.
+func packAddr(addr string) ([packedAddrSize]byte, bool) {
+	var p [packedAddrSize]byte
+	if len(addr) != 81 {
+		return p, false
+	}
+	for i := 0; i < 27; i++ {
+		var v uint16
+		for j := 0; j < 3; j++ {
+			c := addr[i*3+j]
+			var t uint16
+			switch {
+			case c == '9':
+				t = 0
+			case c >= 'A' && c <= 'Z':
+				t = uint16(c-'A') + 1
+			default:
+				return p, false
+			}
+			v = v*27 + t
+		}
+		p[i*2] = byte(v >> 8)
+		p[i*2+1] = byte(v)
+	}
+	return p, true
+}
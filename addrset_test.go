@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/address"
+)
+
+const benchAddrCount = 1000000
+
+func randomAddrs(n int, rnd *rand.Rand) []string {
+	const alphabet = "9ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	addrs := make([]string, n)
+	buf := make([]byte, 81)
+	for i := range addrs {
+		for j := range buf {
+			buf[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		addrs[i] = string(buf)
+	}
+	return addrs
+}
+
+func benchmarkLookup(b *testing.B, build func([]string) addrLookup) {
+	rnd := rand.New(rand.NewSource(1))
+	// the stream mostly carries addresses which aren't monitored
+	seen := randomAddrs(1000, rnd)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	monitored := randomAddrs(benchAddrCount, rnd)
+	for i := 0; i < len(seen); i += 100 {
+		seen[i] = monitored[i]
+	}
+	set := build(monitored)
+	monitored = nil
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.has(seen[i%len(seen)])
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/(1<<20), "set-MiB")
+	runtime.KeepAlive(set)
+}
+
+func BenchmarkMapAddrSetLookup(b *testing.B) {
+	benchmarkLookup(b, func(addrs []string) addrLookup { return mapAddrSet(addrSet(addrs)) })
+}
+
+func BenchmarkBloomAddrSetLookup(b *testing.B) {
+	benchmarkLookup(b, func(addrs []string) addrLookup { return newBloomAddrSet(addrs, 0.001) })
+}
+
+func TestRuntimeAddrs(t *testing.T) {
+	addrs := randomAddrs(3, rand.New(rand.NewSource(1)))
+	static := addrs[:2]
+	r := newRuntimeAddrs(mapAddrSet(addrSet(static)), func() []string { return static })
+	if added, err := r.add(addrs[0]); added || err != nil {
+		t.Errorf("adding a configured address: expected no change, got added=%v (%v)", added, err)
+	}
+	if added, err := r.add(addrs[2]); !added || err != nil {
+		t.Fatalf("adding a new address: expected it to be added, got added=%v (%v)", added, err)
+	}
+	if !r.remove(addrs[0]) {
+		t.Fatal("removing a configured address: expected it to be removed")
+	}
+	if r.remove(addrs[0]) {
+		t.Error("removing a removed address: expected no change")
+	}
+	if r.has(addrs[0]) || !r.has(addrs[1]) || !r.has(addrs[2]) {
+		t.Error("expected the removed address to be unmonitored and the others to be monitored")
+	}
+	if r.len() != 2 {
+		t.Errorf("expected 2 monitored addresses, got %d", r.len())
+	}
+	want := []string{addrs[1], addrs[2]}
+	if want[0] > want[1] {
+		want[0], want[1] = want[1], want[0]
+	}
+	if got := r.addrs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the monitored addresses %v, got %v", want, got)
+	}
+
+	r.maxAddrs = 2
+	if _, err := r.add(addrs[0]); err == nil {
+		t.Error("adding beyond the max. addresses: expected an error")
+	}
+}
+
+func TestNormalizeAddrChecksum(t *testing.T) {
+	addr := strings.Repeat("A", 81)
+	checksum, err := address.Checksum(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "9ABCDEFGH"
+	if wrong == checksum {
+		wrong = "HGFEDCBA9"
+	}
+	for _, tt := range []struct {
+		name  string
+		addr  string
+		valid bool
+	}{
+		{"81 trytes", addr, true},
+		{"valid checksum", addr + checksum, true},
+		{"wrong checksum", addr + wrong, false},
+		{"not trytes", strings.ToLower(addr), false},
+		{"truncated", addr[:80], false},
+	} {
+		normalized, err := normalizeAddr(tt.addr)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("%s: expected an error but got %s", tt.name, normalized)
+			}
+			continue
+		}
+		if err != nil || normalized != addr {
+			t.Errorf("%s: expected %s but got '%s' (%v)", tt.name, addr, normalized, err)
+		}
+	}
+}
+
+func TestServeControlAddrs(t *testing.T) {
+	addrs := randomAddrs(1, rand.New(rand.NewSource(2)))
+	r := newRuntimeAddrs(mapAddrSet(addrSet(nil)), func() []string { return nil })
+	for _, tt := range []struct {
+		name   string
+		auth   string
+		body   string
+		status int
+		// a part of the expected error
+		err string
+	}{
+		{"missing token", "", `{"address": "` + addrs[0] + `"}`, http.StatusUnauthorized, "unauthorized"},
+		{"token without bearer prefix", "secret", `{"address": "` + addrs[0] + `"}`, http.StatusUnauthorized, "unauthorized"},
+		{"wrong token", "Bearer other", `{"address": "` + addrs[0] + `"}`, http.StatusUnauthorized, "unauthorized"},
+		{"invalid address", "Bearer secret", `{"address": "ABC"}`, http.StatusBadRequest, "ABC"},
+		{"oversized body", "Bearer secret", `{"address": "` + strings.Repeat("A", controlMaxBodySize) + `"}`, http.StatusBadRequest, "too large"},
+		{"added address", "Bearer secret", `{"address": "` + addrs[0] + `"}`, http.StatusCreated, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/addrs", strings.NewReader(tt.body))
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		serveControlAddrs(rec, req, r, "secret")
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		if tt.status == http.StatusCreated {
+			continue
+		}
+		var res controlErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || !strings.Contains(res.Error, tt.err) {
+			t.Errorf("%s: expected an error JSON body containing '%s', got '%s' (%v)", tt.name, tt.err, rec.Body, err)
+		}
+	}
+	if !r.has(addrs[0]) {
+		t.Error("expected the added address to be monitored")
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"localhost:6061": true, "127.0.0.1:6061": true, "[::1]:6061": true,
+		":6061": false, "0.0.0.0:6061": false, "10.0.0.1:6061": false, "localhost": false,
+	} {
+		if got := isLoopbackAddr(addr); got != expected {
+			t.Errorf("%s: expected loopback=%v, got %v", addr, expected, got)
+		}
+	}
+}
+
+func TestRemoteAddrsListedDuringRefresh(t *testing.T) {
+	addrs := randomAddrs(2, rand.New(rand.NewSource(3)))
+	fetching, release := make(chan struct{}), make(chan struct{})
+	l := newAddrList("test", addrs[:1], func() ([]byte, error) {
+		close(fetching)
+		<-release
+		return []byte(addrs[1]), nil
+	})
+	done := make(chan error)
+	go func() { done <- l.refresh() }()
+	<-fetching
+	listed := make(chan []string)
+	go func() { listed <- l.addrs() }()
+	select {
+	case got := <-listed:
+		if !reflect.DeepEqual(got, addrs[:1]) {
+			t.Errorf("expected the static addresses during the first load, got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listing the addresses waited for the refresh")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := l.addrs(); !reflect.DeepEqual(got, addrs) {
+		t.Errorf("expected the static and the loaded addresses, got %v", got)
+	}
+}
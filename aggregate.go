@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// bundleAggregator buffers the tx alerts of each group sharing a bundle hash for a short window, so that the matches
+// of a bundle touching multiple monitored addresses are alerted about as a single bundle summary.
+type bundleAggregator struct {
+	window  time.Duration
+	pending map[string]*pendingAggregate
+}
+
+type pendingAggregate struct {
+	group  *watchGroup
+	events []*txEvent
+	first  time.Time
+}
+
+func newBundleAggregator(window time.Duration) *bundleAggregator {
+	return &bundleAggregator{window: window, pending: make(map[string]*pendingAggregate)}
+}
+
+// add buffers the given tx alert of the given group with the alerts of the same bundle. Reattachments of already
+// buffered txs (same address and index in the bundle) are dropped, so that they don't count twice.
+func (a *bundleAggregator) add(group *watchGroup, event *txEvent, now time.Time) {
+	key := group.Name + ":" + event.Bundle
+	aggregate, has := a.pending[key]
+	if !has {
+		aggregate = &pendingAggregate{group: group, first: now}
+		a.pending[key] = aggregate
+	}
+	for _, buffered := range aggregate.events {
+		if buffered.Address == event.Address && buffered.CurrentIndex == event.CurrentIndex {
+			return
+		}
+	}
+	aggregate.events = append(aggregate.events, event)
+}
+
+// due removes and returns the buffered alerts whose window passed.
+func (a *bundleAggregator) due(now time.Time) []*pendingAggregate {
+	var due []*pendingAggregate
+	for key, aggregate := range a.pending {
+		if now.Sub(aggregate.first) < a.window {
+			continue
+		}
+		delete(a.pending, key)
+		due = append(due, aggregate)
+	}
+	return due
+}
+
+// flushAggregates alerts about the buffered alerts whose window passed.
+func (m *Monitor) flushAggregates(now time.Time) {
+	for _, aggregate := range m.aggregator.due(now) {
+		m.notifyAggregate(aggregate)
+	}
+}
+
+// notifyAggregate alerts about the given buffered alerts of a bundle: a single alert as is, multiple ones as the
+// summary of their bundle.
+func (m *Monitor) notifyAggregate(aggregate *pendingAggregate) {
+	if len(aggregate.events) == 1 {
+		m.notifyTx(aggregate.group, aggregate.events[0])
+		return
+	}
+	sort.Slice(aggregate.events, func(i, j int) bool {
+		return aggregate.events[i].CurrentIndex < aggregate.events[j].CurrentIndex
+	})
+	txs := make([]*transaction.Transaction, len(aggregate.events))
+	for i, event := range aggregate.events {
+		txs[i] = event.Transaction
+	}
+	summary := bundleAlert(aggregate.group, txs)
+	if summary == nil {
+		return
+	}
+	summary.Node = aggregate.events[0].Node
+	bundlesAggregated.Add(1)
+	logEvent(levelInfo, logFields{Event: "bundle_aggregated", Bundle: summary.Bundle, Group: aggregate.group.Name}, "aggregated %d tx alert(s) of bundle %s touching %d monitored address(es) (group %s)", len(txs), summary.Bundle, len(summary.Addresses), aggregate.group.Name)
+	m.notifyBundle(aggregate.group, summary)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the endpoint of the ECS container credentials for AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+	ecsCredentialsEndpoint = "http://169.254.170.2"
+	// the endpoint of the EC2 instance metadata service, unless overridden by AWS_EC2_METADATA_SERVICE_ENDPOINT
+	imdsEndpoint = "http://169.254.169.254"
+	// the timeout of a request to the local credential endpoints, short so that the chain fails fast off AWS
+	awsMetadataTimeout = 2 * time.Second
+	// how long before their expiration temporary credentials are refreshed
+	awsCredentialsRefreshMargin = 5 * time.Minute
+)
+
+// awsCredentials are the credentials signing the requests to AWS.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	// when temporary credentials expire, zero if they don't
+	expires time.Time
+}
+
+// awsCredentialProvider is a link of the AWS credentials chain. It returns nil credentials if it doesn't apply, e.g.
+// as its environment variables aren't set.
+type awsCredentialProvider struct {
+	name string
+	// whether it resolves temporary credentials, which are cached
+	temporary bool
+	resolve   func(ctx context.Context) (*awsCredentials, error)
+}
+
+// awsCredentialChain are the links of the standard AWS credentials chain in its order: the environment, the shared
+// credentials file, the web identity token (EKS), the ECS container credentials and the EC2 instance profile.
+var awsCredentialChain = []awsCredentialProvider{
+	{"environment", false, envAWSCredentials},
+	{"shared credentials file", false, sharedFileAWSCredentials},
+	{"web identity token", true, webIdentityAWSCredentials},
+	{"ECS container credentials", true, ecsAWSCredentials},
+	{"EC2 instance metadata", true, imdsAWSCredentials},
+}
+
+// awsCredentialsCache holds the last temporary credentials of the chain until shortly before they expire, as
+// fetching them takes requests.
+var awsCredentialsCache struct {
+	mu    sync.Mutex
+	creds *awsCredentials
+}
+
+// awsMetadataClient requests the local credential endpoints and STS.
+var awsMetadataClient = &http.Client{Timeout: awsMetadataTimeout}
+
+// resolveAWSCredentials resolves the AWS credentials via the first applicable link of the AWS credentials chain. The
+// environment and the shared credentials file are read per request to pick up rotated credentials, temporary
+// credentials are reused until shortly before they expire.
+func resolveAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	awsCredentialsCache.mu.Lock()
+	defer awsCredentialsCache.mu.Unlock()
+	for _, provider := range awsCredentialChain {
+		// the links of static credentials take precedence over the cached temporary ones
+		if cached := awsCredentialsCache.creds; provider.temporary && cached != nil && time.Until(cached.expires) > awsCredentialsRefreshMargin {
+			return cached, nil
+		}
+		creds, err := provider.resolve(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve AWS credentials via the %s: %w", provider.name, err)
+		}
+		if creds == nil {
+			continue
+		}
+		if provider.temporary {
+			awsCredentialsCache.creds = creds
+		}
+		return creds, nil
+	}
+	return nil, errors.New("no AWS credentials found in the environment, the shared credentials file, a web identity token, the ECS container credentials or the EC2 instance metadata")
+}
+
+// envAWSCredentials resolves the credentials of AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func envAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return nil, nil
+	}
+	return &awsCredentials{accessKeyID: id, secretAccessKey: secret, sessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+}
+
+// sharedFileAWSCredentials resolves the credentials of the AWS_PROFILE (default 'default') of the shared credentials
+// file, if it exists.
+func sharedFileAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the shared credentials file: %w", err)
+	}
+	defer f.Close()
+
+	creds := &awsCredentials{}
+	inProfile := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if !inProfile || len(split) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(split[1])
+		switch strings.TrimSpace(split[0]) {
+		case "aws_access_key_id":
+			creds.accessKeyID = value
+		case "aws_secret_access_key":
+			creds.secretAccessKey = value
+		case "aws_session_token":
+			creds.sessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the shared credentials file: %w", err)
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// webIdentityAWSCredentials assumes the AWS_ROLE_ARN with the token of the AWS_WEB_IDENTITY_TOKEN_FILE via STS, as set
+// up by EKS for the service accounts of pods (IRSA).
+func webIdentityAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return nil, nil
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the web identity token: %w", err)
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "addr_monitor"
+	}
+	endpoint := "https://sts.amazonaws.com/"
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com/"
+	}
+	form := url.Values{
+		"Action": {"AssumeRoleWithWebIdentity"}, "Version": {"2011-06-15"}, "RoleArn": {roleARN},
+		"RoleSessionName": {session}, "WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to build AssumeRoleWithWebIdentity request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	content, err := doAWSMetadataRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	var res assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("unable to parse AssumeRoleWithWebIdentity response: %w", err)
+	}
+	c := res.Credentials
+	return &awsCredentials{accessKeyID: c.AccessKeyID, secretAccessKey: c.SecretAccessKey, sessionToken: c.SessionToken, expires: c.Expiration}, nil
+}
+
+// awsMetadataCredentials are the temporary credentials served by the ECS container credentials and the EC2 instance
+// metadata service.
+type awsMetadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func parseAWSMetadataCredentials(content []byte) (*awsCredentials, error) {
+	var c awsMetadataCredentials
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("unable to parse credentials: %w", err)
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, errors.New("credentials lack the access key")
+	}
+	return &awsCredentials{accessKeyID: c.AccessKeyID, secretAccessKey: c.SecretAccessKey, sessionToken: c.Token, expires: c.Expiration}, nil
+}
+
+// ecsAWSCredentials fetches the credentials of the task role from the ECS container credentials endpoint of the
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or the AWS_CONTAINER_CREDENTIALS_FULL_URI, the latter authorized by the
+// AWS_CONTAINER_AUTHORIZATION_TOKEN or the token of the AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE, if set.
+func ecsAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		uri = ecsCredentialsEndpoint + relative
+	}
+	if uri == "" {
+		return nil, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build container credentials request: %w", err)
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the container authorization token: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	content, err := doAWSMetadataRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return parseAWSMetadataCredentials(content)
+}
+
+// imdsAWSCredentials fetches the credentials of the instance profile from the EC2 instance metadata service (IMDSv2),
+// unless AWS_EC2_METADATA_DISABLED is 'true'. Off EC2, the endpoint isn't reachable and the chain ends.
+func imdsAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, nil
+	}
+	endpoint := imdsEndpoint
+	if override := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); override != "" {
+		endpoint = strings.TrimSuffix(override, "/")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build instance metadata token request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := doAWSMetadataRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build instance metadata request: %w", err)
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return doAWSMetadataRequest(req)
+	}
+	roles, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(roles)), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("the instance has no instance profile")
+	}
+	content, err := get("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return nil, err
+	}
+	return parseAWSMetadataCredentials(content)
+}
+
+// doAWSMetadataRequest sends the given request to a credential endpoint and returns the content of the response.
+func doAWSMetadataRequest(req *http.Request) ([]byte, error) {
+	res, err := awsMetadataClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request %s: %w", req.URL.Host, err)
+	}
+	defer closeResponse(res)
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the response of %s: %w", req.URL.Host, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with status %s: %s", req.URL.Host, res.Status, content)
+	}
+	return content, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import "math"
+
+// baselineFilter only lets through the alerts of value txs which are large relative to the typical value of their
+// address, as tracked by an exponential moving average (EMA) of the magnitudes of the address's recent values.
+type baselineFilter struct {
+	multiple float64
+	// the smoothing factor of the EMA, the weight of the latest value
+	alpha float64
+	// the number of value txs establishing the baseline of an address, whose alerts are all let through
+	minTxs    int
+	baselines map[string]*addrBaseline
+}
+
+type addrBaseline struct {
+	ema float64
+	txs int
+}
+
+func newBaselineFilter(multiple float64, alpha float64, minTxs int) *baselineFilter {
+	return &baselineFilter{multiple: multiple, alpha: alpha, minTxs: minTxs, baselines: make(map[string]*addrBaseline)}
+}
+
+// observe adds the given value of a tx on the given address to the address's baseline and reports whether the value
+// exceeds the baseline of the preceding txs by the multiple, along with that baseline (0 while establishing it).
+func (f *baselineFilter) observe(addr string, value int64) (bool, float64) {
+	magnitude := math.Abs(float64(value))
+	baseline, has := f.baselines[addr]
+	if !has {
+		f.baselines[addr] = &addrBaseline{ema: magnitude, txs: 1}
+		return true, 0
+	}
+	established := baseline.txs >= f.minTxs
+	ema := baseline.ema
+	baseline.ema = f.alpha*magnitude + (1-f.alpha)*baseline.ema
+	baseline.txs++
+	if !established {
+		return true, 0
+	}
+	return magnitude > f.multiple*ema, ema
+}
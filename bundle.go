@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// bundleAssembler buffers txs by their bundle hash until every tx of the bundle has been seen.
+// Bundles which don't complete within the timeout are dropped.
+type bundleAssembler struct {
+	timeout   time.Duration
+	bundles   map[string]*pendingBundle
+	lastPrune time.Time
+}
+
+type pendingBundle struct {
+	txs       []*transaction.Transaction
+	seen      uint64
+	firstSeen time.Time
+}
+
+func newBundleAssembler(timeout time.Duration) *bundleAssembler {
+	return &bundleAssembler{
+		timeout:   timeout,
+		bundles:   make(map[string]*pendingBundle),
+		lastPrune: time.Now(),
+	}
+}
+
+// add adds the given tx to its pending bundle and returns the bundle's txs ordered by their index
+// if the tx completed the bundle, or nil otherwise.
+func (b *bundleAssembler) add(tx *transaction.Transaction) []*transaction.Transaction {
+	now := time.Now()
+	if now.Sub(b.lastPrune) > b.timeout {
+		b.prune(now)
+	}
+
+	if tx.CurrentIndex > tx.LastIndex {
+		return nil
+	}
+
+	bndl, has := b.bundles[tx.Bundle]
+	if !has {
+		bndl = &pendingBundle{txs: make([]*transaction.Transaction, tx.LastIndex+1), firstSeen: now}
+		b.bundles[tx.Bundle] = bndl
+	}
+
+	// txs of reattachments share the same bundle and index, the first seen one is kept
+	if uint64(len(bndl.txs)) != tx.LastIndex+1 || bndl.txs[tx.CurrentIndex] != nil {
+		return nil
+	}
+	bndl.txs[tx.CurrentIndex] = tx
+	bndl.seen++
+
+	if bndl.seen != uint64(len(bndl.txs)) {
+		return nil
+	}
+	delete(b.bundles, tx.Bundle)
+	return bndl.txs
+}
+
+func (b *bundleAssembler) prune(now time.Time) {
+	for hash, bndl := range b.bundles {
+		if now.Sub(bndl.firstSeen) > b.timeout {
+			delete(b.bundles, hash)
+		}
+	}
+	b.lastPrune = now
+}
+
+// bundleAddrValue is the net value moved by a monitored address within a bundle.
+type bundleAddrValue struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
+	// the sum of the address' outputs within the bundle, for monitored addresses
+	Received int64 `json:"received,omitempty"`
+}
+
+// bundleSummary describes a complete bundle touching monitored addresses.
+type bundleSummary struct {
+	Event  string `json:"event"`
+	Group  string `json:"group"`
+	Bundle string `json:"bundle"`
+	TailTx string `json:"tailTx"`
+	Value  int64  `json:"value"`
+	// the total value received by the monitored addresses, summed over all of their outputs within the bundle
+	Received  int64             `json:"received"`
+	Addresses []bundleAddrValue `json:"addresses"`
+	// the estimated fiat value of the transferred value, if a price is available
+	FiatValue *float64 `json:"fiatValue,omitempty"`
+	// the input addresses of bundles in which a monitored address receives value, if enabled
+	Senders []bundleAddrValue `json:"senders,omitempty"`
+	Txs     []string          `json:"txs"`
+	// the node the bundle was completed from, if receiving from multiple nodes at once
+	Node string `json:"node,omitempty"`
+
+	// set if TailTx isn't the bundle's tail but its first matched tx, as aggregated bundles lack the tail unless it
+	// matched
+	tailMissing bool
+}
+
+// summarizeBundle builds the summary of the given complete bundle, returning nil if no monitored address is involved.
+func summarizeBundle(group *watchGroup, txs []*transaction.Transaction) *bundleSummary {
+	summary := &bundleSummary{Event: "bundle", Group: group.Name, Bundle: txs[0].Bundle, TailTx: txs[0].Hash, tailMissing: txs[0].CurrentIndex != 0}
+	monitoredIndex := make(map[string]int)
+	for _, tx := range txs {
+		summary.Txs = append(summary.Txs, tx.Hash)
+		if tx.Value > 0 {
+			summary.Value += tx.Value
+		}
+		if !group.matcher.matchTx(tx).Matched {
+			continue
+		}
+		i, has := monitoredIndex[tx.Address]
+		if !has {
+			i = len(summary.Addresses)
+			monitoredIndex[tx.Address] = i
+			summary.Addresses = append(summary.Addresses, bundleAddrValue{Address: tx.Address})
+		}
+		summary.Addresses[i].Value += tx.Value
+		if tx.Value > 0 {
+			summary.Addresses[i].Received += tx.Value
+			summary.Received += tx.Value
+		}
+	}
+	if len(summary.Addresses) == 0 {
+		return nil
+	}
+	if *bundleSenders && receivesValue(summary.Addresses) {
+		summary.Senders = bundleInputs(txs)
+	}
+	return summary
+}
+
+func receivesValue(addrs []bundleAddrValue) bool {
+	for _, addr := range addrs {
+		if addr.Value > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleInputs returns the addresses spending value in the given bundle with their net value.
+// Inputs spanning multiple txs of the same address (higher security levels) are merged.
+func bundleInputs(txs []*transaction.Transaction) []bundleAddrValue {
+	var inputs []bundleAddrValue
+	inputIndex := make(map[string]int)
+	for _, tx := range txs {
+		if tx.Value >= 0 {
+			continue
+		}
+		i, has := inputIndex[tx.Address]
+		if !has {
+			i = len(inputs)
+			inputIndex[tx.Address] = i
+			inputs = append(inputs, bundleAddrValue{Address: tx.Address})
+		}
+		inputs[i].Value += tx.Value
+	}
+	return inputs
+}
+
+// bundleAlert returns the summary to alert the given group about for the given complete bundle,
+// or nil if the bundle doesn't touch any of the group's monitored addresses or is filtered out.
+func bundleAlert(group *watchGroup, txs []*transaction.Transaction) *bundleSummary {
+	summary := summarizeBundle(group, txs)
+	if summary == nil {
+		return nil
+	}
+	if summary.Value == 0 && group.OnlyValue {
+		return nil
+	}
+	if group.filteredValue(summary.Value) != "" {
+		return nil
+	}
+	if summary.Value != 0 {
+		summary.FiatValue = fiatValueOf(summary.Value)
+	}
+	return summary
+}
+
+// spendSummary describes a complete bundle in which monitored addresses spend value.
+type spendSummary struct {
+	Event  string `json:"event"`
+	Group  string `json:"group"`
+	Bundle string `json:"bundle"`
+	TailTx string `json:"tailTx"`
+	// the monitored addresses spending value with their net value
+	Inputs []bundleAddrValue `json:"inputs"`
+	// the addresses receiving value
+	Outputs []bundleAddrValue `json:"outputs"`
+	// the node the bundle was completed from, if receiving from multiple nodes at once
+	Node string `json:"node,omitempty"`
+}
+
+// spendAlert returns the summary to alert the given group about if its monitored addresses are inputs of
+// the given complete bundle, or nil if none of them spends value in it.
+func spendAlert(group *watchGroup, txs []*transaction.Transaction) *spendSummary {
+	summary := &spendSummary{Event: "spend", Group: group.Name, Bundle: txs[0].Bundle, TailTx: txs[0].Hash}
+	// the tags of an input are the ones of its first tx
+	firstTxs := make(map[string]*transaction.Transaction)
+	for i := len(txs) - 1; i >= 0; i-- {
+		firstTxs[txs[i].Address] = txs[i]
+	}
+	for _, input := range bundleInputs(txs) {
+		if group.matcher.matchTx(firstTxs[input.Address]).Matched {
+			summary.Inputs = append(summary.Inputs, input)
+		}
+	}
+	if len(summary.Inputs) == 0 {
+		return nil
+	}
+	for _, tx := range txs {
+		if tx.Value > 0 {
+			summary.Outputs = append(summary.Outputs, bundleAddrValue{Address: tx.Address, Value: tx.Value})
+		}
+	}
+	return summary
+}
+
+var spendTemplate = `monitoring: *SPEND* of monitored address(es)
+- bundle %s
+- tail tx %s
+`
+
+// slackSpendText renders the chat msg of the given spend alert.
+func slackSpendText(summary *spendSummary) string {
+	bundleLink := explorerLink(*bundleExplorerURI, *bundleMirrorURI, summary.Bundle)
+	txLink := explorerLink(*txExplorerURI, *txMirrorURI, summary.TailTx)
+	var lines []string
+	for _, input := range summary.Inputs {
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, input.Address)
+		lines = append(lines, fmt.Sprintf("  - %s (%d)\n", addrLink, input.Value))
+	}
+	lines = append(lines, "- outputs:\n")
+	for _, output := range summary.Outputs {
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, output.Address)
+		lines = append(lines, fmt.Sprintf("  - %s (%d)\n", addrLink, output.Value))
+	}
+	header := fmt.Sprintf(spendTemplate, bundleLink, txLink)
+	if summary.Node != "" {
+		header += fmt.Sprintf("- node %s\n", summary.Node)
+	}
+	header += "- inputs:\n"
+	return renderSlackText(summary.Event, summary, truncateLines(header, lines, *maxMsgLength))
+}
+
+var bundleWebhookTemplate = `monitoring:
+- saw bundle %s transferring %s
+- %s %s
+`
+
+// slackBundleText renders the chat msg of the given bundle alert.
+func slackBundleText(summary *bundleSummary) string {
+	bundleLink := explorerLink(*bundleExplorerURI, *bundleMirrorURI, summary.Bundle)
+	txLink := explorerLink(*txExplorerURI, *txMirrorURI, summary.TailTx)
+	var addrLines []string
+	for _, addr := range summary.Addresses {
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, addr.Address)
+		if addr.Received != 0 && addr.Received != addr.Value {
+			addrLines = append(addrLines, fmt.Sprintf("  - %s (%d, received %d)\n", addrLink, addr.Value, addr.Received))
+			continue
+		}
+		addrLines = append(addrLines, fmt.Sprintf("  - %s (%d)\n", addrLink, addr.Value))
+	}
+	if len(summary.Senders) > 0 {
+		addrLines = append(addrLines, "- sent by:\n")
+		for _, sender := range summary.Senders {
+			senderLink := explorerLink(*addrExplorerURI, *addrMirrorURI, sender.Address)
+			addrLines = append(addrLines, fmt.Sprintf("  - %s (%d)\n", senderLink, sender.Value))
+		}
+	}
+	txLabel := "tail tx"
+	if summary.tailMissing {
+		txLabel = "first matched tx"
+	}
+	header := fmt.Sprintf(bundleWebhookTemplate, bundleLink, displayValue(summary.Value, summary.FiatValue), txLabel, txLink)
+	if summary.Received != 0 {
+		header += fmt.Sprintf("- monitored addresses received %d in total\n", summary.Received)
+	}
+	if summary.Node != "" {
+		header += fmt.Sprintf("- node %s\n", summary.Node)
+	}
+	header += "- monitored addresses:\n"
+	return renderSlackText(summary.Event, summary, truncateLines(header, addrLines, *maxMsgLength))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpTimeout bounds the query of the -ntpServer.
+const ntpTimeout = 5 * time.Second
+
+// ntpEpochOffset is the number of seconds in between the NTP epoch (1900) and the unix epoch.
+const ntpEpochOffset = 2208988800
+
+// the wall clock time as per the -ntpServer at the monotonic clock reading of clockCheckedAt,
+// unset if the clock wasn't checked
+var (
+	clockCheckedWall time.Time
+	clockCheckedAt   time.Time
+)
+
+// alertTime returns the current time to stamp alerts with. If the system clock was checked against the -ntpServer,
+// it's the NTP corrected time of the check advanced by the monotonic clock, so that neither the system clock's
+// skew nor later jumps of it affect the alert timestamps.
+func alertTime() time.Time {
+	if clockCheckedAt.IsZero() {
+		return time.Now()
+	}
+	return clockCheckedWall.Add(time.Since(clockCheckedAt))
+}
+
+// checkClock queries the given NTP server for the skew of the system clock, warning if it exceeds the given max. skew
+// or, in strict mode, refusing to start. Alerts are stamped with the corrected time from here on.
+func checkClock(server string, maxSkew time.Duration) {
+	offset, err := queryNTPOffset(server)
+	if err != nil {
+		warnf("warning: could not check the system clock, stamping alerts with it unchecked: %s", err)
+		return
+	}
+	clockCheckedAt = time.Now()
+	clockCheckedWall = clockCheckedAt.Add(offset).Round(0)
+	skew := offset
+	if skew < 0 {
+		skew = -skew
+	}
+	if maxSkew > 0 && skew > maxSkew {
+		if *strict {
+			fatalf("error: strict mode: the system clock is off by %v as per NTP server %s (more than -maxClockSkew %v)", offset, server, maxSkew)
+		}
+		warnf("warning: the system clock is off by %v as per NTP server %s (more than -maxClockSkew %v), stamping alerts with the corrected time", offset, server, maxSkew)
+		return
+	}
+	infof("the system clock is off by %v as per NTP server %s", offset, server)
+}
+
+// queryNTPOffset returns the offset of the system clock to the given NTP server's clock (host[:port]) via SNTP.
+func queryNTPOffset(server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("unable to dial NTP server: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	request := make([]byte, 48)
+	// leap indicator 0, version 4, mode 3 (client)
+	request[0] = 0<<6 | 4<<3 | 3
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[40:], ntpTimestamp(sent))
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("unable to query NTP server: %w", err)
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read NTP response: %w", err)
+	}
+	received := sent.Add(time.Since(sent))
+	if n < 48 {
+		return 0, fmt.Errorf("unable to read NTP response: short response of %d bytes", n)
+	}
+	if mode := response[0] & 7; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP response mode %d", mode)
+	}
+	if stratum := response[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("NTP server is unsynchronized (stratum %d)", stratum)
+	}
+	serverReceived := ntpTime(binary.BigEndian.Uint64(response[32:]))
+	serverSent := ntpTime(binary.BigEndian.Uint64(response[40:]))
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+func ntpTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func ntpTime(timestamp uint64) time.Time {
+	seconds := int64(timestamp>>32) - ntpEpochOffset
+	nanos := int64((timestamp & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanos)
+}
@@ -0,0 +1,784 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/guards"
+)
+
+// durationFlags are the flags of durations by name.
+var durationFlags = map[string]*string{
+	"connRetryInterval":       connRetryIntervalStr,
+	"dialTimeout":             dialTimeoutStr,
+	"bundleTimeout":           bundleTimeoutStr,
+	"initialConnectDelay":     initialConnDelayStr,
+	"reconnectAlertWindow":    reconnectAlertWinStr,
+	"httpIdleConnTimeout":     httpIdleTimeoutStr,
+	"httpTimeout":             httpTimeoutStr,
+	"addrsURLRefreshInterval": addrsURLRefreshStr,
+	"slackTimeout":            slackTimeoutStr,
+	"webhookTimeout":          webhookTimeoutStr,
+	"notifyDeadline":          notifyDeadlineStr,
+	"slackMinInterval":        slackMinIntervalStr,
+	"webhookMinInterval":      webhookMinIntervStr,
+	"pagerDutyTimeout":        pagerDutyTimeoutStr,
+	"opsgenieTimeout":         opsgenieTimeoutStr,
+	"snsTimeout":              snsTimeoutStr,
+	"startupJitter":           startupJitterStr,
+	"redisDedupTTL":           redisDedupTTLStr,
+	"dedupWindow":             dedupWindowStr,
+	"priceTTL":                priceTTLStr,
+	"reattachmentWindow":      reattachWindowStr,
+	"parseErrorLogInterval":   parseErrLogIntervStr,
+	"noMatchTimeout":          noMatchTimeoutStr,
+	"matchRatioWindow":        matchRatioWindowStr,
+	"maxAge":                  maxAgeStr,
+	"idleProbeInterval":       idleProbeIntervalStr,
+	"maxIdle":                 maxIdleStr,
+	"milestoneTimeout":        milestoneTimeoutStr,
+	"maxStreamLag":            maxStreamLagStr,
+	"topNInterval":            topNIntervalStr,
+	"activityRetention":       activityRetentionStr,
+	"maxClockSkew":            maxClockSkewStr,
+	"connRetryMaxInterval":    connRetryMaxIntStr,
+	"maxDowntime":             maxDowntimeStr,
+	"downtimeWindow":          downtimeWindowStr,
+	"confirmationTimeout":     confirmTimeoutStr,
+	"shutdownTimeout":         shutdownTimeoutStr,
+	"bundleAggregateWindow":   bundleAggregateStr,
+	"debounceWindow":          debounceWindowStr,
+	"recordMaxAge":            recordMaxAgeStr,
+	"conflictWindow":          conflictWindowStr,
+}
+
+// loadConfig applies the -config file and loads the watch groups, the default one first, and the files of the
+// templates and severity rules. The problems of the configuration are logged and returned, the ones of the -config file
+// are fatal right away. The addresses needing cleanup and the groups without notification targets are warned about.
+func loadConfig() ([]*watchGroup, []error) {
+	var problems []error
+	origins := commandLineOrigins()
+	var configGroups []*watchGroup
+	if *configFile != "" {
+		var configProblems []error
+		configGroups, configProblems = applyConfigFile(*configFile, origins)
+		for _, problem := range configProblems {
+			errorf("invalid configuration: %s", problem)
+		}
+		if len(configProblems) > 0 {
+			fatalf("aborting due to %d problem(s) of the -config file", len(configProblems))
+		}
+	}
+	setLogFormat(*logFormat)
+	groups := append([]*watchGroup{defaultWatchGroup()}, configGroups...)
+	if *watchGroupsFile != "" {
+		fileGroups, err := loadWatchGroups(*watchGroupsFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("-groupsFile: %w", err))
+		}
+		groups = append(groups, fileGroups...)
+		if err := checkGroupNames(groups[1:]); err != nil && len(configGroups) > 0 {
+			problems = append(problems, fmt.Errorf("-groupsFile: %w (including the groups of the -config file)", err))
+		}
+	}
+	if *templatesFile != "" {
+		templates, err := loadSlackTemplates(*templatesFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("-templatesFile: %w", err))
+		}
+		slackTemplates = templates
+	}
+	if *webhookTemplatesFile != "" {
+		templates, err := loadWebhookTemplates(*webhookTemplatesFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("-webhookTemplatesFile: %w", err))
+		}
+		webhookTemplates = templates
+	}
+	if *severityRulesFile != "" {
+		rules, err := loadSeverityRules(*severityRulesFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("-pagerDutyRulesFile: %w", err))
+		}
+		severityRules = rules
+	}
+	if *nodeDSN != "" {
+		problems = append(problems, applyNodeDSN(*nodeDSN, origins)...)
+	}
+	problems = append(problems, validateConfig(groups)...)
+	for _, problem := range problems {
+		errorf("invalid configuration: %s", problem)
+	}
+	logAddrCleanups("-shadowAddrs", parseAddrList(*shadowAddrsStr)...)
+	for _, group := range groups {
+		logAddrCleanups("group "+group.Name, group.Addrs...)
+		logAddrCleanups("the ignored addresses of group "+group.Name, group.IgnoreAddrs...)
+		for addr := range group.AddrTags {
+			logAddrCleanups("the tagged addresses of group "+group.Name, addr)
+		}
+		if !group.hasTargets() {
+			warnf("warning: group %s has no notification targets, its matches are only logged", group.Name)
+		}
+	}
+	return groups, problems
+}
+
+// validateConfig checks the flags and the given watch groups for problems and returns every problem found.
+func validateConfig(groups []*watchGroup) []error {
+	var problems []error
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	for name, str := range durationFlags {
+		if dur, err := time.ParseDuration(*str); err != nil {
+			problemf("-%s: unable to parse duration '%s': %s", name, *str, err)
+		} else if dur < 0 {
+			problemf("-%s: must not be negative", name)
+		}
+	}
+
+	nodes := parseAddrList(*nodeURI)
+	if len(nodes) == 0 {
+		problemf("-node: no node given")
+	}
+	for _, node := range nodes {
+		if err := validateURI(node, "tcp", "ipc", "inproc"); err != nil {
+			problemf("-node: %s", err)
+		}
+	}
+	if *nodeMode != nodeModeFailover && *nodeMode != nodeModeFanIn {
+		problemf("-nodeMode: unknown mode '%s'", *nodeMode)
+	}
+	if *preferPrimary && *nodeMode != nodeModeFailover {
+		problemf("-preferPrimary: only applies to -nodeMode %s", nodeModeFailover)
+	}
+	switch *frameFormat {
+	case frameFormatTrytes:
+		if *subTopic != trytesSubTopic && *subTopic != txTrytesSubTopic {
+			problemf("-topic: unknown topic '%s'", *subTopic)
+		}
+	case frameFormatJSON:
+		if *subTopic == "" || strings.ContainsAny(*subTopic, " \t") {
+			problemf("-topic: must be non-empty without whitespace")
+		}
+		if *verifyTxHashes {
+			problemf("-verifyTxHashes: not supported with -frameFormat json, the frames carry no trytes")
+		}
+		if *bundleReassembly {
+			problemf("-bundleReassembly: not supported with -frameFormat json, the frames carry no bundle indexes")
+		}
+	default:
+		problemf("-frameFormat: unknown format '%s'", *frameFormat)
+	}
+	if strings.ContainsAny(*milestoneTopic, " \t") {
+		problemf("-milestoneTopic: must not contain whitespace")
+	} else if *milestoneTopic != "" && strings.HasPrefix(*subTopic, *milestoneTopic) {
+		problemf("-milestoneTopic: must not be a prefix of the -topic '%s'", *subTopic)
+	}
+	if *minConfirmations < 0 {
+		problemf("-minConfirmations: must not be negative")
+	} else if *minConfirmations > 0 {
+		if *bundleReassembly {
+			problemf("-minConfirmations: not supported with -bundleReassembly")
+		}
+		if *milestoneTopic == confirmationTopic {
+			problemf("-milestoneTopic: must not be the '%s' topic subscribed to by -minConfirmations", confirmationTopic)
+		}
+		if timeout, err := time.ParseDuration(*confirmTimeoutStr); err == nil && timeout == 0 {
+			problemf("-confirmationTimeout: must be positive with -minConfirmations")
+		}
+	}
+	if *confirmURI != "" {
+		if err := validateURI(*confirmURI, "http", "https"); err != nil {
+			problemf("-confirmURI: %s", err)
+		}
+		if *minConfirmations > 0 {
+			problemf("-confirmURI: not supported with -minConfirmations, whose alerts are only sent once confirmed")
+		}
+		if timeout, err := time.ParseDuration(*confirmTimeoutStr); err == nil && timeout == 0 {
+			problemf("-confirmationTimeout: must be positive with -confirmURI")
+		}
+	}
+	if timeout, err := time.ParseDuration(*milestoneTimeoutStr); err == nil && timeout > 0 && *milestoneTopic == "" {
+		problemf("-milestoneTimeout: requires -milestoneTopic")
+	}
+	for name, explorer := range map[string]struct {
+		uri         string
+		placeholder string
+		optional    bool
+	}{
+		"explorerTxsURI":          {*txExplorerURI, hashPlaceholder, false},
+		"explorerBundleURI":       {*bundleExplorerURI, hashPlaceholder, false},
+		"explorerAddrsURI":        {*addrExplorerURI, addrPlaceholder, false},
+		"explorerTxsMirrorURI":    {*txMirrorURI, hashPlaceholder, true},
+		"explorerBundleMirrorURI": {*bundleMirrorURI, hashPlaceholder, true},
+		"explorerAddrsMirrorURI":  {*addrMirrorURI, addrPlaceholder, true},
+	} {
+		if explorer.uri == "" && explorer.optional {
+			continue
+		}
+		if err := validateExplorerURI(explorer.uri, explorer.placeholder); err != nil {
+			problemf("-%s: %s", name, err)
+		}
+	}
+
+	if strings.ContainsAny(*explorerLinkSuffix, " \t\n|<>") {
+		problemf("-explorerLinkSuffix: must not contain whitespace or any of '|<>'")
+	} else if *explorerLinkSuffix != "" {
+		if err := validateURI(withLinkSuffix(explorerURL(*txExplorerURI, strings.Repeat("9", consts.HashTrytesSize))), "http", "https"); err != nil {
+			problemf("-explorerLinkSuffix: doesn't yield valid links: %s", err)
+		}
+	}
+
+	if *qrCodeURI != "" {
+		if err := validateExplorerURI(*qrCodeURI, addrPlaceholder); err != nil {
+			problemf("-qrCodeURI: %s", err)
+		}
+	}
+
+	if *addrsURL != "" {
+		if err := validateURI(*addrsURL, "http", "https"); err != nil {
+			problemf("-addrsURL: %s", err)
+		}
+		if *addrsFile != "" {
+			problemf("-addrsFile: can't be combined with -addrsURL")
+		}
+	}
+	if *addrsFileWatch && *addrsFile == "" {
+		problemf("-addrsFileWatch: requires -addrsFile")
+	}
+	if *notifyAddrChanges && *addrsURL == "" && *addrsFile == "" {
+		problemf("-notifyAddrChanges: requires -addrsURL or -addrsFile")
+	}
+	if *recordMaxSizeMB < 0 {
+		problemf("-recordMaxSizeMB: must not be negative")
+	}
+	if *recordMaxArchives < 0 {
+		problemf("-recordMaxArchives: must not be negative")
+	}
+	maxAge, err := time.ParseDuration(*recordMaxAgeStr)
+	if (*recordMaxSizeMB != 0 || (err == nil && maxAge > 0) || *recordMaxArchives != 0) && *recordFile == "" {
+		problemf("-recordMaxSizeMB, -recordMaxAge and -recordMaxArchives: require -recordFile")
+	}
+
+	if window, err := time.ParseDuration(*bundleAggregateStr); err == nil && window > 0 {
+		if *bundleReassembly {
+			problemf("-bundleAggregateWindow: not supported with -bundleReassembly, which alerts once per bundle already")
+		}
+		if *minConfirmations > 0 {
+			problemf("-bundleAggregateWindow: not supported with -minConfirmations")
+		}
+	}
+	if window, err := time.ParseDuration(*debounceWindowStr); err == nil && window > 0 {
+		if _, err := parseDebounceRelated(*debounceRelated); err != nil {
+			problemf("-debounceRelated: %s", err)
+		}
+		if aggregate, err := time.ParseDuration(*bundleAggregateStr); err == nil && aggregate > 0 {
+			problemf("-debounceWindow: not supported with -bundleAggregateWindow, debounce with the 'bundle' criterion instead")
+		}
+		if *bundleReassembly {
+			problemf("-debounceWindow: not supported with -bundleReassembly")
+		}
+		if *minConfirmations > 0 {
+			problemf("-debounceWindow: not supported with -minConfirmations")
+		}
+	}
+	if retention, err := time.ParseDuration(*activityRetentionStr); err == nil && retention > 0 && *pprofAddr == "" {
+		problemf("-activityRetention: requires -pprofAddr, which serves /activity")
+	}
+	if *bundleSenders && !*bundleReassembly {
+		problemf("-bundleSenders: requires -bundleReassembly")
+	}
+	switch *deliverySemantics {
+	case deliveryBestEffort, deliveryAtLeastOnce:
+	case deliveryAtMostOnce:
+		if *deliveryStateFile == "" {
+			problemf("-deliverySemantics: '%s' requires -deliveryStateFile", deliveryAtMostOnce)
+		}
+	default:
+		problemf("-deliverySemantics: must be '%s', '%s' or '%s'", deliveryBestEffort, deliveryAtLeastOnce, deliveryAtMostOnce)
+	}
+	if *queueDir != "" && *deliverySemantics != deliveryAtLeastOnce {
+		problemf("-queueDir: requires -deliverySemantics '%s'", deliveryAtLeastOnce)
+	}
+	if *notifyQueueSize < 0 {
+		problemf("-notifyQueueSize: must not be negative")
+	}
+	switch *queueOverflowPolicy {
+	case overflowBlock, overflowDropOldest, overflowDropNewest, overflowSpill:
+	default:
+		problemf("-queueOverflowPolicy: unknown policy '%s', must be '%s', '%s', '%s' or '%s'", *queueOverflowPolicy, overflowBlock, overflowDropOldest, overflowDropNewest, overflowSpill)
+	}
+	if *queueOverflowPolicy != overflowBlock && *notifyQueueSize == 0 {
+		problemf("-queueOverflowPolicy: requires -notifyQueueSize")
+	}
+	if (*queueOverflowPolicy == overflowSpill) != (*spillFile != "") {
+		problemf("-spillFile: required by and only used with -queueOverflowPolicy '%s'", overflowSpill)
+	}
+	if *spillFile != "" && *spillFile == *undeliveredFile {
+		problemf("-spillFile: must differ from -undeliveredFile")
+	}
+	if *redeliverUndelivered && *undeliveredFile == "" {
+		problemf("-redeliverUndelivered: requires -undeliveredFile")
+	}
+	if *redisAddr != "" {
+		if ttl, err := time.ParseDuration(*redisDedupTTLStr); err == nil && ttl < time.Millisecond {
+			problemf("-redisDedupTTL: must be at least 1ms")
+		}
+	}
+	if *dedupMaxEntries < 1 {
+		problemf("-dedupMaxEntries: must be at least 1")
+	}
+	if *correlateReattaches {
+		if window, err := time.ParseDuration(*reattachWindowStr); err == nil && window == 0 {
+			problemf("-reattachmentWindow: must be positive with -correlateReattachments")
+		}
+	}
+	if maxDowntime, err := time.ParseDuration(*maxDowntimeStr); err == nil && maxDowntime > 0 {
+		if window, err := time.ParseDuration(*downtimeWindowStr); err == nil && window <= maxDowntime {
+			problemf("-downtimeWindow: must be longer than -maxDowntime")
+		}
+	}
+	if *priceURI != "" {
+		if ttl, err := time.ParseDuration(*priceTTLStr); err == nil && ttl == 0 {
+			problemf("-priceTTL: must be positive to refresh the -priceURL")
+		}
+		if err := validateURI(*priceURI, "http", "https"); err != nil {
+			problemf("-priceURL: %s", err)
+		}
+	}
+	if *kafkaBrokers != "" && *kafkaTopic == "" {
+		problemf("-kafkaTopic: must not be empty")
+	}
+	if *pagerDutyRoutingKey != "" {
+		if err := validateURI(*pagerDutyURI, "http", "https"); err != nil {
+			problemf("-pagerDutyURI: %s", err)
+		}
+	}
+	if *opsgenieAPIKey != "" {
+		if err := validateURI(*opsgenieURI, "http", "https"); err != nil {
+			problemf("-opsgenieURI: %s", err)
+		}
+	}
+	if !pagerDutySeverities[*pagerDutySeverity] {
+		problemf("-pagerDutySeverity: unknown severity '%s'", *pagerDutySeverity)
+	}
+
+	if *spendAlerts && !*bundleReassembly {
+		problemf("-spendAlerts: requires -bundleReassembly")
+	}
+	if *spendSlackWebhookURI != "" {
+		if err := validateURI(*spendSlackWebhookURI, "http", "https"); err != nil {
+			problemf("-spendSlackWebhookURI: %s", err)
+		}
+	}
+	if *replicaCount < 1 {
+		problemf("-replicaCount: must be at least 1")
+	} else {
+		if *instanceID < 0 || *instanceID >= *replicaCount {
+			problemf("-instanceID: must be in between 0 and %d", *replicaCount-1)
+		}
+		if *shardOverlap < 0 || *shardOverlap >= *replicaCount {
+			problemf("-shardOverlap: must be in between 0 and %d", *replicaCount-1)
+		}
+	}
+	if *slackRateLimit < 0 {
+		problemf("-slackRateLimit: must not be negative")
+	}
+	if *slackRateLimit > 0 && *slackBurst < 1 {
+		problemf("-slackBurst: must be at least 1")
+	}
+	if *reconnectRateLimit < 0 {
+		problemf("-reconnectRateLimit: must not be negative")
+	}
+	if *reconnectRateLimit > 0 && *reconnectBurst < 1 {
+		problemf("-reconnectBurst: must be at least 1")
+	}
+	if *addrFormat != addrFormatLegacy && *addrFormat != addrFormatChrysalis {
+		problemf("-addressFormat: unknown address format '%s'", *addrFormat)
+	}
+	if _, err := parseAddrTags(*addrTemplatesStr); err != nil {
+		problemf("-addrTemplates: %s", strings.Replace(err.Error(), "address=tag", "address=name", 1))
+	}
+	if _, err := parseAddrTags(*addrTagsStr); err != nil {
+		problemf("-addrTags: %s", err)
+	}
+	for _, addr := range parseAddrList(*shadowAddrsStr) {
+		if _, err := normalizeAddr(addr); err != nil {
+			problemf("-shadowAddrs: %s", err)
+		}
+	}
+	if *addrSetBackend != addrSetMap && *addrSetBackend != addrSetBloom {
+		problemf("-addrSet: unknown address set backend '%s'", *addrSetBackend)
+	}
+	if *bloomFPRate <= 0 || *bloomFPRate >= 1 {
+		problemf("-bloomFPRate: must be in between 0 and 1")
+	}
+	if *maxMsgLength < 100 {
+		problemf("-maxMsgLength: must be at least 100")
+	}
+	if *notifier != notifierSlack && *notifier != notifierDiscord && *notifier != notifierGeneric {
+		problemf("-notifier: unknown chat platform '%s', must be '%s', '%s' or '%s'", *notifier, notifierSlack, notifierDiscord, notifierGeneric)
+	}
+	if *logFormat != logFormatText && *logFormat != logFormatJSON {
+		problemf("-logFormat: unknown format '%s', must be '%s' or '%s'", *logFormat, logFormatText, logFormatJSON)
+	}
+	if *multiMatchPolicy != multiMatchAll && *multiMatchPolicy != multiMatchFirst {
+		problemf("-multiMatchPolicy: unknown policy '%s'", *multiMatchPolicy)
+	}
+	if *suspiciousTxsPolicy != suspiciousTxsSkip && *suspiciousTxsPolicy != suspiciousTxsFlag {
+		problemf("-suspiciousTxs: unknown policy '%s'", *suspiciousTxsPolicy)
+	}
+	if *initialConnRetries < 0 {
+		problemf("-initialConnectRetries: must not be negative")
+	}
+	if *httpMaxInFlight < 0 {
+		problemf("-httpMaxInFlightPerHost: must not be negative")
+	}
+	if _, err := newNotificationTLSConfig(*httpCAFile, *httpPinnedCerts); err != nil {
+		problemf("-httpCAFile/-httpPinnedCerts: %s", err)
+	}
+	if *httpMaxIdleConns < 0 {
+		problemf("-httpMaxIdleConns: must not be negative")
+	}
+	if *matchRatioDeviation <= 1 {
+		problemf("-matchRatioDeviation: must be greater than 1")
+	}
+	if *matchRatioMinTxs < 1 {
+		problemf("-matchRatioMinTxs: must be at least 1")
+	}
+	if *reconnectAlertThres < 0 {
+		problemf("-reconnectAlertThreshold: must not be negative")
+	}
+	if _, err := time.LoadLocation(*dailyTimezone); err != nil {
+		problemf("-dailyTimezone: unable to load timezone '%s': %s", *dailyTimezone, err)
+	}
+	if loc, err := time.LoadLocation(*maintenanceTimezone); err != nil {
+		problemf("-maintenanceTimezone: unable to load timezone '%s': %s", *maintenanceTimezone, err)
+	} else if _, err := parseMaintenanceSchedule(*maintenanceWindows, loc); err != nil {
+		problemf("-maintenanceWindows: %s", err)
+	}
+	if loc, err := time.LoadLocation(*offHoursTimezone); err != nil {
+		problemf("-offHoursTimezone: unable to load timezone '%s': %s", *offHoursTimezone, err)
+	} else if _, err := parseMaintenanceSchedule(*offHours, loc); err != nil {
+		problemf("-offHours: %s", err)
+	}
+	if !pagerDutySeverities[*offHoursMinSeverity] {
+		problemf("-offHoursMinSeverity: unknown severity '%s'", *offHoursMinSeverity)
+	}
+	if _, err := parseClock(*offHoursDigestTime); err != nil {
+		problemf("-offHoursDigestTime: %s", err)
+	}
+	if *snsTopicARN != "" {
+		if _, err := snsRegion(*snsTopicARN); err != nil {
+			problemf("-snsTopicARN: %s, set -snsRegion", err)
+		}
+		if *snsEndpoint != "" {
+			if err := validateURI(*snsEndpoint, "http", "https"); err != nil {
+				problemf("-snsEndpoint: %s", err)
+			}
+		}
+	}
+	if *nodePublicKey != "" {
+		if _, err := parseNodePublicKey(*nodePublicKey); err != nil {
+			problemf("-nodePublicKey: %s", err)
+		}
+	}
+	if *baselineMultiple < 0 {
+		problemf("-baselineMultiple: must not be negative")
+	}
+	if *baselineAlpha <= 0 || *baselineAlpha > 1 {
+		problemf("-baselineAlpha: must be in between 0 (exclusive) and 1")
+	}
+	if *baselineMinTxs < 1 {
+		problemf("-baselineMinTxs: must be at least 1")
+	}
+	if *allowInject && *apiToken == "" {
+		problemf("-allowInject: requires -apiToken")
+	}
+	if *allowInject && *pprofAddr == "" {
+		problemf("-allowInject: requires -pprofAddr")
+	}
+	if *controlToken != "" && *controlAddr == "" {
+		problemf("-controlToken: requires -controlAddr")
+	}
+	if *controlAddr != "" && *controlToken == "" && !isLoopbackAddr(*controlAddr) {
+		problemf("-controlAddr: requires -controlToken unless listening on a loopback address (e.g. 'localhost:6061')")
+	}
+
+	for _, group := range groups {
+		for _, err := range group.validate() {
+			problemf("group %s: %s", group.Name, err)
+		}
+	}
+	if *hashTokenIndex < 0 {
+		problemf("-hashTokenIndex: must not be negative")
+	}
+	if *topN < 1 {
+		problemf("-topN: must be at least 1")
+	}
+	if *fifoOut != "" {
+		if info, err := os.Stat(*fifoOut); err != nil {
+			problemf("-fifoOut: %s", err)
+		} else if info.Mode()&os.ModeNamedPipe == 0 {
+			problemf("-fifoOut: %s is not a named pipe (create it via mkfifo)", *fifoOut)
+		}
+	}
+	if *addrsMaxShrink < 0 || *addrsMaxShrink > 100 {
+		problemf("-addrsMaxShrink: must be a percentage in between 0 and 100")
+	}
+	if *maxAddresses < 0 {
+		problemf("-maxAddresses: must not be negative")
+	} else if count := monitoredAddrCount(groups); *maxAddresses > 0 && count > *maxAddresses {
+		problemf("-maxAddresses: the groups monitor %d addresses, more than the max. of %d", count, *maxAddresses)
+	}
+
+	return problems
+}
+
+// monitoredAddrCount returns the number of addresses monitored by the given groups before their init.
+func monitoredAddrCount(groups []*watchGroup) int {
+	count := 0
+	for _, group := range groups {
+		count += len(group.Addrs)
+	}
+	return count
+}
+
+// validate checks the group's addresses and notification targets for problems.
+func (g *watchGroup) validate() []error {
+	var problems []error
+	for _, addr := range g.Addrs {
+		if _, err := normalizeAddr(addr); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, addr := range g.IgnoreAddrs {
+		if _, err := normalizeAddr(addr); err != nil {
+			problems = append(problems, fmt.Errorf("ignored %w", err))
+		}
+	}
+	for _, prefix := range g.AddrPrefixes {
+		if !guards.IsTrytes(prefix) || len(prefix) > 81 {
+			problems = append(problems, fmt.Errorf("address prefix '%s' is not at most 81 trytes", prefix))
+		}
+	}
+	for addr, tag := range g.AddrTags {
+		if _, err := normalizeAddr(addr); err != nil {
+			problems = append(problems, fmt.Errorf("tagged %w", err))
+		}
+		if !guards.IsTrytes(tag) || len(tag) > consts.TagTrinarySize/3 {
+			problems = append(problems, fmt.Errorf("required tag '%s' of address %s is not at most 27 trytes", tag, addr))
+		}
+	}
+	if g.TagPattern != "" {
+		if _, err := regexp.Compile(g.TagPattern); err != nil {
+			problems = append(problems, fmt.Errorf("tag pattern: %w", err))
+		}
+	} else if g.RequireTagPattern {
+		problems = append(problems, fmt.Errorf("requiring a tag pattern match requires a tag pattern"))
+	}
+	if g.Template != "" && !hasNamedTemplates(g.Template) {
+		problems = append(problems, fmt.Errorf("template '%s': no Slack msg or webhook template is named so", g.Template))
+	}
+	for addr, name := range g.AddrTemplates {
+		if _, err := normalizeAddr(addr); err != nil {
+			problems = append(problems, fmt.Errorf("templated %w", err))
+		}
+		if !hasNamedTemplates(name) {
+			problems = append(problems, fmt.Errorf("template '%s' of address %s: no Slack msg or webhook template is named so", name, addr))
+		}
+	}
+	if g.MinValue < 0 {
+		problems = append(problems, fmt.Errorf("min. value must not be negative"))
+	}
+	switch g.Direction {
+	case "", directionAny, directionIn, directionOut:
+	default:
+		problems = append(problems, fmt.Errorf("direction '%s' must be 'in', 'out' or 'any'", g.Direction))
+	}
+	if g.ZeroValueAlerts && (g.MinValue > 0 || g.Direction == directionIn || g.Direction == directionOut) {
+		problems = append(problems, fmt.Errorf("zero-value alerts are never sent with a min. value or a direction"))
+	}
+	if g.SlackWebhookURI != "" {
+		if err := validateURI(g.SlackWebhookURI, "http", "https"); err != nil {
+			problems = append(problems, fmt.Errorf("slack webhook URI: %w", err))
+		}
+	}
+	if g.WebhookURI != "" {
+		if err := validateURI(g.WebhookURI, "http", "https"); err != nil {
+			problems = append(problems, fmt.Errorf("webhook URI: %w", err))
+		}
+	}
+	return problems
+}
+
+// validateExplorerURI checks that the given explorer URI only contains well-formed instances of the given placeholder
+// and yields valid http(s) URIs.
+func validateExplorerURI(uri string, placeholder string) error {
+	if strings.Contains(uri, "%s") {
+		return fmt.Errorf("URI '%s' contains a printf verb, use the '%s' placeholder instead", uri, placeholder)
+	}
+	for rest := uri; ; {
+		open, closing := strings.Index(rest, "{"), strings.Index(rest, "}")
+		if open == -1 && closing == -1 {
+			break
+		}
+		if open != -1 && closing == -1 {
+			return fmt.Errorf("URI '%s' contains an unterminated placeholder, expected '%s'", uri, placeholder)
+		}
+		if open == -1 || closing < open {
+			return fmt.Errorf("URI '%s' contains a dangling '}', expected the '%s' placeholder", uri, placeholder)
+		}
+		if found := rest[open : closing+1]; found != placeholder {
+			return fmt.Errorf("URI '%s' contains the unknown placeholder '%s', expected '%s'", uri, found, placeholder)
+		}
+		rest = rest[closing+1:]
+	}
+	if !strings.Contains(uri, placeholder) {
+		return validateURI(uri, "http", "https")
+	}
+	if err := validateURI(explorerURL(uri, strings.Repeat("9", consts.HashTrytesSize)), "http", "https"); err != nil {
+		return fmt.Errorf("URI '%s' doesn't yield valid links: %w", uri, err)
+	}
+	return nil
+}
+
+// validateURI checks that the given URI is absolute and uses one of the given schemes.
+func validateURI(uri string, schemes ...string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("unable to parse URI '%s': %w", uri, err)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme != scheme {
+			continue
+		}
+		if u.Host == "" && u.Scheme != "ipc" && u.Scheme != "inproc" {
+			return fmt.Errorf("URI '%s' has no host", uri)
+		}
+		return nil
+	}
+	return fmt.Errorf("URI '%s' doesn't use any of the schemes %v", uri, schemes)
+}
+
+// printDefaultConfig writes a sample YAML config of every option (keyed by its flag name) with its default value
+// and its usage as comment. It's generated from the flags so that it never drifts from them.
+func printDefaultConfig(w io.Writer) {
+	fmt.Fprintln(w, "# addr_monitor sample config, generated via -printDefaultConfig")
+	flag.VisitAll(func(f *flag.Flag) {
+		if configFileExcluded[f.Name] {
+			return
+		}
+		fmt.Fprintf(w, "\n# %s\n%s: %s\n", f.Usage, f.Name, yamlValue(f))
+	})
+}
+
+// yamlValue renders the default value of the given flag as YAML scalar.
+func yamlValue(f *flag.Flag) string {
+	if getter, ok := f.Value.(flag.Getter); ok {
+		switch v := getter.Get().(type) {
+		case bool, int, int64, uint, uint64, float64:
+			return fmt.Sprint(v)
+		}
+	}
+	// JSON strings are valid YAML scalars
+	quoted, _ := json.Marshal(f.DefValue)
+	return string(quoted)
+}
+
+// secretFlags are the flags whose values are redacted from the effective config, as they contain credentials
+// (webhook URIs, keys and URLs possibly carrying tokens).
+var secretFlags = map[string]bool{
+	"slackWebhookURI":      true,
+	"spendSlackWebhookURI": true,
+	"webhookURI":           true,
+	"pagerDutyRoutingKey":  true,
+	"opsgenieAPIKey":       true,
+	"addrsURL":             true,
+	"priceURL":             true,
+	"confirmURI":           true,
+	"apiToken":             true,
+	"controlToken":         true,
+}
+
+// redactedValue replaces the values of secrets which are set.
+const redactedValue = "<redacted>"
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// effectiveFlag is the value a flag took effect with and whether it was set explicitly rather than defaulted.
+type effectiveFlag struct {
+	Value interface{} `json:"value"`
+	Set   bool        `json:"set"`
+}
+
+// effectiveGroup describes a watch group as it took effect, with the sizes of its address lists
+// instead of the (possibly huge) lists themselves.
+type effectiveGroup struct {
+	Name            string   `json:"name"`
+	Addrs           int      `json:"addrs"`
+	AddrPrefixes    []string `json:"addrPrefixes"`
+	IgnoreAddrs     int      `json:"ignoreAddrs"`
+	AddrTags        int      `json:"addrTags"`
+	OnlyValue       bool     `json:"onlyValue"`
+	ZeroValueAlerts bool     `json:"zeroValueAlerts"`
+	MinValue        int64    `json:"minValue"`
+	Direction       string   `json:"direction"`
+	SlackWebhookURI string   `json:"slackWebhookURI"`
+	WebhookURI      string   `json:"webhookURI"`
+}
+
+type effectiveConfig struct {
+	Flags  map[string]effectiveFlag `json:"flags"`
+	Groups []effectiveGroup         `json:"groups"`
+}
+
+// buildEffectiveConfig returns the flags and the given initialized watch groups the monitor runs with,
+// with the secrets redacted.
+func buildEffectiveConfig(groups []*watchGroup) *effectiveConfig {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	config := &effectiveConfig{Flags: make(map[string]effectiveFlag)}
+	flag.VisitAll(func(f *flag.Flag) {
+		var value interface{} = f.Value.String()
+		if secretFlags[f.Name] {
+			value = redact(f.Value.String())
+		} else if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		config.Flags[f.Name] = effectiveFlag{Value: value, Set: set[f.Name]}
+	})
+	for _, g := range groups {
+		config.Groups = append(config.Groups, effectiveGroup{
+			Name:            g.Name,
+			Addrs:           g.matcher.exact.len(),
+			AddrPrefixes:    g.AddrPrefixes,
+			IgnoreAddrs:     len(g.matcher.ignored),
+			AddrTags:        len(g.matcher.tags),
+			OnlyValue:       g.OnlyValue,
+			ZeroValueAlerts: g.ZeroValueAlerts,
+			MinValue:        g.MinValue,
+			Direction:       g.Direction,
+			SlackWebhookURI: redact(g.SlackWebhookURI),
+			WebhookURI:      redact(g.WebhookURI),
+		})
+	}
+	return config
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configFileExcluded are the flags which can't be given in a -config file, as they're only meaningful on the command
+// line.
+var configFileExcluded = map[string]bool{"config": true, "printDefaultConfig": true, "validate": true}
+
+// configFileGroups is the option of a -config file defining watch groups, which isn't a flag.
+const configFileGroups = "groups"
+
+// configAddrFlags are the flags of address lists, whose addresses are validated when loading a -config file.
+var configAddrFlags = map[string]bool{"addrs": true, "ignoreAddrs": true, "shadowAddrs": true}
+
+// The origins of the set flags.
+const (
+	originCommandLine = "command line"
+	originConfigFile  = "config file"
+)
+
+// flagOrigins are the origins of the set flags by name, as flag.Visit doesn't tell the options applied from the
+// -config file apart from the flags given on the command line.
+type flagOrigins map[string]string
+
+// commandLineOrigins returns the origins of the flags given on the command line, before any -config file is applied.
+func commandLineOrigins() flagOrigins {
+	origins := make(flagOrigins)
+	flag.Visit(func(f *flag.Flag) {
+		origins[f.Name] = originCommandLine
+	})
+	return origins
+}
+
+// Config is the content of a -config file.
+type Config struct {
+	// the options by flag name (as printed by -printDefaultConfig) in the flags' syntax, the items of sequences
+	// joined by commas
+	Options map[string]string
+	// the watch groups in the schema of the -groupsFile, in addition to its groups
+	Groups []*watchGroup
+}
+
+// loadConfigFile reads the YAML config of the given file: the options keyed by their flag names, the values being
+// scalars or, for the comma separated lists (e.g. 'addrs'), sequences of them, and the 'groups' as a sequence of
+// watch groups. Every option is validated, the problems name the offending option.
+func loadConfigFile(path string) (*Config, []error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("unable to read config file: %w", err)}
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, []error{fmt.Errorf("unable to parse config file: %w", err)}
+	}
+
+	var problems []error
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	config := &Config{Options: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, item := range doc {
+		key, ok := item.Key.(string)
+		if !ok {
+			problemf("option '%v': not a name", item.Key)
+			continue
+		}
+		if seen[key] {
+			problemf("option '%s': given more than once", key)
+			continue
+		}
+		seen[key] = true
+		if key == configFileGroups {
+			groups, err := decodeConfigGroups(item.Value)
+			if err != nil {
+				problemf("%s", err)
+			}
+			config.Groups = groups
+			continue
+		}
+		if flag.Lookup(key) == nil || configFileExcluded[key] {
+			problemf("option '%s': unknown option", key)
+			continue
+		}
+		value, err := configValue(item.Value)
+		if err != nil {
+			problemf("option '%s': %s", key, err)
+			continue
+		}
+		if _, isDuration := durationFlags[key]; isDuration {
+			if _, err := time.ParseDuration(value); err != nil {
+				problemf("option '%s': unable to parse duration '%s': %s", key, value, err)
+				continue
+			}
+		}
+		config.Options[key] = value
+	}
+	return config, problems
+}
+
+// configValue renders the given option value in the flags' syntax: a scalar or a sequence of them joined by
+// commas.
+func configValue(value interface{}) (string, error) {
+	items, isSequence := value.([]interface{})
+	if !isSequence {
+		return configScalar(value)
+	}
+	rendered := make([]string, 0, len(items))
+	for i, item := range items {
+		scalar, err := configScalar(item)
+		if err != nil {
+			return "", fmt.Errorf("item %d: %w", i, err)
+		}
+		rendered = append(rendered, scalar)
+	}
+	return strings.Join(rendered, ","), nil
+}
+
+// configScalar renders the given scalar, null being empty.
+func configScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("only scalars and sequences of them are supported")
+}
+
+// decodeConfigGroups decodes the watch groups of the given 'groups' option, a sequence of mappings in the schema of the
+// -groupsFile.
+func decodeConfigGroups(value interface{}) ([]*watchGroup, error) {
+	items, isSequence := value.([]interface{})
+	if !isSequence {
+		return nil, fmt.Errorf("option '%s': must be a sequence of watch groups", configFileGroups)
+	}
+	groups := make([]*watchGroup, 0, len(items))
+	for i, item := range items {
+		content, err := json.Marshal(jsonValue(item))
+		if err != nil {
+			return nil, fmt.Errorf("option '%s[%d]': %s", configFileGroups, i, err)
+		}
+		var group watchGroup
+		if err := json.Unmarshal(content, &group); err != nil {
+			return nil, fmt.Errorf("option '%s[%d]': %s", configFileGroups, i, err)
+		}
+		groups = append(groups, &group)
+	}
+	if err := checkGroupNames(groups); err != nil {
+		return nil, fmt.Errorf("option '%s': %w", configFileGroups, err)
+	}
+	return groups, nil
+}
+
+// jsonValue converts the mappings of the given decoded YAML value to ones encoding/json can serialize.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = jsonValue(item.Value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = jsonValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// applyConfigFile sets the flags from the YAML config of the given file (see loadConfigFile) and returns its watch
+// groups. The flags given on the command line per the given origins take precedence over the file, the origins of the
+// flags set from the file are added to them. The addresses are validated once the file's -addressFormat applies.
+func applyConfigFile(path string, origins flagOrigins) ([]*watchGroup, []error) {
+	config, problems := loadConfigFile(path)
+	if config == nil {
+		return nil, prefixProblems("-config", problems)
+	}
+	names := make([]string, 0, len(config.Options))
+	for name := range config.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if origins[name] == originCommandLine {
+			continue
+		}
+		if err := flag.Set(name, config.Options[name]); err != nil {
+			problems = append(problems, fmt.Errorf("option '%s': invalid value: %s", name, err))
+			continue
+		}
+		origins[name] = originConfigFile
+	}
+
+	for _, name := range names {
+		if !configAddrFlags[name] || origins[name] != originConfigFile {
+			continue
+		}
+		for _, addr := range parseAddrList(config.Options[name]) {
+			if _, err := normalizeAddr(addr); err != nil {
+				problems = append(problems, fmt.Errorf("option '%s': %s", name, err))
+			}
+		}
+	}
+	for i, group := range config.Groups {
+		for _, list := range []struct {
+			field string
+			addrs []string
+		}{{"addrs", group.Addrs}, {"ignoreAddrs", group.IgnoreAddrs}} {
+			for _, addr := range list.addrs {
+				if _, err := normalizeAddr(addr); err != nil {
+					problems = append(problems, fmt.Errorf("option '%s[%d].%s': %s", configFileGroups, i, list.field, err))
+				}
+			}
+		}
+	}
+	return config.Groups, prefixProblems("-config", problems)
+}
+
+// prefixProblems prefixes the given problems by the given flag.
+func prefixProblems(flagName string, problems []error) []error {
+	prefixed := make([]error, 0, len(problems))
+	for _, problem := range problems {
+		prefixed = append(prefixed, fmt.Errorf("%s: %w", flagName, problem))
+	}
+	return prefixed
+}
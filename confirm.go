@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// confirmationTopic is the ZMQ topic on which the node publishes the txs confirmed by a milestone,
+// as 'sn <milestone index> <tx hash> <trunk> <branch> <bundle> <address>'.
+const confirmationTopic = "sn"
+
+// confirmationGate holds the alerts of matched txs until the txs are confirmed and the given number of
+// milestones (including the confirming one) passed, then releases them. Alerts of txs not confirmed
+// within the timeout are dropped.
+type confirmationGate struct {
+	minConfirmations int64
+	timeout          time.Duration
+	release          func(group *watchGroup, event *txEvent)
+	// the latest milestone index seen on the confirmation topic
+	latest    int64
+	pending   map[string]*pendingConfirmation
+	lastPrune time.Time
+}
+
+type pendingConfirmation struct {
+	alerts []heldAlert
+	// the index of the milestone which confirmed the tx, 0 while unconfirmed
+	confirmedBy int64
+	seen        time.Time
+}
+
+type heldAlert struct {
+	group *watchGroup
+	event *txEvent
+}
+
+func newConfirmationGate(minConfirmations int64, timeout time.Duration, release func(*watchGroup, *txEvent)) *confirmationGate {
+	return &confirmationGate{
+		minConfirmations: minConfirmations,
+		timeout:          timeout,
+		release:          release,
+		pending:          make(map[string]*pendingConfirmation),
+		lastPrune:        time.Now(),
+	}
+}
+
+// handles tells whether the given frame was published on the confirmation topic.
+func (g *confirmationGate) handles(frame string) bool {
+	return strings.HasPrefix(frame, confirmationTopic+" ")
+}
+
+// hold holds the alert of the given group about the given tx until the tx has enough confirmations.
+func (g *confirmationGate) hold(group *watchGroup, event *txEvent, now time.Time) {
+	g.pruneIfDue(now)
+	pending, has := g.pending[event.Hash]
+	if !has {
+		pending = &pendingConfirmation{seen: now}
+		g.pending[event.Hash] = pending
+		pendingConfirmations.Set(int64(len(g.pending)))
+	}
+	pending.alerts = append(pending.alerts, heldAlert{group: group, event: event})
+	logEvent(levelInfo, logFields{Event: "alert_held", TxHash: event.Hash, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "holding alert for tx %s on monitored address %s (group %s) until it has %d confirmation(s)", event.Hash, event.Address, group.Name, g.minConfirmations)
+}
+
+// observe records the confirmation of the given frame published on the confirmation topic,
+// releasing the held alerts which got enough confirmations.
+func (g *confirmationGate) observe(frame string, now time.Time) error {
+	tokens := strings.Fields(frame)
+	if len(tokens) < 3 {
+		return fmt.Errorf("unable to parse confirmation from ZMQ stream: frame has %d token(s)", len(tokens))
+	}
+	index, err := strconv.ParseInt(tokens[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse confirmation from ZMQ stream: invalid milestone index '%s'", tokens[1])
+	}
+	g.pruneIfDue(now)
+
+	changed := false
+	if pending, has := g.pending[tokens[2]]; has && pending.confirmedBy == 0 {
+		pending.confirmedBy = index
+		changed = true
+	}
+	if index > g.latest {
+		g.latest = index
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	for hash, pending := range g.pending {
+		if pending.confirmedBy == 0 || g.latest-pending.confirmedBy+1 < g.minConfirmations {
+			continue
+		}
+		delete(g.pending, hash)
+		for _, alert := range pending.alerts {
+			logEvent(levelInfo, logFields{Event: "tx_confirmation", TxHash: hash, Address: alert.event.Address, Bundle: alert.event.Bundle, Group: alert.group.Name}, "tx %s on monitored address %s (group %s) confirmed by milestone %d", hash, alert.event.Address, alert.group.Name, pending.confirmedBy)
+			alert.event.ConfirmedBy = pending.confirmedBy
+			g.release(alert.group, alert.event)
+		}
+	}
+	pendingConfirmations.Set(int64(len(g.pending)))
+	return nil
+}
+
+func (g *confirmationGate) pruneIfDue(now time.Time) {
+	if now.Sub(g.lastPrune) < g.timeout/10 {
+		return
+	}
+	for hash, pending := range g.pending {
+		if now.Sub(pending.seen) <= g.timeout {
+			continue
+		}
+		delete(g.pending, hash)
+		for _, alert := range pending.alerts {
+			unconfirmedAlertsDropped.Add(1)
+			logEvent(levelWarn, logFields{Event: "alert_dropped", TxHash: hash, Address: alert.event.Address, Bundle: alert.event.Bundle, Group: alert.group.Name}, "dropped alert for tx %s on monitored address %s (group %s): not confirmed within %v", hash, alert.event.Address, alert.group.Name, g.timeout)
+		}
+	}
+	pendingConfirmations.Set(int64(len(g.pending)))
+	g.lastPrune = now
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// conflictDetector tracks the recent spends of monitored addresses to detect conflicting spends: as the reattachments
+// of a transfer share its bundle hash, a monitored address spending value in two different bundles within the window
+// hints at a double spend (or a reused address whose funds are at risk).
+type conflictDetector struct {
+	window time.Duration
+	// the recent spends by address and bundle
+	spends    map[string]map[string]*recentSpend
+	lastPrune time.Time
+}
+
+type recentSpend struct {
+	tx    string
+	value int64
+	seen  time.Time
+}
+
+// conflictEvent is the generic webhook payload of a conflicting spend alert.
+type conflictEvent struct {
+	Event   string `json:"event"`
+	Group   string `json:"group"`
+	Address string `json:"address"`
+	Tx      string `json:"tx"`
+	Bundle  string `json:"bundle"`
+	Value   int64  `json:"value"`
+	// the earlier spend of the address in another bundle
+	ConflictingTx     string    `json:"conflictingTx"`
+	ConflictingBundle string    `json:"conflictingBundle"`
+	ConflictingValue  int64     `json:"conflictingValue"`
+	ConflictingSeen   time.Time `json:"conflictingSeen"`
+	Time              time.Time `json:"time"`
+}
+
+var conflictTemplate = `monitoring: *CONFLICTING SPEND* of monitored address %s
+- spent %d in bundle %s (tx %s)
+- after spending %d in bundle %s (tx %s) at %s
+`
+
+func newConflictDetector(window time.Duration) *conflictDetector {
+	return &conflictDetector{window: window, spends: make(map[string]map[string]*recentSpend), lastPrune: time.Now()}
+}
+
+// observe records the given tx spending value from a monitored address, returning the conflicting spend of the
+// address in another bundle within the window if there is one.
+func (d *conflictDetector) observe(tx *transaction.Transaction, now time.Time) *conflictEvent {
+	if now.Sub(d.lastPrune) > d.window {
+		d.prune(now)
+	}
+	bundles, has := d.spends[tx.Address]
+	if !has {
+		bundles = make(map[string]*recentSpend)
+		d.spends[tx.Address] = bundles
+	}
+	if _, has := bundles[tx.Bundle]; has {
+		// a reattachment (or another input tx) of a known spend, whose conflicts were reported already
+		return nil
+	}
+	var conflict *conflictEvent
+	for bundle, spend := range bundles {
+		if now.Sub(spend.seen) > d.window {
+			continue
+		}
+		if conflict == nil || spend.seen.After(conflict.ConflictingSeen) {
+			conflict = &conflictEvent{
+				Event: "conflicting_spend", Address: tx.Address, Tx: tx.Hash, Bundle: tx.Bundle, Value: tx.Value,
+				ConflictingTx: spend.tx, ConflictingBundle: bundle, ConflictingValue: spend.value,
+				ConflictingSeen: spend.seen, Time: alertTime(),
+			}
+		}
+	}
+	bundles[tx.Bundle] = &recentSpend{tx: tx.Hash, value: tx.Value, seen: now}
+	return conflict
+}
+
+func (d *conflictDetector) prune(now time.Time) {
+	for addr, bundles := range d.spends {
+		for bundle, spend := range bundles {
+			if now.Sub(spend.seen) > d.window {
+				delete(bundles, bundle)
+			}
+		}
+		if len(bundles) == 0 {
+			delete(d.spends, addr)
+		}
+	}
+	d.lastPrune = now
+}
+
+func conflictSeverityInput(event *conflictEvent) *severityInput {
+	return &severityInput{
+		group: event.Group, direction: "out", value: -event.Value,
+		tx: event.Tx, bundle: event.Bundle, address: event.Address, critical: true,
+	}
+}
+
+// notifyConflict sends the conflicting spend alert to the group's spend notifier and other notification targets.
+func (g *watchGroup) notifyConflict(event *conflictEvent) {
+	var notifications []notification
+	if g.spendNotifier != nil {
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address)
+		text := renderSlackText(event.Event, event, fmt.Sprintf(conflictTemplate, addrLink,
+			event.Value, explorerLink(*bundleExplorerURI, *bundleMirrorURI, event.Bundle), explorerLink(*txExplorerURI, *txMirrorURI, event.Tx),
+			event.ConflictingValue, explorerLink(*bundleExplorerURI, *bundleMirrorURI, event.ConflictingBundle), explorerLink(*txExplorerURI, *txMirrorURI, event.ConflictingTx),
+			event.ConflictingSeen.Format(time.RFC3339)))
+		color := slackColor(conflictSeverityInput(event), true)
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.spendNotifier.notifyEvent(ctx, text, color, event)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("conflicting spends of monitored address %s in bundles %s and %s (group %s)", event.Address, event.ConflictingBundle, event.Bundle, g.Name)
+			return sendPagerDutyEvent(ctx, text, conflictSeverityInput(event), event)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("conflicting spends of monitored address %s in bundles %s and %s (group %s)", event.Address, event.ConflictingBundle, event.Bundle, g.Name)
+			return sendOpsgenieAlert(ctx, text, conflictSeverityInput(event), event)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}
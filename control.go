@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// runtimeAddrs are the monitored addresses of the default group as changed at runtime via the -controlAddr API: its
+// configured (or loaded) addresses plus the added and minus the removed ones. A reload of the -addrsURL/-addrsFile
+// doesn't undo the changes, which are lost on restart. The changes are swapped atomically, so that the lookups of the
+// receive loop never wait for the API.
+type runtimeAddrs struct {
+	base addrLookup
+	// lists the addresses of the base
+	list func() []string
+	// the max. number of addresses, 0 for no limit
+	maxAddrs int
+	// holds the current addrChanges
+	changes atomic.Value
+	// serializes the changes
+	mu sync.Mutex
+}
+
+// addrChanges are the addresses added to and removed from the base of the runtimeAddrs, never modified once stored.
+type addrChanges struct {
+	added   map[string]struct{}
+	removed map[string]struct{}
+}
+
+func newRuntimeAddrs(base addrLookup, list func() []string) *runtimeAddrs {
+	r := &runtimeAddrs{base: base, list: list}
+	r.changes.Store(addrChanges{added: map[string]struct{}{}, removed: map[string]struct{}{}})
+	return r
+}
+
+func (r *runtimeAddrs) has(addr string) bool {
+	return r.changes.Load().(addrChanges).has(r.base, addr)
+}
+
+func (r *runtimeAddrs) len() int {
+	c := r.changes.Load().(addrChanges)
+	n := r.base.len()
+	for addr := range c.added {
+		if !r.base.has(addr) {
+			n++
+		}
+	}
+	for addr := range c.removed {
+		if r.base.has(addr) {
+			n--
+		}
+	}
+	return n
+}
+
+func (c addrChanges) has(base addrLookup, addr string) bool {
+	if _, removed := c.removed[addr]; removed {
+		return false
+	}
+	if _, added := c.added[addr]; added {
+		return true
+	}
+	return base.has(addr)
+}
+
+// clone copies the changes to be modified and stored anew.
+func (c addrChanges) clone() addrChanges {
+	clone := addrChanges{added: make(map[string]struct{}, len(c.added)+1), removed: make(map[string]struct{}, len(c.removed)+1)}
+	for addr := range c.added {
+		clone.added[addr] = struct{}{}
+	}
+	for addr := range c.removed {
+		clone.removed[addr] = struct{}{}
+	}
+	return clone
+}
+
+// add monitors the given normalized address, reporting whether it wasn't monitored yet.
+func (r *runtimeAddrs) add(addr string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.changes.Load().(addrChanges)
+	if current.has(r.base, addr) {
+		return false, nil
+	}
+	if r.maxAddrs > 0 && r.len() >= r.maxAddrs {
+		return false, fmt.Errorf("already monitoring %d address(es), the -maxAddresses", r.len())
+	}
+	c := current.clone()
+	delete(c.removed, addr)
+	if !r.base.has(addr) {
+		c.added[addr] = struct{}{}
+	}
+	r.changes.Store(c)
+	return true, nil
+}
+
+// remove stops monitoring the given normalized address, reporting whether it was monitored.
+func (r *runtimeAddrs) remove(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.changes.Load().(addrChanges)
+	if !current.has(r.base, addr) {
+		return false
+	}
+	c := current.clone()
+	delete(c.added, addr)
+	if r.base.has(addr) {
+		c.removed[addr] = struct{}{}
+	}
+	r.changes.Store(c)
+	return true
+}
+
+// addrs returns the sorted monitored addresses.
+func (r *runtimeAddrs) addrs() []string {
+	c := r.changes.Load().(addrChanges)
+	set := make(map[string]struct{})
+	for _, addr := range r.list() {
+		if _, removed := c.removed[addr]; !removed {
+			set[addr] = struct{}{}
+		}
+	}
+	for addr := range c.added {
+		set[addr] = struct{}{}
+	}
+	addrs := make([]string, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+type controlAddrRequest struct {
+	Address string `json:"address"`
+}
+
+type controlAddrsResponse struct {
+	Addresses []string `json:"addresses"`
+}
+
+type controlErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// controlMaxBodySize bounds the size of the bodies of the requests to the control API.
+const controlMaxBodySize = 4 << 10
+
+// isLoopbackAddr reports whether the given listen address only listens on a loopback interface, i.e. its host is
+// 'localhost' or a loopback IP. An empty host listens on all interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// writeControlJSON responds with the given status and payload as JSON.
+func writeControlJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		errorf("could not write control response: %s", err)
+	}
+}
+
+func writeControlError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeControlJSON(w, status, &controlErrorResponse{Error: fmt.Sprintf(format, args...)})
+}
+
+// serveControlAddrs lists (GET /addrs), adds (POST /addrs) or removes (DELETE /addrs/{addr}) the monitored addresses of
+// the default group, if authorized by the given token (unless empty).
+func serveControlAddrs(w http.ResponseWriter, r *http.Request, addrs *runtimeAddrs, token string) {
+	if token != "" && !authorized(r, token) {
+		writeControlError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/addrs")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeControlJSON(w, http.StatusOK, &controlAddrsResponse{Addresses: addrs.addrs()})
+	case path == "" && r.Method == http.MethodPost:
+		var req controlAddrRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, controlMaxBodySize)).Decode(&req); err != nil {
+			writeControlError(w, http.StatusBadRequest, "unable to parse address request: %s", err)
+			return
+		}
+		addr, err := normalizeAddr(req.Address)
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, "%s", err)
+			return
+		}
+		added, err := addrs.add(addr)
+		if err != nil {
+			writeControlError(w, http.StatusConflict, "%s", err)
+			return
+		}
+		status := http.StatusOK
+		if added {
+			status = http.StatusCreated
+			logEvent(levelInfo, logFields{Event: "address_added", Address: addr, Group: "default"}, "added monitored address %s via the control API", addr)
+			if *notifyAddrChanges {
+				notifyAddrsChanged("-controlAddr", []string{addr}, nil)
+			}
+		}
+		writeControlJSON(w, status, &controlAddrRequest{Address: addr})
+	case strings.HasPrefix(path, "/") && r.Method == http.MethodDelete:
+		addr, err := normalizeAddr(strings.TrimPrefix(path, "/"))
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, "%s", err)
+			return
+		}
+		if !addrs.remove(addr) {
+			writeControlError(w, http.StatusNotFound, "address '%s' isn't monitored", addr)
+			return
+		}
+		logEvent(levelInfo, logFields{Event: "address_removed", Address: addr, Group: "default"}, "removed monitored address %s via the control API", addr)
+		if *notifyAddrChanges {
+			notifyAddrsChanged("-controlAddr", nil, []string{addr})
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case path == "" || strings.HasPrefix(path, "/"):
+		writeControlError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeControlError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// startControlServer serves the API changing the given monitored addresses of the default group on the given address
+// until the context is done.
+func startControlServer(ctx context.Context, addr string, token string, addrs *runtimeAddrs) {
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		serveControlAddrs(w, r, addrs, token)
+	}
+	mux.HandleFunc("/addrs", handler)
+	mux.HandleFunc("/addrs/", handler)
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Close(); err != nil {
+			errorf("could not close control server successfully: %s", err)
+		}
+	}()
+
+	go func() {
+		infof("serving the control API on %s/addrs", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf("control server failed: %s", err)
+		}
+	}()
+}
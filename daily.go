@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// dailyFilter lets only the first alert per group and address through each calendar day in its location.
+type dailyFilter struct {
+	loc    *time.Location
+	states map[string]*dailyState
+}
+
+type dailyState struct {
+	date       string
+	suppressed int
+}
+
+// dailySummary describes the txs of which alerts were suppressed on a previous day.
+type dailySummary struct {
+	Date          string `json:"date"`
+	SuppressedTxs int    `json:"suppressedTxs"`
+}
+
+func newDailyFilter(loc *time.Location) *dailyFilter {
+	return &dailyFilter{loc: loc, states: make(map[string]*dailyState)}
+}
+
+// allow reports whether the alert for the given group and address may be sent at the given time.
+// If it may be sent and alerts were suppressed on the day the address was last alerted about,
+// the summary of the suppressed alerts is returned as well.
+func (f *dailyFilter) allow(group string, addr string, now time.Time) (bool, *dailySummary) {
+	key := group + "/" + addr
+	date := now.In(f.loc).Format("2006-01-02")
+	state, has := f.states[key]
+	if !has {
+		f.states[key] = &dailyState{date: date}
+		return true, nil
+	}
+	if state.date == date {
+		state.suppressed++
+		return false, nil
+	}
+
+	var summary *dailySummary
+	if state.suppressed > 0 {
+		summary = &dailySummary{Date: state.date, SuppressedTxs: state.suppressed}
+	}
+	state.date = date
+	state.suppressed = 0
+	return true, summary
+}
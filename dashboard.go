@@ -0,0 +1,139 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dashboardHistorySize is the number of recent matches and connection state changes shown on the dashboard.
+const dashboardHistorySize = 20
+
+// recentMatch is a tx recently matched by a watch group.
+type recentMatch struct {
+	Time    time.Time
+	Group   string
+	Tx      string
+	Address string
+	Value   int64
+}
+
+// connChange is a change of the state of the connection to a node.
+type connChange struct {
+	Time  time.Time
+	Node  string
+	State connState
+}
+
+// history keeps the most recent matches and connection state changes for the dashboard, newest first.
+var history struct {
+	sync.Mutex
+	matches     []recentMatch
+	connChanges []connChange
+}
+
+// recordRecentMatch remembers the given tx matched by the given group.
+func recordRecentMatch(group string, tx string, addr string, value int64) {
+	history.Lock()
+	defer history.Unlock()
+	match := recentMatch{Time: time.Now(), Group: group, Tx: tx, Address: addr, Value: value}
+	history.matches = append([]recentMatch{match}, history.matches...)
+	if len(history.matches) > dashboardHistorySize {
+		history.matches = history.matches[:dashboardHistorySize]
+	}
+}
+
+// recordConnChange remembers the given change of the state of the connection to the given node.
+func recordConnChange(node string, state connState) {
+	history.Lock()
+	defer history.Unlock()
+	change := connChange{Time: time.Now(), Node: node, State: state}
+	history.connChanges = append([]connChange{change}, history.connChanges...)
+	if len(history.connChanges) > dashboardHistorySize {
+		history.connChanges = history.connChanges[:dashboardHistorySize]
+	}
+}
+
+type dashboardData struct {
+	Ready       bool
+	Subscribed  []string
+	Groups      []effectiveGroup
+	Matches     []recentMatch
+	ConnChanges []connChange
+	Now         time.Time
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>addr_monitor</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+td.hash { font-family: monospace; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>addr_monitor</h1>
+<p>{{if .Ready}}Ready{{else}}Not ready{{end}}, subscribed to {{len .Subscribed}} node(s){{range .Subscribed}} <code>{{.}}</code>{{end}}
+(as of {{.Now.Format "2006-01-02 15:04:05 MST"}})</p>
+
+<h2>Watch groups</h2>
+<table>
+<tr><th>Group</th><th>Addresses</th><th>Prefixes</th><th>Ignored</th><th>Tagged</th><th>Only value txs</th><th>Slack</th><th>Webhook</th></tr>
+{{range .Groups}}<tr><td>{{.Name}}</td><td>{{.Addrs}}</td><td>{{range .AddrPrefixes}}<code>{{.}}</code> {{end}}</td><td>{{.IgnoreAddrs}}</td><td>{{.AddrTags}}</td><td>{{.OnlyValue}}</td><td>{{.SlackWebhookURI}}</td><td>{{.WebhookURI}}</td></tr>
+{{end}}</table>
+
+<h2>Recent matches</h2>
+{{if .Matches}}<table>
+<tr><th>Time</th><th>Group</th><th>Address</th><th>Tx</th><th>Value</th></tr>
+{{range .Matches}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Group}}</td><td class="hash">{{.Address}}</td><td class="hash">{{.Tx}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>{{else}}<p>No matches yet.</p>{{end}}
+
+<h2>Connection history</h2>
+{{if .ConnChanges}}<table>
+<tr><th>Time</th><th>Node</th><th>State</th></tr>
+{{range .ConnChanges}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td><code>{{.Node}}</code></td><td>{{.State}}</td></tr>
+{{end}}</table>{{else}}<p>No connection state changes yet.</p>{{end}}
+</body>
+</html>
+`))
+
+// serveDashboard renders the read-only HTML dashboard of the connection state, the given watch groups (as in the
+// effective config) and the recent matches and connection state changes.
+func serveDashboard(w http.ResponseWriter, r *http.Request, groups []*watchGroup) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data := &dashboardData{
+		Ready:  atomic.LoadInt32(&ready) == 1,
+		Groups: buildEffectiveConfig(groups).Groups,
+		Now:    time.Now(),
+	}
+	subscribedMu.Lock()
+	for node := range subscribed {
+		data.Subscribed = append(data.Subscribed, node)
+	}
+	subscribedMu.Unlock()
+	sort.Strings(data.Subscribed)
+	history.Lock()
+	data.Matches = append([]recentMatch(nil), history.matches...)
+	data.ConnChanges = append([]connChange(nil), history.connChanges...)
+	history.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		errorf("could not render dashboard: %s", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The criteria of -debounceRelated by which a tx alert is related to a held one.
+const (
+	// a tx of the same bundle (e.g. another input or output of the transfer), merged into one alert
+	debounceRelatedBundle = "bundle"
+	// a tx on the same monitored address with the opposite value (e.g. a spend returned right away), cancelling both
+	debounceRelatedReversal = "reversal"
+)
+
+// alertDebouncer holds the tx alerts of each group for a short window, so that alerts of txs resolving themselves
+// shortly after, e.g. a spend followed by the return of its value, can be cancelled or merged before they're sent.
+type alertDebouncer struct {
+	window          time.Duration
+	mergeBundles    bool
+	cancelReversals bool
+	// held in the order of their first alert
+	pending []*pendingAggregate
+}
+
+// newAlertDebouncer builds the debouncer of the given window, relating the alerts by the given criteria, which must
+// be validated beforehand.
+func newAlertDebouncer(window time.Duration, related []string) *alertDebouncer {
+	d := &alertDebouncer{window: window}
+	for _, criterion := range related {
+		switch criterion {
+		case debounceRelatedBundle:
+			d.mergeBundles = true
+		case debounceRelatedReversal:
+			d.cancelReversals = true
+		}
+	}
+	return d
+}
+
+// parseDebounceRelated parses the comma separated criteria of -debounceRelated.
+func parseDebounceRelated(str string) ([]string, error) {
+	var criteria []string
+	for _, criterion := range strings.Split(str, ",") {
+		criterion = strings.TrimSpace(criterion)
+		switch criterion {
+		case "":
+			continue
+		case debounceRelatedBundle, debounceRelatedReversal:
+			criteria = append(criteria, criterion)
+		default:
+			return nil, fmt.Errorf("unknown criterion '%s', must be '%s' or '%s'", criterion, debounceRelatedBundle, debounceRelatedReversal)
+		}
+	}
+	return criteria, nil
+}
+
+// hold holds the given tx alert of the given group. If it reverses a held alert, the held alert is removed and
+// returned instead, as neither of them is worth an alert. Alerts of the bundle of a held alert are merged into it,
+// dropping reattachments of already held txs (same address and index in the bundle).
+func (d *alertDebouncer) hold(group *watchGroup, event *txEvent, now time.Time) *txEvent {
+	if d.cancelReversals && event.Value != 0 {
+		for i, held := range d.pending {
+			if held.group != group {
+				continue
+			}
+			for j, heldEvent := range held.events {
+				if heldEvent.Address != event.Address || heldEvent.Value != -event.Value {
+					continue
+				}
+				held.events = append(held.events[:j], held.events[j+1:]...)
+				if len(held.events) == 0 {
+					d.pending = append(d.pending[:i], d.pending[i+1:]...)
+				}
+				return heldEvent
+			}
+		}
+	}
+	if d.mergeBundles {
+		for _, held := range d.pending {
+			if held.group != group || held.events[0].Bundle != event.Bundle {
+				continue
+			}
+			for _, heldEvent := range held.events {
+				if heldEvent.Address == event.Address && heldEvent.CurrentIndex == event.CurrentIndex {
+					return nil
+				}
+			}
+			held.events = append(held.events, event)
+			return nil
+		}
+	}
+	d.pending = append(d.pending, &pendingAggregate{group: group, events: []*txEvent{event}, first: now})
+	return nil
+}
+
+// due removes and returns the held alerts whose window passed.
+func (d *alertDebouncer) due(now time.Time) []*pendingAggregate {
+	var due, pending []*pendingAggregate
+	for _, held := range d.pending {
+		if now.Sub(held.first) < d.window {
+			pending = append(pending, held)
+			continue
+		}
+		due = append(due, held)
+	}
+	d.pending = pending
+	return due
+}
+
+// debounce holds the given tx alert of the given group, cancelling it along with the held alert it reverses.
+func (m *Monitor) debounce(group *watchGroup, event *txEvent) {
+	reversed := m.debouncer.hold(group, event, time.Now())
+	if reversed == nil {
+		return
+	}
+	debouncedCancelled.Add(2)
+	logEvent(levelInfo, logFields{Event: "alert_debounced", TxHash: event.Hash, Address: event.Address, Bundle: event.Bundle, Group: group.Name},
+		"cancelled the alerts for tx %s and tx %s on monitored address %s (group %s): value %d reversed within -debounceWindow", reversed.Hash, event.Hash, event.Address, group.Name, reversed.Value)
+}
+
+// flushDebounced alerts about the held alerts whose window passed, merged alerts of a bundle as its summary.
+func (m *Monitor) flushDebounced(now time.Time) {
+	for _, held := range m.debouncer.due(now) {
+		m.notifyAggregate(held)
+	}
+}
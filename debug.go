@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+)
+
+// startDebugServer serves the pprof handlers, the expvar counters, the readiness, the effective config
+// of the given watch groups, the dashboard, the addresses' activity (if kept), the tx injection (if allowed) and the address mutes (if an API token is set)
+// of the given mute list on the given address until the context is done.
+func startDebugServer(ctx context.Context, addr string, groups []*watchGroup, mutes *muteList) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		serveConfig(w, r, groups)
+	})
+	if activitySeries != nil {
+		mux.HandleFunc("/activity", serveActivity)
+	}
+	if *allowInject {
+		mux.HandleFunc("/inject", func(w http.ResponseWriter, r *http.Request) {
+			serveInject(w, r, *apiToken)
+		})
+	}
+	if *apiToken != "" {
+		mux.HandleFunc("/addresses/", func(w http.ResponseWriter, r *http.Request) {
+			serveMute(w, r, *apiToken, mutes)
+		})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		serveDashboard(w, r, groups)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Close(); err != nil {
+			errorf("could not close debug server successfully: %s", err)
+		}
+	}()
+
+	go func() {
+		infof("serving debug endpoints on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf("debug server failed: %s", err)
+		}
+	}()
+}
+
+type healthzResponse struct {
+	Ready bool `json:"ready"`
+	// 1 while subscribed to the node, 0 while not and -1 while still initializing
+	ConnectionUp int64 `json:"connectionUp"`
+}
+
+// healthz reports whether the monitor is ready, i.e. the first subscription to the node succeeded,
+// responding with 503 while it isn't.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	res := &healthzResponse{Ready: atomic.LoadInt32(&ready) == 1, ConnectionUp: connectionUp.Value()}
+	w.Header().Set("Content-Type", "application/json")
+	if !res.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		errorf("could not write healthz response: %s", err)
+	}
+}
+
+// serveConfig responds with the effective config, i.e. the flags and watch groups the monitor actually runs with,
+// with the secrets redacted.
+func serveConfig(w http.ResponseWriter, r *http.Request, groups []*watchGroup) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(buildEffectiveConfig(groups)); err != nil {
+		errorf("could not write config response: %s", err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The delivery semantics of alerts, configuring their retries, deduplication and persistence as a whole.
+const (
+	// failed sends are only retried a few times to the chat and webhook backends, and alerts are sent anyway if Redis
+	// is unreachable
+	deliveryBestEffort = "best-effort"
+	// failed sends are retried, alerts may be duplicated but are only lost if every attempt failed
+	deliveryAtLeastOnce = "at-least-once"
+	// every alert is persisted as sent before sending it, alerts may be lost but are never duplicated,
+	// not even across restarts, and are skipped if Redis is unreachable
+	deliveryAtMostOnce = "at-most-once"
+)
+
+// retries of failed sends with at-least-once delivery, and of the chat and webhook sends with best-effort delivery
+const (
+	deliveryMaxAttempts        = 5
+	deliveryBestEffortAttempts = 3
+)
+
+// the defaults of the retryPolicy
+const (
+	deliveryRetryBackoff = time.Second
+	maxRetryAfter        = time.Minute
+)
+
+// retryPolicy paces the retries of a notification's failed sends, its zero fields default to deliveryRetryBackoff
+// and maxRetryAfter.
+type retryPolicy struct {
+	// the delay of the first retry, doubling after every attempt
+	backoff time.Duration
+	// bounds the delay of a retry requested by a backend via its Retry-After header
+	maxRetryAfter time.Duration
+}
+
+// firstDelay returns the delay of the first retry.
+func (p retryPolicy) firstDelay() time.Duration {
+	if p.backoff > 0 {
+		return p.backoff
+	}
+	return deliveryRetryBackoff
+}
+
+// throttledDelay returns the delay of a retry requested via Retry-After, bounded by the policy's max.
+func (p retryPolicy) throttledDelay(retryAfter time.Duration) time.Duration {
+	max := p.maxRetryAfter
+	if max <= 0 {
+		max = maxRetryAfter
+	}
+	if retryAfter > max {
+		return max
+	}
+	return retryAfter
+}
+
+// throttledError is the error of a send rejected by the backend with 429 Too Many Requests, with the delay the
+// backend requested via its Retry-After header (0 if none).
+type throttledError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return e.err.Error()
+}
+
+func (e *throttledError) Unwrap() error {
+	return e.err
+}
+
+// responseError returns the given error of the given rejected response, as a throttledError if it's a 429.
+func responseError(res *http.Response, err error) error {
+	if res.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+	return &throttledError{err: err, retryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now())}
+}
+
+// parseRetryAfter parses the given Retry-After header of either delay seconds or an HTTP date into the delay from
+// the given time, which the retryPolicy bounds. Returns 0 for a missing or invalid header.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		delay = date.Sub(now)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// sentLog is an append-only file of the IDs of sent alerts, one per line. At most maxEntries IDs are remembered,
+// the oldest ones are forgotten first and dropped from the file once it holds twice as many.
+type sentLog struct {
+	mu         sync.Mutex
+	path       string
+	f          *os.File
+	maxEntries int
+	sent       map[string]struct{}
+	// the remembered IDs in the order they were sent
+	order []string
+	// the number of IDs in the file
+	lines int
+}
+
+// openSentLog loads the IDs of the alerts sent so far from the given file, which doesn't need to exist yet,
+// and opens it for appending.
+func openSentLog(path string, maxEntries int) (*sentLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open delivery state file: %w", err)
+	}
+	l := &sentLog{path: path, f: f, maxEntries: maxEntries, sent: make(map[string]struct{})}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l.remember(scanner.Text())
+		l.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read delivery state file: %w", err)
+	}
+	if err := l.compactIfNeeded(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// markSent persists the given alert ID as sent and reports whether it wasn't sent before.
+// An alert which couldn't be persisted is reported as sent, so that it's never sent twice.
+func (l *sentLog) markSent(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, sent := l.sent[id]; sent {
+		return false
+	}
+	if _, err := l.f.WriteString(id + "\n"); err != nil {
+		errorf("could not persist alert %s as sent, skipping it: %s", id, err)
+		return false
+	}
+	if err := l.f.Sync(); err != nil {
+		errorf("could not persist alert %s as sent, skipping it: %s", id, err)
+		return false
+	}
+	l.lines++
+	l.remember(id)
+	if err := l.compactIfNeeded(); err != nil {
+		errorf("could not compact delivery state file: %s", err)
+	}
+	return true
+}
+
+func (l *sentLog) remember(id string) {
+	if _, sent := l.sent[id]; sent {
+		return
+	}
+	if len(l.order) >= l.maxEntries {
+		delete(l.sent, l.order[0])
+		l.order = l.order[1:]
+	}
+	l.sent[id] = struct{}{}
+	l.order = append(l.order, id)
+	sentAlertsCacheEntries.Set(int64(len(l.order)))
+}
+
+// compactIfNeeded rewrites the file with only the remembered IDs once it holds twice as many IDs,
+// via a temporary file which then replaces it.
+func (l *sentLog) compactIfNeeded() error {
+	if l.lines < 2*l.maxEntries {
+		return nil
+	}
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create delivery state file: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, id := range l.order {
+		w.WriteString(id + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write delivery state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write delivery state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write delivery state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("unable to replace delivery state file: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to reopen delivery state file: %w", err)
+	}
+	l.f.Close()
+	l.f = f
+	l.lines = len(l.order)
+	return nil
+}
+
+func (l *sentLog) Close() error {
+	return l.f.Close()
+}
+
+// claimAlert reports whether the alert with the given ID should be sent by this replica, marking it as sent
+// with at-most-once delivery and claiming it across replicas if deduplication via Redis is enabled.
+func (m *Monitor) claimAlert(id string) bool {
+	if m.recentAlerts != nil && !m.recentAlerts.add(id, time.Now()) {
+		duplicateAlerts.Add(1)
+		infof("skipped alert %s: already sent within the -dedupWindow", id)
+		return false
+	}
+	if m.sentAlerts != nil && !m.sentAlerts.markSent(id) {
+		infof("skipped alert %s: already sent", id)
+		return false
+	}
+	if m.dedup != nil && !m.dedup.claim(id) {
+		infof("skipped alert %s: already sent by another replica", id)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// DiscordNotifier posts the alerts as msgs to a Discord webhook, with the explorer links as markdown links.
+type DiscordNotifier struct {
+	URI string
+}
+
+// Notify posts the chat msg of the given tx alert, as an embed colored by its severity with -slackColors.
+func (n *DiscordNotifier) Notify(ctx context.Context, tx *transaction.Transaction) error {
+	event := txEventOf(ctx, tx)
+	return n.notifyEvent(ctx, slackTxText(event), slackTxColor(event), event)
+}
+
+const (
+	// the max. length of the content of a Discord msg, longer msgs are truncated
+	discordMaxContentLength = 2000
+	// the msg flag suppressing the previews Discord would render for the explorer links
+	discordSuppressEmbeds = 1 << 2
+)
+
+type discordWebhookPayload struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+	Flags   int            `json:"flags,omitempty"`
+}
+
+type discordEmbed struct {
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+}
+
+// discordColors are the embed colors of the Slack attachment colors, matching the color bars of Slack.
+var discordColors = map[string]int{"good": 0x2eb886, "warning": 0xdaa038, "danger": 0xa30200}
+
+// slackLinkPattern matches the '<url|text>' links of Slack's markup.
+var slackLinkPattern = regexp.MustCompile(`<([^<>|\s]+)\|([^<>]*)>`)
+
+// discordMarkdown converts the links of the given Slack msg to Discord's markdown, '<url|text>' to '[text](url)'.
+func discordMarkdown(text string) string {
+	return slackLinkPattern.ReplaceAllString(text, "[$2]($1)")
+}
+
+// notifyEvent posts the given chat msg with the given Slack attachment color (none if empty) to the Discord webhook,
+// as an embed whose color reflects the alert's severity if colored.
+func (n *DiscordNotifier) notifyEvent(ctx context.Context, text string, color string, _ interface{}) error {
+	maxLength := *maxMsgLength
+	if maxLength > discordMaxContentLength {
+		maxLength = discordMaxContentLength
+	}
+	text = truncateMsg(discordMarkdown(text), instanceFooter(), maxLength)
+	payload := &discordWebhookPayload{Content: text, Flags: discordSuppressEmbeds}
+	if color != "" {
+		payload = &discordWebhookPayload{Embeds: []discordEmbed{{Description: text, Color: discordColors[color]}}}
+	}
+	jsonWebHookPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialize discord webhook payload: %w", err)
+	}
+	if slackLimiter != nil {
+		if err := slackLimiter.wait(ctx); err != nil {
+			return fmt.Errorf("unable to POST discord webhook payload within the rate limit: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URI, bytes.NewReader(jsonWebHookPayload))
+	if err != nil {
+		return fmt.Errorf("unable to build discord webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST discord webhook payload: %w", err)
+	}
+	defer closeResponse(res)
+	// 204 unless the msg is requested back via ?wait=true
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from POSTing discord webhook payload: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to POST discord webhook payload: %s", bodyContent))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// downtimeTracker detects a flapping node connection by summing up the durations of the outages of each stream
+// within a sliding window, which catches frequent short outages that individual reconnects don't reveal.
+type downtimeTracker struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	window    time.Duration
+	// the outages by the nodes of the stream, in order
+	outages map[string][]outage
+	// the nodes of the streams currently alerted about
+	alerted map[string]bool
+}
+
+type outage struct {
+	start time.Time
+	// zero while the outage lasts
+	end time.Time
+}
+
+// downtimeEvent is the generic webhook payload of a cumulative downtime alert.
+type downtimeEvent struct {
+	Event     string    `json:"event"`
+	Node      string    `json:"node"`
+	Downtime  string    `json:"downtime"`
+	Threshold string    `json:"threshold"`
+	Window    string    `json:"window"`
+	Time      time.Time `json:"time"`
+}
+
+var downtimeTemplate = `monitoring:
+- connection to node %s was down for %v in total within the last %v (threshold %v)
+`
+
+func newDowntimeTracker(threshold time.Duration, window time.Duration) *downtimeTracker {
+	return &downtimeTracker{threshold: threshold, window: window, outages: make(map[string][]outage), alerted: make(map[string]bool)}
+}
+
+// down records the start of an outage of the connection to the given node(s).
+func (t *downtimeTracker) down(node string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	outages := t.outages[node]
+	if len(outages) > 0 && outages[len(outages)-1].end.IsZero() {
+		return
+	}
+	t.outages[node] = append(outages, outage{start: now})
+}
+
+// up records the end of the outage of the connection to the given node(s), if any.
+func (t *downtimeTracker) up(node string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if outages := t.outages[node]; len(outages) > 0 && outages[len(outages)-1].end.IsZero() {
+		outages[len(outages)-1].end = now
+	}
+}
+
+// downtime returns the cumulative duration of the outages of the given node(s) within the window ending at the
+// given time, forgetting the outages which ended before the window. The caller must hold the mutex.
+func (t *downtimeTracker) downtime(node string, now time.Time) time.Duration {
+	since := now.Add(-t.window)
+	outages := t.outages[node]
+	for len(outages) > 0 && !outages[0].end.IsZero() && outages[0].end.Before(since) {
+		outages = outages[1:]
+	}
+	t.outages[node] = outages
+
+	var total time.Duration
+	for _, o := range outages {
+		start, end := o.start, o.end
+		if start.Before(since) {
+			start = since
+		}
+		if end.IsZero() {
+			end = now
+		}
+		total += end.Sub(start)
+	}
+	return total
+}
+
+// check returns the downtime alerts to send for the streams whose cumulative downtime within the window exceeds
+// the threshold. Further alerts are only returned for a stream after its downtime fell below the threshold again.
+func (t *downtimeTracker) check(now time.Time) []*downtimeEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []*downtimeEvent
+	for node := range t.outages {
+		downtime := t.downtime(node, now)
+		exceeded := downtime > t.threshold
+		switch {
+		case exceeded && !t.alerted[node]:
+			t.alerted[node] = true
+			events = append(events, &downtimeEvent{
+				Event: "downtime", Node: node, Downtime: downtime.Round(time.Millisecond).String(),
+				Threshold: t.threshold.String(), Window: t.window.String(), Time: alertTime(),
+			})
+		case !exceeded && t.alerted[node]:
+			delete(t.alerted, node)
+			infof("cumulative downtime of the connection to node %s is below %v again", node, t.threshold)
+		}
+	}
+	return events
+}
+
+// watch checks the cumulative downtimes periodically and sends the downtime alerts, until the given context is done.
+// Checking periodically rather than on connection state changes catches ongoing outages as well.
+func (t *downtimeTracker) watch(ctx context.Context) {
+	interval := t.threshold / 4
+	if interval > 15*time.Second {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, event := range t.check(time.Now()) {
+			warnf("connection to node %s was down for %s in total within the last %v", event.Node, event.Downtime, t.window)
+			notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(downtimeTemplate, event.Node, event.Downtime, t.window, t.threshold)), event)
+		}
+	}
+}
+
+// downtimes tracks the outages of the node connections, nil if downtime alerts are disabled.
+var downtimes *downtimeTracker
+
+// recordOutage records the start (down) or end (!down) of an outage of the connection to the given node(s)
+// with the downtime tracker, if enabled.
+func recordOutage(node string, down bool) {
+	if downtimes == nil {
+		return
+	}
+	if down {
+		downtimes.down(node, time.Now())
+		return
+	}
+	downtimes.up(node, time.Now())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// txEvent is the generic webhook payload of a matched tx.
+type txEvent struct {
+	*transaction.Transaction
+	Group string `json:"group"`
+	// when the tx was received, NTP corrected if the clock was checked
+	ReceivedAt time.Time `json:"receivedAt"`
+	DecodedTag string    `json:"decodedTag,omitempty"`
+	RawTrytes  string    `json:"rawTrytes,omitempty"`
+	// the node the tx was received from, if receiving from multiple nodes at once
+	Node       string   `json:"node,omitempty"`
+	Suspicious []string `json:"suspicious,omitempty"`
+	// the decoded obsolete tag with -matchObsoleteTag, if it differs from the tag
+	DecodedObsoleteTag string `json:"decodedObsoleteTag,omitempty"`
+	// the match of the decoded tag against the group's tag pattern and its captured groups, if matching
+	TagMatch map[string]string `json:"tagMatch,omitempty"`
+	// the URL of the QR code of the address, if a QR code service is configured
+	QRCodeURL string `json:"qrCodeURL,omitempty"`
+	// the estimated fiat value of the tx's value, if a price is available
+	FiatValue *float64 `json:"fiatValue,omitempty"`
+	// the typical value of the address the tx's value exceeded by the -baselineMultiple, if established
+	Baseline float64 `json:"baseline,omitempty"`
+	// the index of the milestone which confirmed the tx, if alerts are held until confirmation
+	ConfirmedBy int64 `json:"confirmedBy,omitempty"`
+	// set in daily first only mode if alerts were suppressed on the day the address was last alerted about
+	PreviousDay *dailySummary `json:"previousDay,omitempty"`
+}
+
+// newTxEvent builds the event of the given tx matched by the given group, which was parsed from the given frame.
+func newTxEvent(group *watchGroup, tx *transaction.Transaction, frame string) *txEvent {
+	event := &txEvent{Transaction: tx, Group: group.Name, ReceivedAt: alertTime()}
+	if *decodeTags {
+		event.DecodedTag = displayTag(tx.Tag)
+	}
+	if *includeRawTrytes {
+		event.RawTrytes = splitFrame(frame)[0]
+	}
+	if *matchObsoleteTag && tx.ObsoleteTag != tx.Tag {
+		event.DecodedObsoleteTag = displayTag(tx.ObsoleteTag)
+	}
+	event.TagMatch = group.matcher.tagCaptures(tx.Tag)
+	if event.TagMatch == nil && event.DecodedObsoleteTag != "" {
+		event.TagMatch = group.matcher.tagCaptures(tx.ObsoleteTag)
+	}
+	if *qrCodeURI != "" {
+		event.QRCodeURL = qrCodeURL(tx.Address)
+	}
+	if tx.Value != 0 {
+		event.FiatValue = fiatValueOf(tx.Value)
+	}
+	return event
+}
+
+// connState is a state of the connection to the ZMQ stream.
+type connState string
+
+const (
+	connStateConnected    connState = "connected"
+	connStateDisconnected connState = "disconnected"
+	connStateReconnecting connState = "reconnecting"
+	connStateSubscribed   connState = "subscribed"
+)
+
+// connectionEvent is the generic webhook payload describing a connection state change.
+type connectionEvent struct {
+	Event string    `json:"event"`
+	State connState `json:"state"`
+	Node  string    `json:"node"`
+	Time  time.Time `json:"time"`
+}
+
+var connectionEventTemplate = `monitoring:
+- connection to node %s is %s
+`
+
+// notifyConnectionEvent records the given connection state change of the connection to the given node and sends it
+// through the configured notification backends, if connection event notifications are enabled.
+func notifyConnectionEvent(node string, state connState) {
+	recordConnectionState(node, state)
+	if !*notifyConnEvents {
+		return
+	}
+	event := &connectionEvent{Event: "connection", State: state, Node: node, Time: alertTime()}
+	notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(connectionEventTemplate, node, state)), event)
+}
+
+// notifyOperators sends an event about the monitor itself (rather than a matched tx) through the notification
+// backends configured via flags: the given text to the notifier of the -slackWebhookURI and the given payload to the
+// generic webhook.
+func notifyOperators(text string, payload interface{}) {
+	var notifications []notification
+	if chat := newNotifier(*notifier, *slackWebhookURI, *webhookURI); chat != nil {
+		var color string
+		if *slackColors {
+			color = slackSeverityColors["warning"]
+		}
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return chat.notifyEvent(ctx, text, color, payload)
+		}))
+	}
+	if *webhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, *webhookURI, payload)
+		}))
+	}
+	fanOut(payload, notifications)
+}
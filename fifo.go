@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// fifoWriter writes alerts as JSON lines to a named pipe read by a co-located process. The pipe is opened without
+// blocking, so that alerts are dropped instead of stalling the pipeline while no process reads it.
+type fifoWriter struct {
+	path    string
+	timeout time.Duration
+	mu      sync.Mutex
+	f       *os.File
+	// whether the last attempt to write found no process reading the pipe
+	readerMissing bool
+}
+
+// fifoSink is the writer of alerts to the named pipe, nil if disabled.
+var fifoSink *fifoWriter
+
+func newFIFOWriter(path string, timeout time.Duration) *fifoWriter {
+	return &fifoWriter{path: path, timeout: timeout}
+}
+
+// write writes the given payload as a single JSON line to the pipe, opening it if not open yet. The payload is
+// dropped if no process reads the pipe. A write not completing within the timeout (e.g. as the reader stalls)
+// closes the pipe, so that the reader doesn't resume in the middle of the torn line.
+func (w *fifoWriter) write(payload interface{}) error {
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialize FIFO payload: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		f, err := os.OpenFile(w.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if errors.Is(err, syscall.ENXIO) {
+			w.dropWithoutReader()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to open FIFO: %w", err)
+		}
+		if w.readerMissing {
+			infof("a process reads the FIFO %s again, resuming writing the alerts to it", w.path)
+			w.readerMissing = false
+		}
+		w.f = f
+	}
+	err = w.f.SetWriteDeadline(time.Now().Add(w.timeout))
+	if err == nil {
+		_, err = w.f.Write(line)
+	}
+	if err == nil {
+		return nil
+	}
+	w.f.Close()
+	w.f = nil
+	if errors.Is(err, syscall.EPIPE) {
+		w.dropWithoutReader()
+		return nil
+	}
+	fifoEventsDropped.Add(1)
+	return fmt.Errorf("unable to write JSON line to FIFO: %w", err)
+}
+
+// dropWithoutReader counts an alert dropped as no process reads the pipe, warning about the first one.
+func (w *fifoWriter) dropWithoutReader() {
+	fifoEventsDropped.Add(1)
+	if !w.readerMissing {
+		warnf("warning: no process reads the FIFO %s, dropping the alerts until one does", w.path)
+		w.readerMissing = true
+	}
+}
+
+// Close closes the pipe, if open.
+func (w *fifoWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// firstSeenStore keeps track of the monitored addresses which have been seen on the network, persisted so that it
+// survives restarts. Every newly seen address is appended as a line to the file, which is compacted on load should
+// it hold anything but distinct addresses, e.g. the line of an append torn by a crash or the JSON array of a store of
+// an older release.
+type firstSeenStore struct {
+	path string
+	seen map[string]struct{}
+	// the store's file opened for appending, nil if the store is only kept in memory
+	f *os.File
+}
+
+// loadFirstSeenStore loads the store from the given file, which doesn't need to exist yet. With inMemory (e.g. for
+// replays), the file is only read, the newly seen addresses are kept in memory.
+func loadFirstSeenStore(path string, inMemory bool) (*firstSeenStore, error) {
+	s := &firstSeenStore{path: path, seen: make(map[string]struct{})}
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read first seen file: %w", err)
+	}
+	compact := false
+	if trimmed := bytes.TrimSpace(content); bytes.HasPrefix(trimmed, []byte("[")) {
+		var addrs []string
+		if err := json.Unmarshal(trimmed, &addrs); err != nil {
+			return nil, fmt.Errorf("unable to parse first seen file: %w", err)
+		}
+		s.seen = addrSet(addrs)
+		compact = true
+	} else {
+		lines := strings.Split(string(content), "\n")
+		// the last line is empty unless the last append was torn
+		compact = lines[len(lines)-1] != ""
+		for _, line := range lines[:len(lines)-1] {
+			if _, seen := s.seen[line]; seen || len(line) != consts.HashTrytesSize {
+				compact = true
+				continue
+			}
+			s.seen[line] = struct{}{}
+		}
+	}
+	if inMemory {
+		return s, nil
+	}
+	if compact {
+		if err := s.compact(); err != nil {
+			return nil, fmt.Errorf("unable to compact first seen file: %w", err)
+		}
+	}
+	if s.f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+		return nil, fmt.Errorf("unable to open first seen file: %w", err)
+	}
+	return s, nil
+}
+
+// markSeen marks the given address as seen and reports whether this is the first time it has been seen.
+func (s *firstSeenStore) markSeen(addr string) bool {
+	if _, seen := s.seen[addr]; seen {
+		return false
+	}
+	s.seen[addr] = struct{}{}
+	if s.f == nil {
+		return true
+	}
+	if _, err := s.f.WriteString(addr + "\n"); err != nil {
+		errorf("could not persist first seen address %s: %s", addr, err)
+	}
+	return true
+}
+
+// compact writes the seen addresses to a temporary file which then replaces the store's file.
+func (s *firstSeenStore) compact() error {
+	addrs := make([]string, 0, len(s.seen))
+	for addr := range s.seen {
+		addrs = append(addrs, addr+"\n")
+	}
+	sort.Strings(addrs)
+	tmpPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, []byte(strings.Join(addrs, "")), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// firstActivityEvent is the generic webhook payload of the first tx ever seen on a monitored address.
+type firstActivityEvent struct {
+	Event   string    `json:"event"`
+	Group   string    `json:"group"`
+	Address string    `json:"address"`
+	Tx      string    `json:"tx"`
+	Bundle  string    `json:"bundle"`
+	Value   int64     `json:"value"`
+	Time    time.Time `json:"time"`
+}
+
+var firstActivityTemplate = `monitoring:
+- first activity ever on address %s
+- tx %s transferring %d
+`
+
+func newFirstActivityEvent(group *watchGroup, tx *transaction.Transaction) *firstActivityEvent {
+	return &firstActivityEvent{
+		Event: "firstActivity", Group: group.Name, Address: tx.Address,
+		Tx: tx.Hash, Bundle: tx.Bundle, Value: tx.Value, Time: alertTime(),
+	}
+}
+
+// notifyFirstActivity sends the first activity alert to the group's notification targets.
+func (g *watchGroup) notifyFirstActivity(event *firstActivityEvent) {
+	var notifications []notification
+	if g.notifier != nil {
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address)
+		txLink := explorerLink(*txExplorerURI, *txMirrorURI, event.Tx)
+		text := renderSlackText(event.Event, event, fmt.Sprintf(firstActivityTemplate, addrLink, txLink, event.Value))
+		direction, value := valueDirection(event.Value)
+		color := slackColor(&severityInput{
+			group: event.Group, direction: direction, value: value,
+			tx: event.Tx, bundle: event.Bundle, address: event.Address,
+		}, false)
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.notifier.notifyEvent(ctx, text, color, event)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}
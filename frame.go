@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/guards"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// errHashMismatch is returned by extractTransaction if hash verification is enabled
+// and the hash carried by the frame doesn't match the hash of its trytes.
+var errHashMismatch = errors.New("tx hash doesn't match its trytes")
+
+// errMalformedFrame is returned by extractTransaction if the frame doesn't have the expected structure,
+// e.g. because it was cut short. The errors of the specific structural problems wrap it.
+var errMalformedFrame = errors.New("malformed frame")
+
+// The errors returned by extractTransaction, besides errHashMismatch.
+var (
+	// the frame carries no trytes at all
+	errEmptyFrame = fmt.Errorf("%w: empty frame", errMalformedFrame)
+	// a frame of the 'trytes' topic lacks the hash following the trytes
+	errMissingHash = fmt.Errorf("%w: missing hash token", errMalformedFrame)
+	// the frame's trytes or hash aren't complete trytes, or there are surplus tokens
+	errInvalidTrytes = fmt.Errorf("%w: invalid trytes", errMalformedFrame)
+	// iota.go couldn't parse the tx from the well-formed trytes
+	errTxParse = errors.New("unable to parse tx")
+	// the frame doesn't carry a valid JSON tx object with -frameFormat json
+	errInvalidJSON = fmt.Errorf("%w: invalid JSON tx", errMalformedFrame)
+)
+
+// jsonTx is a tx as re-emitted by gateways with -frameFormat json.
+type jsonTx struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
+	Hash    string `json:"hash"`
+	Bundle  string `json:"bundle"`
+	Tag     string `json:"tag"`
+	// unix seconds
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// extractTransaction parses a frame of either the 'trytes <trytes> <hash>' or the
+// 'tx_trytes <trytes>' layout. If the frame doesn't carry the hash, it is computed from the trytes.
+// With -frameFormat json, the frame carries a JSON tx object instead.
+func extractTransaction(trytesTopicFrame string) (*transaction.Transaction, error) {
+	if *frameFormat == frameFormatJSON {
+		return extractJSONTransaction(trytesTopicFrame)
+	}
+	frameSplit := selectHashToken(splitFrame(trytesTopicFrame), *hashTokenIndex)
+	if err := checkFrameTokens(frameSplit, strings.HasPrefix(trytesTopicFrame, trytesSubTopic+" ")); err != nil {
+		return nil, err
+	}
+	tx, err := transaction.AsTransactionObject(frameSplit[0], frameSplit[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errTxParse, err)
+	}
+	if len(frameSplit) == 2 && *verifyTxHashes {
+		if computed := transaction.TransactionHash(tx); computed != tx.Hash {
+			return nil, fmt.Errorf("%w: frame carries %s, trytes hash to %s", errHashMismatch, tx.Hash, computed)
+		}
+	}
+	return tx, nil
+}
+
+// splitFrame strips the topic and any trailing whitespace (e.g. as appended by line based relays) from the given
+// frame and splits it into its tokens.
+func splitFrame(trytesTopicFrame string) []string {
+	switch {
+	case strings.HasPrefix(trytesTopicFrame, txTrytesSubTopic+" "):
+		trytesTopicFrame = strings.TrimPrefix(trytesTopicFrame, txTrytesSubTopic+" ")
+	default:
+		trytesTopicFrame = strings.TrimPrefix(trytesTopicFrame, trytesSubTopic+" ")
+	}
+	return strings.Split(strings.TrimRight(trytesTopicFrame, " \t\r\n"), " ")
+}
+
+// selectHashToken reduces the given frame tokens to the trytes and the hash token at the given index, for publishers
+// appending metadata tokens to the frames. Index 0 selects the first token after the trytes which is a complete hash,
+// index 1 (the hash following the trytes) keeps the tokens as they are. Frames lacking the hash token are reduced
+// to the trytes.
+func selectHashToken(frameSplit []string, index int) []string {
+	switch {
+	case index == 1 || len(frameSplit) < 2:
+		return frameSplit
+	case index == 0:
+		for _, token := range frameSplit[1:] {
+			if guards.IsTrytesOfExactLength(token, consts.HashTrytesSize) {
+				return []string{frameSplit[0], token}
+			}
+		}
+		return frameSplit[:1]
+	case index < len(frameSplit):
+		return []string{frameSplit[0], frameSplit[index]}
+	}
+	return frameSplit[:1]
+}
+
+// checkFrameTokens checks that the given frame tokens consist of the complete trytes of a tx,
+// followed by a complete hash if the frame is of the 'trytes' topic and optionally otherwise.
+func checkFrameTokens(frameSplit []string, trytesTopic bool) error {
+	if frameSplit[0] == "" {
+		return errEmptyFrame
+	}
+	if len(frameSplit) > 2 {
+		return fmt.Errorf("%w: expected at most 2 tokens but got %d", errInvalidTrytes, len(frameSplit))
+	}
+	if len(frameSplit[0]) != consts.TransactionTrytesSize || !guards.IsTrytes(frameSplit[0]) {
+		return fmt.Errorf("%w: expected %d tx trytes but got %d chars", errInvalidTrytes, consts.TransactionTrytesSize, len(frameSplit[0]))
+	}
+	if len(frameSplit) == 1 {
+		if trytesTopic {
+			return errMissingHash
+		}
+		return nil
+	}
+	if frameSplit[1] == "" {
+		return errMissingHash
+	}
+	if len(frameSplit[1]) != consts.HashTrytesSize || !guards.IsTrytes(frameSplit[1]) {
+		return fmt.Errorf("%w: expected a hash of %d trytes but got %d chars", errInvalidTrytes, consts.HashTrytesSize, len(frameSplit[1]))
+	}
+	return nil
+}
+
+// extractJSONTransaction parses a frame of the '<topic> <JSON tx object>' layout. The address, hash and bundle must
+// be complete trytes (the address optionally with its checksum), the tag is padded to its full length.
+// The fields not carried by the object are left zero.
+func extractJSONTransaction(frame string) (*transaction.Transaction, error) {
+	if i := strings.IndexByte(frame, '{'); i >= 0 {
+		frame = frame[i:]
+	} else {
+		return nil, errEmptyFrame
+	}
+	var obj jsonTx
+	if err := json.Unmarshal([]byte(frame), &obj); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidJSON, err)
+	}
+	if len(obj.Address) == consts.AddressWithChecksumTrytesSize {
+		obj.Address = obj.Address[:consts.HashTrytesSize]
+	}
+	for _, field := range []struct{ name, trytes string }{{"address", obj.Address}, {"hash", obj.Hash}, {"bundle", obj.Bundle}} {
+		if !guards.IsTrytesOfExactLength(field.trytes, consts.HashTrytesSize) {
+			return nil, fmt.Errorf("%w: expected %d trytes for the %s but got %d chars", errInvalidJSON, consts.HashTrytesSize, field.name, len(field.trytes))
+		}
+	}
+	tagSize := consts.TagTrinarySize / 3
+	if len(obj.Tag) > tagSize || obj.Tag != "" && !guards.IsTrytes(obj.Tag) {
+		return nil, fmt.Errorf("%w: expected a tag of at most %d trytes but got %d chars", errInvalidJSON, tagSize, len(obj.Tag))
+	}
+	tag := obj.Tag + strings.Repeat("9", tagSize-len(obj.Tag))
+	return &transaction.Transaction{
+		Hash:        obj.Hash,
+		Address:     obj.Address,
+		Value:       obj.Value,
+		ObsoleteTag: tag,
+		Timestamp:   obj.Timestamp,
+		Bundle:      obj.Bundle,
+		Tag:         tag,
+	}, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+)
+
+func TestExtractTransactionShortFrames(t *testing.T) {
+	trytes := strings.Repeat("9", consts.TransactionTrytesSize)
+	hash := strings.Repeat("9", consts.HashTrytesSize)
+
+	if _, err := extractTransaction("trytes " + trytes + " " + hash); err != nil {
+		t.Fatalf("complete frame: unexpected error: %s", err)
+	}
+
+	for name, frame := range map[string]string{
+		"empty":           "",
+		"topic only":      "trytes ",
+		"short trytes":    "trytes " + trytes[:consts.TransactionTrytesSize/2],
+		"short hash":      "trytes " + trytes + " " + hash[:40],
+		"empty hash":      "trytes " + trytes + " ",
+		"extra token":     "trytes " + trytes + " " + hash + " " + hash,
+		"short tx_trytes": "tx_trytes " + trytes[:100],
+	} {
+		if _, err := extractTransaction(frame); !errors.Is(err, errMalformedFrame) {
+			t.Errorf("%s: expected errMalformedFrame but got %v", name, err)
+		}
+	}
+}
+
+func TestExtractTransactionTrailingWhitespace(t *testing.T) {
+	trytes := strings.Repeat("9", consts.TransactionTrytesSize)
+	hash := strings.Repeat("9", consts.HashTrytesSize)
+
+	for _, tt := range []struct {
+		name     string
+		frame    string
+		expected error
+	}{
+		{"trailing space", "trytes " + trytes + " " + hash + " ", nil},
+		{"trailing spaces", "trytes " + trytes + " " + hash + "   ", nil},
+		{"trailing newline", "trytes " + trytes + " " + hash + "\r\n", nil},
+		{"tx_trytes trailing space", "tx_trytes " + trytes + " ", nil},
+		{"trailing spaces only", "trytes    ", errEmptyFrame},
+		{"hash missing before trailing spaces", "trytes " + trytes + "  ", errMissingHash},
+		{"inner double space", "trytes " + trytes + "  " + hash, errInvalidTrytes},
+	} {
+		if _, err := extractTransaction(tt.frame); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestProcessFrameCountsMalformedFrames(t *testing.T) {
+	before := malformedFrames.Value()
+	m := &Monitor{}
+	if err := m.ProcessFrame("trytes " + strings.Repeat("9", 100)); err == nil {
+		t.Fatal("expected an error for a short frame")
+	}
+	if got := malformedFrames.Value() - before; got != 1 {
+		t.Errorf("expected malformed_frames to be incremented by 1 but got %d", got)
+	}
+}
+
+func TestExtractTransactionErrorKinds(t *testing.T) {
+	trytes := strings.Repeat("9", consts.TransactionTrytesSize)
+	hash := strings.Repeat("9", consts.HashTrytesSize)
+
+	for frame, expected := range map[string]error{
+		"trytes ":                                     errEmptyFrame,
+		"trytes " + trytes:                            errMissingHash,
+		"trytes " + trytes + " ":                      errMissingHash,
+		"trytes " + trytes[1:] + "a " + hash:          errInvalidTrytes,
+		"trytes " + trytes + " " + hash[1:] + "!":     errInvalidTrytes,
+		"tx_trytes " + trytes[:consts.HashTrytesSize]: errInvalidTrytes,
+	} {
+		_, err := extractTransaction(frame)
+		if !errors.Is(err, expected) {
+			t.Errorf("frame of %d chars: expected %v but got %v", len(frame), expected, err)
+		}
+		if got, want := parseErrorKind(err), parseErrorKind(expected); got != want {
+			t.Errorf("frame of %d chars: expected kind %s but got %s", len(frame), want, got)
+		}
+	}
+
+	if _, err := extractTransaction("tx_trytes " + trytes); err != nil {
+		t.Errorf("tx_trytes frame without hash: unexpected error: %s", err)
+	}
+}
+
+func TestExtractTransactionHashToken(t *testing.T) {
+	defer func(index int) { *hashTokenIndex = index }(*hashTokenIndex)
+	trytes := strings.Repeat("9", consts.TransactionTrytesSize)
+	hash := strings.Repeat("9", consts.HashTrytesSize)
+
+	for _, c := range []struct {
+		index int
+		frame string
+		valid bool
+	}{
+		{1, "trytes " + trytes + " " + hash, true},
+		{1, "trytes " + trytes + " " + hash + " meta", false},
+		{2, "trytes " + trytes + " meta " + hash, true},
+		{2, "trytes " + trytes + " " + hash, false},
+		{0, "trytes " + trytes + " meta " + hash + " 42", true},
+		{0, "trytes " + trytes + " meta 42", false},
+	} {
+		*hashTokenIndex = c.index
+		_, err := extractTransaction(c.frame)
+		if c.valid && err != nil {
+			t.Errorf("index %d, frame of %d tokens: unexpected error: %s", c.index, len(strings.Fields(c.frame)), err)
+		}
+		if !c.valid && !errors.Is(err, errMalformedFrame) {
+			t.Errorf("index %d, frame of %d tokens: expected errMalformedFrame but got %v", c.index, len(strings.Fields(c.frame)), err)
+		}
+	}
+}
+
+func TestExtractJSONTransaction(t *testing.T) {
+	defer func(format string) { *frameFormat = format }(*frameFormat)
+	*frameFormat = frameFormatJSON
+	hash := strings.Repeat("A", consts.HashTrytesSize)
+
+	tx, err := extractTransaction(`trytes {"address":"` + hash + `","value":-5,"hash":"` + hash + `","bundle":"` + hash + `","tag":"FOO","timestamp":1600000000}`)
+	if err != nil {
+		t.Fatalf("valid frame: unexpected error: %s", err)
+	}
+	if tx.Value != -5 || tx.Timestamp != 1600000000 || tx.Tag != "FOO"+strings.Repeat("9", 24) {
+		t.Errorf("valid frame: unexpected tx %+v", tx)
+	}
+
+	for name, frame := range map[string]string{
+		"no object":     "trytes ",
+		"not JSON":      "trytes {address}",
+		"short address": `trytes {"address":"ABC","hash":"` + hash + `","bundle":"` + hash + `"}`,
+		"missing hash":  `trytes {"address":"` + hash + `","bundle":"` + hash + `"}`,
+		"long tag":      `trytes {"address":"` + hash + `","hash":"` + hash + `","bundle":"` + hash + `","tag":"` + hash + `"}`,
+	} {
+		if _, err := extractTransaction(frame); !errors.Is(err, errMalformedFrame) {
+			t.Errorf("%s: expected errMalformedFrame but got %v", name, err)
+		}
+	}
+}
+
+func TestSelfTestTxParsing(t *testing.T) {
+	if err := selfTestTxParsing(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,9 @@ module github.com/b4nj0c4t/addr_monitor
 go 1.16
 
 require (
-	github.com/go-zeromq/zmq4 v0.13.0 // indirect
-	github.com/iotaledger/iota.go v1.0.0-beta.15.0.20210406071024-a52cf8c2c21e // indirect
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/go-zeromq/zmq4 v0.13.0
+	github.com/iotaledger/iota.go v1.0.0-beta.15.0.20210406071024-a52cf8c2c21e
+	github.com/segmentio/kafka-go v0.4.38
+	gopkg.in/yaml.v2 v2.4.0
 )
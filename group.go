@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+// watchGroup is a named set of monitored addresses with its own filters and notification targets.
+// Every seen tx is evaluated against every group and routed to each group it matches.
+type watchGroup struct {
+	Name              string            `json:"name"`
+	Addrs             []string          `json:"addrs"`
+	AddrPrefixes      []string          `json:"addrPrefixes"`
+	IgnoreAddrs       []string          `json:"ignoreAddrs"`
+	AddrTags          map[string]string `json:"addrTags"`
+	TagPattern        string            `json:"tagPattern"`
+	RequireTagPattern bool              `json:"requireTagPattern"`
+	OnlyValue         bool              `json:"onlyValue"`
+	ZeroValueAlerts   bool              `json:"zeroValueAlerts"`
+	MinValue          int64             `json:"minValue"`
+	Direction         string            `json:"direction"`
+	SlackWebhookURI   string            `json:"slackWebhookURI"`
+	WebhookURI        string            `json:"webhookURI"`
+	// the name of the templates of the group's alerts, the addresses' own template names take precedence
+	Template      string            `json:"template"`
+	AddrTemplates map[string]string `json:"addrTemplates"`
+
+	matcher *addrMatcher
+	// the notifiers of the group's slackWebhookURI and of its spend alerts, nil if there is none
+	notifier      Notifier
+	spendNotifier Notifier
+}
+
+// init builds the group's matcher, the group must be validated beforehand.
+func (g *watchGroup) init() {
+	g.matcher = &addrMatcher{
+		exact:    newAddrLookup(normalizeAddrs(g.Addrs)),
+		prefixes: g.AddrPrefixes,
+		ignored:  addrSet(normalizeAddrs(g.IgnoreAddrs)),
+		tags:     make(map[string]string, len(g.AddrTags)),
+	}
+	if g.TagPattern != "" {
+		g.matcher.tagPattern = regexp.MustCompile(g.TagPattern)
+		g.matcher.requireTagMatch = g.RequireTagPattern
+	}
+	for addr, tag := range g.AddrTags {
+		if normalized, err := normalizeAddr(addr); err == nil {
+			g.matcher.tags[normalized] = tag
+		}
+	}
+	// the matcher holds the monitored addresses from here on, which keeps the
+	// memory footprint of huge watch lists down when using the bloom address set
+	g.Addrs = nil
+}
+
+// setNotifiers injects the notifiers of the given -notifier into the group, sending its spend alerts to the given
+// high priority Slack webhook URI instead of the group's unless empty.
+func (g *watchGroup) setNotifiers(kind string, spendURI string) {
+	g.notifier = newNotifier(kind, g.SlackWebhookURI, g.WebhookURI)
+	g.spendNotifier = g.notifier
+	if spendURI != "" {
+		g.spendNotifier = newNotifier(kind, spendURI, g.WebhookURI)
+	}
+}
+
+// defaultWatchGroup builds the group defined by the command line flags.
+func defaultWatchGroup() *watchGroup {
+	g := &watchGroup{
+		Name:              "default",
+		Addrs:             parseAddrList(*monitorAddrsStr),
+		AddrPrefixes:      parseAddrList(*monitorPrefixesStr),
+		IgnoreAddrs:       parseAddrList(*ignoreAddrsStr),
+		TagPattern:        *tagPattern,
+		RequireTagPattern: *requireTagPattern,
+		OnlyValue:         *monitorOnlyValueTx,
+		ZeroValueAlerts:   *zeroValueAlerts,
+		MinValue:          *minValue,
+		Direction:         *valueDirectionFilter,
+		SlackWebhookURI:   *slackWebhookURI,
+		WebhookURI:        *webhookURI,
+		Template:          *alertTemplate,
+	}
+	// malformed entries are reported by the config validation
+	g.AddrTags, _ = parseAddrTags(*addrTagsStr)
+	g.AddrTemplates, _ = parseAddrTags(*addrTemplatesStr)
+	return g
+}
+
+// loadWatchGroups reads the JSON array of groups from the given file.
+func loadWatchGroups(path string) ([]*watchGroup, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read watch groups file: %w", err)
+	}
+	var groups []*watchGroup
+	if err := json.Unmarshal(content, &groups); err != nil {
+		return nil, fmt.Errorf("unable to parse watch groups file: %w", err)
+	}
+	if err := checkGroupNames(groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// checkGroupNames checks that every one of the given groups has a unique name.
+func checkGroupNames(groups []*watchGroup) error {
+	names := make(map[string]struct{})
+	for i, g := range groups {
+		if g.Name == "" {
+			return fmt.Errorf("watch group at index %d has no name", i)
+		}
+		if _, dup := names[g.Name]; dup {
+			return fmt.Errorf("watch group '%s' is defined more than once", g.Name)
+		}
+		names[g.Name] = struct{}{}
+	}
+	return nil
+}
+
+// The value directions of -direction.
+const (
+	directionAny = "any"
+	// deposits, i.e. txs with a positive value
+	directionIn = "in"
+	// spends, i.e. txs with a negative value
+	directionOut = "out"
+)
+
+// filteredValue returns why the group doesn't alert about matched txs (or bundles) of the given value by its min.
+// value and direction, empty if it does. Zero-value txs are neither incoming nor outgoing.
+func (g *watchGroup) filteredValue(value int64) string {
+	if value < g.MinValue && -value < g.MinValue {
+		return fmt.Sprintf("value %d below the min. value of %d", value, g.MinValue)
+	}
+	switch {
+	case g.Direction == directionIn && value <= 0:
+		return fmt.Sprintf("value %d not incoming", value)
+	case g.Direction == directionOut && value >= 0:
+		return fmt.Sprintf("value %d not outgoing", value)
+	}
+	return ""
+}
+
+// hasTargets reports whether matches of the group are sent anywhere besides the log.
+func (g *watchGroup) hasTargets() bool {
+	return g.SlackWebhookURI != "" || g.WebhookURI != "" || *jsonStdout || *pagerDutyRoutingKey != "" || *opsgenieAPIKey != "" || *kafkaBrokers != "" || *unixSocketOut != "" || *fifoOut != "" || *snsTopicARN != ""
+}
+
+// notifyTx sends the alert for the given matched tx's event to the group's notification targets.
+func (g *watchGroup) notifyTx(event *txEvent) {
+	var notifications []notification
+	if g.notifier != nil {
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.notifier.Notify(withTxEvent(ctx, event), event.Transaction)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen tx %s on monitored address %s with value %d (group %s)", event.Hash, event.Address, event.Value, g.Name)
+			return sendPagerDutyEvent(ctx, text, txSeverityInput(event), event)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen tx %s on monitored address %s with value %d (group %s)", event.Hash, event.Address, event.Value, g.Name)
+			return sendOpsgenieAlert(ctx, text, txSeverityInput(event), event)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}
+
+// notifyBundle sends the alert for the given bundle to the group's notification targets.
+func (g *watchGroup) notifyBundle(summary *bundleSummary) {
+	var notifications []notification
+	if g.notifier != nil {
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.notifier.notifyEvent(ctx, slackBundleText(summary), slackColor(bundleSeverityInput(summary), false), summary)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, summary)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen bundle %s transferring %d touching %d monitored address(es) (group %s)", summary.Bundle, summary.Value, len(summary.Addresses), g.Name)
+			return sendPagerDutyEvent(ctx, text, bundleSeverityInput(summary), summary)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen bundle %s transferring %d touching %d monitored address(es) (group %s)", summary.Bundle, summary.Value, len(summary.Addresses), g.Name)
+			return sendOpsgenieAlert(ctx, text, bundleSeverityInput(summary), summary)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, summary)
+		}))
+	}
+	fanOut(summary, notifications)
+	writeEventSinks(summary.Addresses[0].Address, summary)
+}
+
+// notifySpend sends the spend alert to the group's spend notifier and other notification targets.
+func (g *watchGroup) notifySpend(summary *spendSummary) {
+	var notifications []notification
+	if g.spendNotifier != nil {
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.spendNotifier.notifyEvent(ctx, slackSpendText(summary), slackColor(spendSeverityInput(summary), false), summary)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, summary)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("spend of %d monitored address(es) in bundle %s (group %s)", len(summary.Inputs), summary.Bundle, g.Name)
+			return sendPagerDutyEvent(ctx, text, spendSeverityInput(summary), summary)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("spend of %d monitored address(es) in bundle %s (group %s)", len(summary.Inputs), summary.Bundle, g.Name)
+			return sendOpsgenieAlert(ctx, text, spendSeverityInput(summary), summary)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, summary)
+		}))
+	}
+	fanOut(summary, notifications)
+	writeEventSinks(summary.Inputs[0].Address, summary)
+}
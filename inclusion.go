@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// the max. number of alerted txs polled at once, further ones aren't polled
+	inclusionMaxPending = 1000
+	// the interval at which the due txs are polled
+	inclusionTickInterval = 5 * time.Second
+	// the interval in between the first polls of a tx, doubled after every poll up to inclusionMaxPollInterval
+	inclusionPollInterval    = 10 * time.Second
+	inclusionMaxPollInterval = 2 * time.Minute
+)
+
+// nodeAPI is a client of the HTTP API of a node.
+type nodeAPI struct {
+	uri    string
+	client *http.Client
+}
+
+type getInclusionStatesRequest struct {
+	Command      string   `json:"command"`
+	Transactions []string `json:"transactions"`
+}
+
+type getInclusionStatesResponse struct {
+	States []bool `json:"states"`
+	Error  string `json:"error"`
+}
+
+func newNodeAPI(uri string, timeout time.Duration) *nodeAPI {
+	return &nodeAPI{uri: uri, client: &http.Client{Timeout: timeout}}
+}
+
+// inclusionStates returns whether the given txs are confirmed, in their order.
+func (a *nodeAPI) inclusionStates(ctx context.Context, hashes []string) ([]bool, error) {
+	body, err := json.Marshal(&getInclusionStatesRequest{Command: "getInclusionStates", Transactions: hashes})
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize getInclusionStates request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to build getInclusionStates request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-IOTA-API-Version", "1")
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call getInclusionStates: %w", err)
+	}
+	defer closeResponse(res)
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read getInclusionStates response: %w", err)
+	}
+	var states getInclusionStatesResponse
+	if err := json.Unmarshal(content, &states); err != nil {
+		return nil, fmt.Errorf("unable to parse getInclusionStates response (status %s): %w", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getInclusionStates failed with status %s: %s", res.Status, states.Error)
+	}
+	if len(states.States) != len(hashes) {
+		return nil, fmt.Errorf("getInclusionStates returned %d states for %d txs", len(states.States), len(hashes))
+	}
+	return states.States, nil
+}
+
+// inclusionPoller polls the confirmation of the alerted txs via the node API, sending a follow-up alert once a tx
+// confirmed or the timeout passed since its alert without it confirming. The txs due to be polled are polled together
+// in a single request per tick.
+type inclusionPoller struct {
+	api     *nodeAPI
+	timeout time.Duration
+
+	mu sync.Mutex
+	// the alerted txs pending confirmation
+	pending []*pendingInclusion
+}
+
+// pendingInclusion is an alerted tx pending confirmation.
+type pendingInclusion struct {
+	group     *watchGroup
+	event     *txEvent
+	alertedAt time.Time
+	// when the tx is polled next and the interval to the poll after it
+	nextPoll time.Time
+	interval time.Duration
+}
+
+// txConfirmationEvent is the generic webhook payload of the follow-up alert about the confirmation of an alerted tx.
+type txConfirmationEvent struct {
+	Event   string `json:"event"`
+	Group   string `json:"group"`
+	Tx      string `json:"tx"`
+	Address string `json:"address"`
+	Bundle  string `json:"bundle"`
+	Value   int64  `json:"value"`
+	// 'confirmed', or 'unconfirmed' if the tx didn't confirm within the timeout
+	Status string `json:"status"`
+	// how long after the alert the tx was confirmed or the polling timed out
+	After string    `json:"after"`
+	Time  time.Time `json:"time"`
+}
+
+var txConfirmedTemplate = `monitoring:
+- tx %s on address %s confirmed %v after its alert
+`
+
+var txUnconfirmedTemplate = `monitoring:
+- tx %s on address %s still unconfirmed %v after its alert, no longer polling
+`
+
+// newInclusionPoller starts the scheduler of a poller, which stops once the given context is done.
+func newInclusionPoller(ctx context.Context, api *nodeAPI, timeout time.Duration) *inclusionPoller {
+	p := &inclusionPoller{api: api, timeout: timeout}
+	go p.run(ctx)
+	return p
+}
+
+// track polls the confirmation of the tx of the given alert of the given group sent at the given time, unless the
+// max. number of txs is already pending.
+func (p *inclusionPoller) track(group *watchGroup, event *txEvent, alertedAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) >= inclusionMaxPending {
+		inclusionPollsDropped.Add(1)
+		logEvent(levelWarn, logFields{Event: "inclusion_poll_dropped", TxHash: event.Hash, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "not polling the confirmation of tx %s: %d txs are already pending", event.Hash, inclusionMaxPending)
+		return
+	}
+	p.pending = append(p.pending, &pendingInclusion{
+		group: group, event: event, alertedAt: alertedAt,
+		nextPoll: p.capToDeadline(alertedAt, alertedAt.Add(inclusionPollInterval)), interval: inclusionPollInterval,
+	})
+}
+
+// capToDeadline returns the given time of the next poll of a tx alerted at the given time, at the latest its timeout.
+func (p *inclusionPoller) capToDeadline(alertedAt time.Time, next time.Time) time.Time {
+	if deadline := alertedAt.Add(p.timeout); next.After(deadline) {
+		return deadline
+	}
+	return next
+}
+
+func (p *inclusionPoller) run(ctx context.Context) {
+	ticker := time.NewTicker(inclusionTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.tick(ctx, now)
+		}
+	}
+}
+
+// tick polls the txs due at the given time in a single request, alerting about the ones which confirmed or whose
+// timeout passed and backing off the polls of the others. Failed polls are logged and retried like unconfirmed ones.
+func (p *inclusionPoller) tick(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	var due []*pendingInclusion
+	var hashes []string
+	polled := make(map[string]bool)
+	for _, pending := range p.pending {
+		if pending.nextPoll.After(now) {
+			continue
+		}
+		due = append(due, pending)
+		if !polled[pending.event.Hash] {
+			polled[pending.event.Hash] = true
+			hashes = append(hashes, pending.event.Hash)
+		}
+	}
+	p.mu.Unlock()
+	if len(due) == 0 {
+		return
+	}
+
+	confirmed := make(map[string]bool, len(hashes))
+	states, err := p.api.inclusionStates(ctx, hashes)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return
+	case err != nil:
+		logEvent(levelError, logFields{Event: "inclusion_poll_failed"}, "could not poll the confirmation of %d tx(s): %s", len(hashes), err)
+	default:
+		for i, hash := range hashes {
+			confirmed[hash] = states[i]
+		}
+	}
+
+	done := make(map[*pendingInclusion]bool)
+	for _, pending := range due {
+		switch {
+		case confirmed[pending.event.Hash]:
+			p.notify(pending.group, pending.event, "confirmed", now.Sub(pending.alertedAt))
+		case !now.Before(pending.alertedAt.Add(p.timeout)):
+			p.notify(pending.group, pending.event, "unconfirmed", p.timeout)
+		default:
+			if pending.interval *= 2; pending.interval > inclusionMaxPollInterval {
+				pending.interval = inclusionMaxPollInterval
+			}
+			pending.nextPoll = p.capToDeadline(pending.alertedAt, now.Add(pending.interval))
+			continue
+		}
+		done[pending] = true
+	}
+	p.mu.Lock()
+	remaining := p.pending[:0]
+	for _, pending := range p.pending {
+		if !done[pending] {
+			remaining = append(remaining, pending)
+		}
+	}
+	p.pending = remaining
+	p.mu.Unlock()
+}
+
+func (p *inclusionPoller) notify(group *watchGroup, tx *txEvent, status string, after time.Duration) {
+	after = after.Truncate(time.Second)
+	event := &txConfirmationEvent{
+		Event: "tx_confirmation", Group: group.Name, Tx: tx.Hash, Address: tx.Address, Bundle: tx.Bundle,
+		Value: tx.Value, Status: status, After: after.String(), Time: alertTime(),
+	}
+	logEvent(levelInfo, logFields{Event: "tx_confirmation", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "tx %s on monitored address %s (group %s) is %s %v after its alert", tx.Hash, tx.Address, group.Name, status, after)
+	group.notifyTxConfirmation(event)
+}
+
+// notifyTxConfirmation sends the follow-up alert about the confirmation of an alerted tx to the group's Slack channel
+// and webhook.
+func (g *watchGroup) notifyTxConfirmation(event *txConfirmationEvent) {
+	var notifications []notification
+	if g.notifier != nil {
+		txLink := explorerLink(*txExplorerURI, *txMirrorURI, event.Tx)
+		addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address)
+		builtin, severity := fmt.Sprintf(txConfirmedTemplate, txLink, addrLink, event.After), "info"
+		if event.Status != "confirmed" {
+			builtin, severity = fmt.Sprintf(txUnconfirmedTemplate, txLink, addrLink, event.After), "warning"
+		}
+		color := ""
+		if *slackColors {
+			color = slackSeverityColors[severity]
+		}
+		text := renderSlackText(event.Event, event, builtin)
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.notifier.notifyEvent(ctx, text, color, event)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}
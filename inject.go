@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/guards"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// injectedNode labels the frames of injected txs.
+const injectedNode = "inject"
+
+// injectedFrames passes the frames of the txs injected via the debug server to the main loop,
+// which runs them through the pipeline like the frames received from the node.
+var injectedFrames = make(chan streamFrame)
+
+// injectRequest is the payload of a synthetic tx to inject.
+type injectRequest struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
+	// a random bundle hash is used if empty, so that drills aren't correlated as reattachments
+	Bundle string `json:"bundle"`
+	Tag    string `json:"tag"`
+}
+
+type injectResponse struct {
+	Hash   string `json:"hash"`
+	Bundle string `json:"bundle"`
+}
+
+// buildInjectedFrame builds the frame of a single tx bundle as described by the given request, as published on the
+// 'trytes' topic (or on the -topic in the -frameFormat json).
+func buildInjectedFrame(req *injectRequest, now time.Time) (string, *transaction.Transaction, error) {
+	addr, err := normalizeAddr(req.Address)
+	if err != nil {
+		return "", nil, err
+	}
+	bundle := req.Bundle
+	if bundle == "" {
+		bundle = randomTrytes(consts.HashTrytesSize)
+	} else if !guards.IsTrytesOfExactLength(bundle, consts.HashTrytesSize) {
+		return "", nil, fmt.Errorf("bundle '%s' is not %d trytes", bundle, consts.HashTrytesSize)
+	}
+	tag := req.Tag
+	if !guards.IsTrytes(tag) && tag != "" || len(tag) > consts.TagTrinarySize/3 {
+		return "", nil, fmt.Errorf("tag '%s' is not at most %d trytes", tag, consts.TagTrinarySize/3)
+	}
+	tag += strings.Repeat("9", consts.TagTrinarySize/3-len(tag))
+
+	tx := &transaction.Transaction{
+		SignatureMessageFragment: strings.Repeat("9", consts.SignatureMessageFragmentSizeInTrytes),
+		Address:                  addr,
+		Value:                    req.Value,
+		ObsoleteTag:              tag,
+		Timestamp:                uint64(now.Unix()),
+		Bundle:                   bundle,
+		TrunkTransaction:         strings.Repeat("9", consts.HashTrytesSize),
+		BranchTransaction:        strings.Repeat("9", consts.HashTrytesSize),
+		Tag:                      tag,
+		AttachmentTimestamp:      now.UnixNano() / int64(time.Millisecond),
+		Nonce:                    strings.Repeat("9", consts.NonceTrinarySize/3),
+	}
+	trytes, err := transaction.TransactionToTrytes(tx)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to serialize tx: %w", err)
+	}
+	tx.Hash = transaction.TransactionHash(tx)
+	if *frameFormat == frameFormatJSON {
+		obj, err := json.Marshal(&jsonTx{
+			Address: tx.Address, Value: tx.Value, Hash: tx.Hash, Bundle: tx.Bundle, Tag: tx.Tag, Timestamp: tx.Timestamp,
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to serialize tx: %w", err)
+		}
+		return *subTopic + " " + string(obj), tx, nil
+	}
+	return trytesSubTopic + " " + trytes + " " + tx.Hash, tx, nil
+}
+
+func randomTrytes(n int) string {
+	trytes := make([]byte, n)
+	for i := range trytes {
+		trytes[i] = consts.TryteAlphabet[rand.Intn(len(consts.TryteAlphabet))]
+	}
+	return string(trytes)
+}
+
+// serveInject injects the synthetic tx of the request into the pipeline, if authorized by the given API token.
+// The tx then runs through the full match, filter and notify path as if it arrived from the node.
+func serveInject(w http.ResponseWriter, r *http.Request, apiToken string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, apiToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req injectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("unable to parse inject request: %s", err), http.StatusBadRequest)
+		return
+	}
+	frame, tx, err := buildInjectedFrame(&req, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case injectedFrames <- streamFrame{frame: []byte(frame), node: injectedNode}:
+	case <-r.Context().Done():
+		return
+	}
+	logEvent(levelInfo, logFields{Event: "tx_injected", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "injected tx %s on address %s with value %d", tx.Hash, tx.Address, tx.Value)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(&injectResponse{Hash: tx.Hash, Bundle: tx.Bundle}); err != nil {
+		errorf("could not write inject response: %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// reconnectTracker detects an unstable node connection by counting reconnect attempts within a sliding window.
+type reconnectTracker struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	attempts  []reconnectAttempt
+	lastAlert time.Time
+}
+
+type reconnectAttempt struct {
+	at time.Time
+	ok bool
+}
+
+// instabilityEvent is the generic webhook payload of a connection instability alert.
+type instabilityEvent struct {
+	Event     string    `json:"event"`
+	Node      string    `json:"node"`
+	Window    string    `json:"window"`
+	Successes int       `json:"successes"`
+	Failures  int       `json:"failures"`
+	Time      time.Time `json:"time"`
+}
+
+var instabilityTemplate = `monitoring:
+- connection to node %s is unstable: %d successful and %d failed reconnect attempt(s) within %v
+`
+
+func newReconnectTracker(threshold int, window time.Duration) *reconnectTracker {
+	return &reconnectTracker{threshold: threshold, window: window}
+}
+
+// record records a reconnect attempt to the given node and returns the instability alert to send if the attempts
+// within the window exceed the threshold. At most one alert is returned per window.
+func (t *reconnectTracker) record(node string, ok bool, now time.Time) *instabilityEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts = append(t.attempts, reconnectAttempt{at: now, ok: ok})
+	for len(t.attempts) > 0 && now.Sub(t.attempts[0].at) > t.window {
+		t.attempts = t.attempts[1:]
+	}
+
+	if len(t.attempts) <= t.threshold || now.Sub(t.lastAlert) < t.window {
+		return nil
+	}
+	t.lastAlert = now
+
+	event := &instabilityEvent{Event: "connection_instability", Node: node, Window: t.window.String(), Time: alertTime()}
+	for _, attempt := range t.attempts {
+		if attempt.ok {
+			event.Successes++
+			continue
+		}
+		event.Failures++
+	}
+	return event
+}
+
+// recordReconnectAttempt records a reconnect attempt to the given node with the reconnect tracker, if enabled,
+// and sends a connection instability alert if needed.
+func recordReconnectAttempt(node string, ok bool) {
+	if reconnects == nil {
+		return
+	}
+	event := reconnects.record(node, ok, time.Now())
+	if event == nil {
+		return
+	}
+	notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(instabilityTemplate, event.Node, event.Successes, event.Failures, reconnects.window)), event)
+}
+
+// reconnects tracks the reconnect attempts, nil if connection instability alerts are disabled.
+var reconnects *reconnectTracker
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// instanceLabel identifies this monitor instance (e.g. its region) in every notification and structured event,
+// the hostname unless configured.
+var instanceLabel string
+
+func resolveInstanceLabel(label string) string {
+	if label != "" {
+		return label
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		errorf("could not determine hostname for the instance label: %s", err)
+		return ""
+	}
+	return host
+}
+
+// labelEvent returns the given structured event with the instance label added as its 'instance' field.
+// Payloads which aren't JSON objects (or can't be serialized) are returned as is.
+func labelEvent(payload interface{}) interface{} {
+	if instanceLabel == "" {
+		return payload
+	}
+	content, err := json.Marshal(payload)
+	if err != nil || len(content) < 2 || content[0] != '{' {
+		return payload
+	}
+	label, _ := json.Marshal(instanceLabel)
+	labeled := append([]byte(`{"instance":`), label...)
+	if content[1] != '}' {
+		labeled = append(labeled, ',')
+	}
+	return json.RawMessage(append(labeled, content[1:]...))
+}
+
+// instanceFooter returns the footer labeling Slack msgs with the instance label, empty if unlabeled.
+func instanceFooter() string {
+	if instanceLabel == "" {
+		return ""
+	}
+	return "\n_instance " + instanceLabel + "_"
+}
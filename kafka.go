@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaProducer produces alerts as JSON messages keyed by address to a Kafka topic. Messages are produced
+// asynchronously, so that producer errors and reconnects to the brokers don't block the processing of the stream.
+type kafkaProducer struct {
+	w *kafka.Writer
+	// bounds looking up the topic's partitions, which isn't asynchronous
+	timeout time.Duration
+}
+
+// kafkaSink is the producer of alerts to Kafka, nil if disabled.
+var kafkaSink *kafkaProducer
+
+func newKafkaProducer(brokers []string, topic string, timeout time.Duration) *kafkaProducer {
+	return &kafkaProducer{timeout: timeout, w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		Async:        true,
+		BatchTimeout: 50 * time.Millisecond,
+		RequiredAcks: kafka.RequireAll,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				kafkaProduceErrors.Add(int64(len(messages)))
+				errorf("could not produce %d message(s) to kafka: %s", len(messages), err)
+			}
+		},
+	}}
+}
+
+// produce enqueues the given payload as JSON message with the given key.
+func (p *kafkaProducer) produce(key string, payload interface{}) error {
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialize kafka message: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.w.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: value})
+}
+
+// Close flushes the pending messages and closes the producer.
+func (p *kafkaProducer) Close() error {
+	return p.w.Close()
+}
+
+// writeEventSinks writes the given alert payload, keyed by the given address, to the event sinks
+// (JSON lines on stdout, Kafka and JSON lines on a Unix domain socket) which are enabled.
+func writeEventSinks(addr string, payload interface{}) {
+	payload = labelEvent(payload)
+	if *jsonStdout {
+		if err := writeStdoutPayload(payload); err != nil {
+			errorf("could not write stdout payload: %s", err)
+		}
+	}
+	if kafkaSink != nil {
+		if err := kafkaSink.produce(addr, payload); err != nil {
+			errorf("could not produce kafka message: %s", err)
+		}
+	}
+	if unixSocketSink != nil {
+		if err := unixSocketSink.write(payload); err != nil {
+			unixSocketWriteErrors.Add(1)
+			errorf("could not write unix socket payload: %s", err)
+		}
+	}
+	if fifoSink != nil {
+		if err := fifoSink.write(payload); err != nil {
+			errorf("could not write FIFO payload: %s", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// streamLagBuckets are the upper bounds of the stream_lag_buckets histogram, the last bucket ('inf') counting the rest.
+var streamLagBuckets = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// streamLagSmoothing is the weight of a new sample in the smoothed lag: as the attachment timestamps are set by
+// the issuing clients, single txs may be off by the issuers' clock skew or the time they spent on the PoW.
+const streamLagSmoothing = 0.05
+
+// streamLag estimates how far behind real time the stream is by the attachment timestamps of the received txs,
+// exposed as the expvar gauge stream_lag_ms and the histogram stream_lag_buckets.
+type streamLag struct {
+	// the smoothed lag above which a warning is logged, 0 disables it
+	threshold time.Duration
+
+	mu       sync.Mutex
+	smoothed float64
+	sampled  bool
+	warned   bool
+}
+
+func newStreamLag(threshold time.Duration) *streamLag {
+	for _, bound := range streamLagBuckets {
+		streamLagHistogram.Add(bucketName(bound), 0)
+	}
+	streamLagHistogram.Add("inf", 0)
+	return &streamLag{threshold: threshold}
+}
+
+func bucketName(bound time.Duration) string {
+	return "le_" + bound.String()
+}
+
+// observe records the lag of the given tx received at the given time. Txs without an attachment timestamp (e.g. as
+// re-emitted by gateways in the JSON frame format) fall back to their timestamp, which has a precision of seconds.
+func (l *streamLag) observe(tx *transaction.Transaction, now time.Time) {
+	var issued time.Time
+	switch {
+	case tx.AttachmentTimestamp > 0:
+		issued = time.Unix(0, tx.AttachmentTimestamp*int64(time.Millisecond))
+	case tx.Timestamp > 0:
+		issued = time.Unix(int64(tx.Timestamp), 0)
+	default:
+		return
+	}
+	lag := now.Sub(issued)
+	if lag < 0 {
+		// issued by a client whose clock is ahead
+		lag = 0
+	}
+	bucket := "inf"
+	for _, bound := range streamLagBuckets {
+		if lag <= bound {
+			bucket = bucketName(bound)
+			break
+		}
+	}
+	streamLagHistogram.Add(bucket, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.sampled {
+		l.smoothed, l.sampled = float64(lag), true
+	} else {
+		l.smoothed += streamLagSmoothing * (float64(lag) - l.smoothed)
+	}
+	smoothed := time.Duration(l.smoothed)
+	streamLagMillis.Set(int64(smoothed / time.Millisecond))
+	if l.threshold <= 0 {
+		return
+	}
+	switch {
+	case smoothed > l.threshold && !l.warned:
+		l.warned = true
+		warnf("warning: the stream lags %v behind the attachment timestamps of its txs (above -maxStreamLag %v)", smoothed.Round(time.Millisecond), l.threshold)
+	case smoothed <= l.threshold/2 && l.warned:
+		// only warn again once the lag decreased notably, instead of on every fluctuation around the threshold
+		l.warned = false
+		infof("the stream lag is back to %v", smoothed.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// The formats of -logFormat.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevel is the severity of a log event.
+type logLevel string
+
+const (
+	levelInfo  logLevel = "info"
+	levelWarn  logLevel = "warn"
+	levelError logLevel = "error"
+	levelFatal logLevel = "fatal"
+)
+
+// jsonLogs is whether the log events are logged as JSON objects (-logFormat json) instead of their msgs.
+var jsonLogs bool
+
+// logFields are the structured fields of a log event, empty ones being omitted from the JSON objects. In the text
+// format only the msg is logged, so the fields must also be part of it.
+type logFields struct {
+	// the kind of the event, e.g. 'tx_matched' or 'reconnect'
+	Event   string
+	TxHash  string
+	Address string
+	Bundle  string
+	Group   string
+}
+
+// logEntry is a log event as logged with -logFormat json.
+type logEntry struct {
+	Ts      string `json:"ts"`
+	Level   string `json:"level"`
+	Event   string `json:"event,omitempty"`
+	Msg     string `json:"msg"`
+	TxHash  string `json:"txHash,omitempty"`
+	Address string `json:"address,omitempty"`
+	Bundle  string `json:"bundle,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// setLogFormat switches the log to the given -logFormat, JSON objects carrying their own timestamp.
+func setLogFormat(format string) {
+	jsonLogs = format == logFormatJSON
+	if jsonLogs {
+		log.SetFlags(0)
+	}
+}
+
+// logEvent logs the msg of the given format of an event of the given level with the given fields. The last error
+// among the args is also logged as the 'error' field of the JSON objects.
+func logEvent(level logLevel, fields logFields, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !jsonLogs {
+		_ = log.Output(3, msg)
+		return
+	}
+	entry := &logEntry{
+		Ts: time.Now().UTC().Format(time.RFC3339Nano), Level: string(level), Event: fields.Event, Msg: msg,
+		TxHash: fields.TxHash, Address: fields.Address, Bundle: fields.Bundle, Group: fields.Group,
+	}
+	for i := len(args) - 1; i >= 0; i-- {
+		if err, ok := args[i].(error); ok && err != nil {
+			entry.Error = err.Error()
+			break
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"level":"error","msg":"unable to serialize log event: %s"}`, err))
+	}
+	_ = log.Output(3, string(line))
+}
+
+// infof logs an informational msg without structured fields.
+func infof(format string, args ...interface{}) {
+	logEvent(levelInfo, logFields{}, format, args...)
+}
+
+// warnf logs a msg about a degraded but working state.
+func warnf(format string, args ...interface{}) {
+	logEvent(levelWarn, logFields{}, format, args...)
+}
+
+// errorf logs a msg about a failed operation.
+func errorf(format string, args ...interface{}) {
+	logEvent(levelError, logFields{}, format, args...)
+}
+
+// fatalf logs a msg about an unrecoverable error and exits.
+func fatalf(format string, args ...interface{}) {
+	logEvent(levelFatal, logFields{}, format, args...)
+	os.Exit(1)
+}
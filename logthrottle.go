@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+)
+
+// logThrottle logs the first occurrence of a msg and then only a rolled-up count of its repetitions per interval,
+// keeping the log readable during bursts of identical errors.
+type logThrottle struct {
+	interval time.Duration
+	// the level and fields the msgs are logged with
+	level  logLevel
+	fields logFields
+	msgs   map[string]*throttledMsg
+}
+
+type throttledMsg struct {
+	since    time.Time
+	repeated int
+}
+
+func newLogThrottle(interval time.Duration, level logLevel, fields logFields) *logThrottle {
+	return &logThrottle{interval: interval, level: level, fields: fields, msgs: make(map[string]*throttledMsg)}
+}
+
+// log logs the given msg unless it was already logged within the current interval.
+func (t *logThrottle) log(msg string, now time.Time) {
+	t.flush(now)
+	if m, has := t.msgs[msg]; has {
+		m.repeated++
+		return
+	}
+	logEvent(t.level, t.fields, "%s", msg)
+	t.msgs[msg] = &throttledMsg{since: now}
+}
+
+// flush logs the repetition counts of the msgs whose interval elapsed and forgets the msgs which weren't repeated.
+func (t *logThrottle) flush(now time.Time) {
+	for msg, m := range t.msgs {
+		if now.Sub(m.since) < t.interval {
+			continue
+		}
+		if m.repeated == 0 {
+			delete(t.msgs, msg)
+			continue
+		}
+		logEvent(t.level, t.fields, "%s (repeated %d more time(s) in the last %s)", msg, m.repeated, now.Sub(m.since).Round(time.Second))
+		m.since = now
+		m.repeated = 0
+	}
+}
@@ -1,47 +1,218 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"math/rand"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
-
-	"github.com/go-zeromq/zmq4"
-	"github.com/iotaledger/iota.go/transaction"
+	"unicode/utf8"
 )
 
 var (
-	nodeURI              = flag.String("node", "tcp://example.com:5556", "the URI to the ZMQ stream")
+	nodeURI              = flag.String("node", "tcp://example.com:5556", "the URI to the ZMQ stream, or the URIs of multiple nodes (comma separated) handled according to -nodeMode")
+	nodeDSN              = flag.String("nodeDSN", "", "the -node URI(s) with node connection options as query parameters, e.g. 'tcp://host:5556?dialTimeout=5s&subscribe=trytes', as an alternative to the individual flags")
+	nodeMode             = flag.String("nodeMode", nodeModeFailover, "how multiple -node URIs are handled: 'failover' (a single stream, failing over to the next node whenever connecting to the current one fails) or 'fanin' (a stream per node, all of them matched with alerts labeled with their node)")
+	preferPrimary        = flag.Bool("preferPrimary", false, "whether to move back to the first -node once it's reachable again while failed over to another one (checked every -connRetryInterval)")
 	logAnySeenTxs        = flag.Bool("logAnySeenTx", false, "whether to output every seen txs to stdout")
+	logSeenTxDetails     = flag.Bool("logSeenTxDetails", false, "whether to additionally output the value, tag and bundle of every seen tx logged via -logAnySeenTx")
+	logFormat            = flag.String("logFormat", logFormatText, "the format of the log: 'text' (plain msgs) or 'json' (an object per event with its level, kind and tx hash, address and bundle)")
 	connRetryIntervalStr = flag.String("connRetryInterval", "5s", "the interval at which to dial back to the remote host in case of connection closure")
+	connRetryMaxIntStr   = flag.String("connRetryMaxInterval", "0", "the max. interval in between reconnect attempts up to which the -connRetryInterval is doubled after every failed attempt (0 or not above -connRetryInterval keeps the interval fixed)")
+	maxDowntimeStr       = flag.String("maxDowntime", "0", "the cumulative downtime of the connection to the node within the -downtimeWindow above which a downtime alert is sent, catching a flapping connection of frequent short outages (0 disables the alert)")
+	downtimeWindowStr    = flag.String("downtimeWindow", "1h", "the window in which the downtime of the connection to the node is summed up for -maxDowntime")
+	idleProbeIntervalStr = flag.String("idleProbeInterval", "0", "the idle duration without any received msg after which the connection to the node is probed (by re-sending the subscription), reconnecting if the probe fails (0 disables probing)")
+	maxIdleStr           = flag.String("maxIdle", "2m", "the idle duration without any received msg after which the connection to the node is considered dead and a reconnect is forced, for nodes keeping the connection open while they stopped publishing (0 disables the watchdog)")
 	dialTimeoutStr       = flag.String("dialTimeout", "5s", "the dial timeout to the specified URI")
-	monitorAddrsStr      = flag.String("addrs", "", "the addresses to monitor for (comma separated, 81 tryte addrs)")
+	monitorAddrsStr      = flag.String("addrs", "", "the addresses to monitor for (comma separated, in the -addressFormat)")
+	cleanAddrs           = flag.Bool("cleanAddrs", false, "whether to clean up configured addresses pasted from elsewhere by stripping characters which can't be part of an address (e.g. whitespace or invisible characters) and fixing their case, logging every applied cleanup")
+	addrFormat           = flag.String("addressFormat", addrFormatLegacy, "the format of the configured addresses: 'legacy' (81 trytes or 90 trytes including the checksum) or 'chrysalis' (additionally bech32 Ed25519 addresses, monitored via their migration address)")
 	slackWebhookURI      = flag.String("slackWebhookURI", "", "the webhook URI to which monitoring msgs are sent to")
 	monitorOnlyValueTx   = flag.Bool("onlyValue", false, "whether to only validate value transactions")
-	txExplorerURI        = flag.String("explorerTxsURI", "https://explorer.iota.org/mainnet/transaction", "defines the explorer URI for links for txs")
-	bundleExplorerURI    = flag.String("explorerBundleURI", "https://explorer.iota.org/mainnet/bundle", "defines the explorer URI for links for bundles")
-	addrExplorerURI      = flag.String("explorerAddrsURI", "https://explorer.iota.org/mainnet/address", "defines the explorer URI for links for addresses")
+	minValue             = flag.Int64("minValue", 0, "the min. absolute value of the matched txs to alert about, txs of lower value are skipped")
+	valueDirectionFilter = flag.String("direction", directionAny, "the direction of the matched txs to alert about by the sign of their value: 'in' (deposits), 'out' (spends) or 'any', zero-value txs are neither")
+	zeroValueAlerts      = flag.Bool("zeroValueAlerts", false, "whether to send dedicated zero-value alerts (with the tx's tag and msg) instead of tx alerts for zero-value txs on monitored addresses, also with -onlyValue")
+	txExplorerURI        = flag.String("explorerTxsURI", "https://explorer.iota.org/mainnet/transaction", "defines the explorer URI for links for txs (positioning the hash via a '{hash}' placeholder, appending it as last path segment otherwise)")
+	bundleExplorerURI    = flag.String("explorerBundleURI", "https://explorer.iota.org/mainnet/bundle", "defines the explorer URI for links for bundles (positioning the hash via a '{hash}' placeholder, appending it as last path segment otherwise)")
+	addrExplorerURI      = flag.String("explorerAddrsURI", "https://explorer.iota.org/mainnet/address", "defines the explorer URI for links for addresses (positioning the address via an '{address}' placeholder, appending it as last path segment otherwise)")
+	formatExplorerLinks  = flag.Bool("formatExplorerLinks", true, "whether to render txs, addresses and bundles in alerts as explorer links, plain hashes/addresses are rendered otherwise (e.g. without access to an explorer)")
+	qrCodeURI            = flag.String("qrCodeURI", "", "the URI of a QR code service rendering the address (with checksum) positioned via an '{address}' placeholder, enables including a QR code link of the address in tx alerts (e.g. 'https://api.qrserver.com/v1/create-qr-code/?data={address}')")
+	txMirrorURI          = flag.String("explorerTxsMirrorURI", "", "defines an optional mirror explorer URI for additional links for txs")
+	bundleMirrorURI      = flag.String("explorerBundleMirrorURI", "", "defines an optional mirror explorer URI for additional links for bundles")
+	addrMirrorURI        = flag.String("explorerAddrsMirrorURI", "", "defines an optional mirror explorer URI for additional links for addresses")
+	explorerLinkSuffix   = flag.String("explorerLinkSuffix", "", "an optional suffix appended to every (primary and mirror) explorer link after the ID, e.g. '?network=devnet' to select the network, a leading '?' is turned into '&' for links which already have a query")
+	subTopic             = flag.String("topic", trytesSubTopic, "the ZMQ topic to subscribe to ('trytes' or 'tx_trytes')")
+	milestoneTopic       = flag.String("milestoneTopic", "", "the ZMQ milestone topic to additionally subscribe to (e.g. 'lmi', 'lmsi' or 'lmhs'), tracking the latest milestone as a freshness signal exposed via the expvar counters (empty disables it)")
+	minConfirmations     = flag.Int64("minConfirmations", 0, "the number of milestones (including the confirming one) after which a matched tx is alerted on once it was confirmed, as published on the 'sn' topic which is then additionally subscribed to (0 alerts on txs as soon as they're seen)")
+	confirmTimeoutStr    = flag.String("confirmationTimeout", "1h", "how long the alerts of matched txs are held with -minConfirmations before being dropped if the txs didn't get enough confirmations, or their confirmation is polled with -confirmURI")
+	confirmURI           = flag.String("confirmURI", "", "the URI of a node's HTTP API whose getInclusionStates is polled for the confirmation of every alerted tx, sending a follow-up alert once it confirmed or the -confirmationTimeout passed (disabled if empty)")
+	topNIntervalStr      = flag.String("topNInterval", "0", "the interval at which the operators are sent a report of the -topN most active monitored addresses within it, with their tx counts and net values (0 disables the report)")
+	topN                 = flag.Int("topN", 10, "the number of monitored addresses listed by the -topNInterval report")
+	activityRetentionStr = flag.String("activityRetention", "0", "how long the per minute activity of the monitored addresses is kept in memory to be queried in buckets via /activity of the -pprofAddr, e.g. by a Grafana JSON datasource (0 disables the endpoint)")
+	maxStreamLagStr      = flag.String("maxStreamLag", "0", "the lag of the stream behind the attachment timestamps of its txs (smoothed, exposed as the expvar gauge stream_lag_ms) above which a warning is logged (0 disables the warning)")
+	milestoneTimeoutStr  = flag.String("milestoneTimeout", "0", "the duration after which an alert is sent if the latest milestone didn't advance within it (requires -milestoneTopic, 0 disables the alert)")
+	webhookURI           = flag.String("webhookURI", "", "the generic webhook URI to which matched txs are POSTed as JSON")
+	pagerDutyRoutingKey  = flag.String("pagerDutyRoutingKey", "", "the PagerDuty Events API v2 routing (integration) key, enables triggering PagerDuty alerts for matched txs and bundles")
+	snsTopicARN          = flag.String("snsTopicARN", "", "the ARN of an AWS SNS topic to publish the alerts to as JSON msgs, with the AWS credentials of the standard credentials chain")
+	snsRegionFlag        = flag.String("snsRegion", "", "the AWS region of the -snsTopicARN, the one of the ARN if empty")
+	snsTimeoutStr        = flag.String("snsTimeout", "0", "the timeout of publishing a single notification to SNS (0 only bounds it by -httpTimeout and -notifyDeadline)")
+	snsEndpoint          = flag.String("snsEndpoint", "", "the endpoint of the SNS API, the regional AWS endpoint if empty (e.g. for a local SNS emulator)")
+	pagerDutyURI         = flag.String("pagerDutyURI", "https://events.pagerduty.com/v2/enqueue", "the PagerDuty Events API v2 URI")
+	pagerDutySeverity    = flag.String("pagerDutySeverity", "info", "the severity of PagerDuty alerts not matched by any severity rule: 'info', 'warning', 'error' or 'critical'")
+	opsgenieAPIKey       = flag.String("opsgenieAPIKey", "", "the Opsgenie API (integration) key, enables creating Opsgenie alerts for matched txs and bundles, prioritized by the -pagerDutyRulesFile severities")
+	opsgenieURI          = flag.String("opsgenieURI", "https://api.opsgenie.com/v2/alerts", "the Opsgenie Alert API URI (e.g. 'https://api.eu.opsgenie.com/v2/alerts' for the EU instance)")
+	opsgenieTimeoutStr   = flag.String("opsgenieTimeout", "0", "the timeout of sending a single notification to Opsgenie (0 only bounds it by -httpTimeout and -notifyDeadline)")
+	severityRulesFile    = flag.String("pagerDutyRulesFile", "", "the path to a JSON file of rules mapping alerts to PagerDuty severities and dedup keys")
+	pagerDutyTimeoutStr  = flag.String("pagerDutyTimeout", "0", "the timeout of sending a single notification to PagerDuty (0 only bounds it by -httpTimeout and -notifyDeadline)")
+	priceURI             = flag.String("priceURL", "", "the URL of an HTTP endpoint responding with the fiat price of 1 Mi as a plain number, enables including estimated fiat values in alerts")
+	priceCurrency        = flag.String("priceCurrency", "USD", "the currency of the price returned by -priceURL, as displayed in alerts")
+	priceTTLStr          = flag.String("priceTTL", "5m", "how often the price is fetched again in the background")
+	kafkaBrokers         = flag.String("kafkaBrokers", "", "the Kafka brokers (comma separated host:port) to which alerts are produced as JSON messages keyed by address")
+	kafkaTopic           = flag.String("kafkaTopic", "addr_monitor", "the Kafka topic to which alerts are produced")
+	fifoOut              = flag.String("fifoOut", "", "the path to an existing named pipe (FIFO) to which every alert is written as a single JSON object per line, dropping the alerts while no process reads it")
+	unixSocketOut        = flag.String("unixSocketOut", "", "the path to a Unix domain socket to which every alert is written as a single JSON object per line, redialing it whenever the listening consumer restarted")
+	jsonStdout           = flag.Bool("jsonStdout", false, "whether to write every alert as a single JSON object per line to stdout (logs go to stderr)")
+	webhookGzip          = flag.Bool("webhookGzip", false, "whether to gzip compress the generic webhook payloads")
+	addrsURL             = flag.String("addrsURL", "", "the URL of an HTTP endpoint from which additional addresses to monitor are fetched (separated by newlines and/or commas)")
+	addrsURLRefreshStr   = flag.String("addrsURLRefreshInterval", "5m", "the interval at which the addresses are fetched again from -addrsURL (0 disables the refresh)")
+	addrsFile            = flag.String("addrsFile", "", "the path to a file from which additional addresses to monitor are read (separated by newlines and/or commas)")
+	addrsFileWatch       = flag.Bool("addrsFileWatch", false, "whether to automatically reload the -addrsFile whenever it changes on disk (e.g. a mounted Kubernetes ConfigMap)")
+	notifyAddrChanges    = flag.Bool("notifyAddrChanges", false, "whether to notify the operators about the addresses added and removed whenever the -addrsURL or -addrsFile addresses are reloaded")
+	monitorPrefixesStr   = flag.String("addrPrefixes", "", "the address prefixes to monitor for (comma separated)")
+	alertTemplate        = flag.String("alertTemplate", "", "the name of the templates of the -templatesFile/-webhookTemplatesFile (keyed by '<kind>@<name>') rendering the alerts of the default group, falling back to the unnamed templates")
+	addrTemplatesStr     = flag.String("addrTemplates", "", "the names of the templates rendering the alerts of single monitored addresses of the default group, taking precedence over -alertTemplate (comma separated 'address=name' entries)")
+	addrTagsStr          = flag.String("addrTags", "", "the tags (prefixes) required by monitored addresses, which then only match txs whose tag starts with it (comma separated 'address=tag' entries)")
+	tagPattern           = flag.String("tagPattern", "", "the regular expression matched against the decoded tag of matched txs, including the match and its captured groups (e.g. '^INV(?P<invoice>[0-9]+)') in tx alerts")
+	matchObsoleteTag     = flag.Bool("matchObsoleteTag", false, "whether txs whose tag doesn't match the required tags and -tagPattern are matched by their obsolete tag instead, including it in alerts if it differs from the tag")
+	requireTagPattern    = flag.Bool("requireTagPattern", false, "whether to only match txs whose decoded tag matches the -tagPattern")
+	shadowAddrsStr       = flag.String("shadowAddrs", "", "the candidate addresses which are matched, logged and counted like monitored addresses but never alerted on (comma separated)")
+	ignoreAddrsStr       = flag.String("ignoreAddrs", "", "the addresses to never alert on, even if monitored or matching a prefix (comma separated)")
+	parseErrLogIntervStr = flag.String("parseErrorLogInterval", "0", "the interval at which repetitions of an identical parse error are logged as a rolled-up count after its first occurrence (0 logs every parse error)")
+	explainMatch         = flag.Bool("explainMatch", false, "whether to log the match decision for every seen tx")
+	notifyConnEvents     = flag.Bool("notifyConnectionEvents", false, "whether to send connection state changes (connected, disconnected, reconnecting, subscribed) as notifications")
+	bundleReassembly     = flag.Bool("bundleReassembly", false, "whether to reassemble complete bundles and send one alert per bundle instead of per tx")
+	conflictWindowStr    = flag.String("conflictWindow", "0", "the window in which a monitored address spending value in two different bundles is alerted about as a conflicting spend (a possible double spend), 0 disables the detection")
+	debounceWindowStr    = flag.String("debounceWindow", "0", "the window for which tx alerts are held to cancel or merge them with the alerts of related txs (see -debounceRelated) arriving within it (0 disables the debounce)")
+	debounceRelated      = flag.String("debounceRelated", "bundle,reversal", "the comma separated criteria relating a tx alert to a held one with -debounceWindow: 'bundle' (a tx of the same bundle, merged into one alert) and 'reversal' (a tx on the same address with the opposite value, cancelling both alerts)")
+	bundleAggregateStr   = flag.String("bundleAggregateWindow", "0", "the window in which the tx alerts of a group sharing a bundle hash are buffered to send a single bundle summary of all of them instead (0 disables the aggregation)")
+	bundleTimeoutStr     = flag.String("bundleTimeout", "1m", "the duration after which incomplete bundles are dropped when reassembling bundles")
+	spendAlerts          = flag.Bool("spendAlerts", false, "whether to send a distinct high priority spend alert for bundles in which monitored addresses are inputs (requires -bundleReassembly)")
+	notifier             = flag.String("notifier", notifierSlack, "the chat platform of the -slackWebhookURI, the groups' slackWebhookURI and the -spendSlackWebhookURI: 'slack', 'discord' (their msgs are formatted for the platform) or 'generic' (they receive the JSON payloads like the -webhookURI and the groups' webhookURI)")
+	slackColors          = flag.Bool("slackColors", false, "whether to send Slack msgs as attachments whose color bar reflects the alert's severity per the -pagerDutyRulesFile rules: green for 'info', yellow for 'warning', suspicious txs and events about the monitor itself, red for 'error' and 'critical'")
+	spendSlackWebhookURI = flag.String("spendSlackWebhookURI", "", "the webhook URI of the high priority Slack channel to which spend alerts are sent instead of the group's")
+	correlateReattaches  = flag.Bool("correlateReattachments", false, "whether to treat txs sharing a bundle hash as the same transfer, alerting only once per bundle and address instead of again for every reattachment")
+	reattachWindowStr    = flag.String("reattachmentWindow", "24h", "how long alerted bundles are remembered to recognize their reattachments with -correlateReattachments")
+	dedupWindowStr       = flag.String("dedupWindow", "10m", "the window in which an alert about a tx (or bundle) already alerted about isn't sent again, e.g. as the node replays its recent txs after a reconnect")
+	dedupDisable         = flag.Bool("dedupDisable", false, "whether to disable suppressing the alerts already sent within the -dedupWindow")
+	dedupMaxEntries      = flag.Int("dedupMaxEntries", 100000, "the max. number of entries of the caches of alerted bundles (-correlateReattachments) and sent alerts (at-most-once delivery), bounding their memory, the oldest entries are evicted first")
+	bundleSenders        = flag.Bool("bundleSenders", false, "whether to include the sending (input) addresses in bundle alerts in which a monitored address receives value (requires -bundleReassembly)")
+	includeRawTrytes     = flag.Bool("includeRawTrytes", false, "whether to include the raw trytes of the tx in the generic webhook payloads")
+	hashTokenIndex       = flag.Int("hashTokenIndex", 1, "the index of the hash token in the frames of the 'trytes' topic, counting the trytes as 0, for publishers appending further tokens: 1 is the token following the trytes with no further tokens allowed, 0 detects the first token after the trytes which is a complete hash")
+	frameFormat          = flag.String("frameFormat", frameFormatTrytes, "the format of the txs in the frames of the -topic: 'trytes' (as published by the node) or 'json' (a JSON object of the tx's address, value, hash, bundle, tag and timestamp, as re-emitted by gateways)")
+	nodePublicKey        = flag.String("nodePublicKey", "", "the hex or base64 encoded Ed25519 public key of the node, enables verifying the signature carried by every frame as its last token, dropping frames without a valid one")
+	verifyTxHashes       = flag.Bool("verifyTxHashes", false, "whether to recompute the hash of every tx from its trytes and drop txs whose hash in the frame doesn't match, guarding against spoofed frames")
+	multiMatchPolicy     = flag.String("multiMatchPolicy", multiMatchAll, "what to do with txs matching several watch groups: alert 'all' of them or only the 'first' one in the order of their definition (the default group first)")
+	suspiciousTxsPolicy  = flag.String("suspiciousTxs", suspiciousTxsFlag, "what to do with txs with corrupt key fields (value, timestamps): 'flag' them in alerts or 'skip' them (logging the skipped txs on monitored addresses)")
+	maxAgeStr            = flag.String("maxAge", "0", "the max. age of txs by their attachment timestamp, older ones (e.g. stale reattachments of long settled transfers) are skipped while txs without a valid attachment timestamp are processed (0 disables the limit)")
+	pprofAddr            = flag.String("pprofAddr", "", "the address on which to serve the pprof handlers and expvar counters (e.g. 'localhost:6060'), disabled if empty")
+	controlAddr          = flag.String("controlAddr", "", "the address on which to serve the API adding and removing monitored addresses of the default group at runtime on /addrs (e.g. 'localhost:6061'), disabled if empty")
+	controlToken         = flag.String("controlToken", "", "the bearer token required by the API of the -controlAddr, only optional if it's a loopback address")
+	metricsAddr          = flag.String("metricsAddr", "", "the address on which to serve the metrics for Prometheus on /metrics (e.g. ':9311'), disabled if empty")
+	apiToken             = flag.String("apiToken", "", "the bearer token authorizing the mutating endpoints of the debug server")
+	allowInject          = flag.Bool("allowInject", false, "whether to serve POST /inject on the debug server, running synthetic txs through the pipeline for drills (requires -apiToken)")
+	slackRateLimit       = flag.Float64("slackRateLimit", 0, "the max. number of msgs per second sent to Slack, excess msgs wait for their turn within the -notifyDeadline (0 disables the limit)")
+	slackBurst           = flag.Int("slackBurst", 1, "the number of msgs which may be sent to Slack in a burst before the rate limit kicks in")
+	reconnectRateLimit   = flag.Float64("reconnectRateLimit", 0, "the max. number of reconnect attempts per second across all streams, excess attempts wait for their turn on top of their backoff (0 disables the limit)")
+	reconnectBurst       = flag.Int("reconnectBurst", 1, "the number of reconnect attempts across all streams which may be made in a burst before the -reconnectRateLimit kicks in")
+	recordFile           = flag.String("recordFile", "", "the path to a file to which every received ZMQ message is appended (base64 encoded, one per line)")
+	recordMaxSizeMB      = flag.Int("recordMaxSizeMB", 0, "the size in MiB after which the -recordFile recording is rotated (archived gzip compressed), 0 for no limit")
+	recordMaxAgeStr      = flag.String("recordMaxAge", "0", "the age after which the -recordFile recording is rotated (archived gzip compressed), 0 for no limit")
+	recordMaxArchives    = flag.Int("recordMaxArchives", 0, "the max. number of archived -recordFile recordings to keep, the oldest ones are pruned first, 0 to keep all")
+	replayFile           = flag.String("replayFile", "", "the path to a recording to replay through matching instead of connecting to the node, printing a report of the alerts which would have been sent")
+	maxMsgLength         = flag.Int("maxMsgLength", 40000, "the max. length of a notification msg, longer msgs are truncated")
+	decodeTags           = flag.Bool("decodeTag", false, "whether to include the tag decoded as ASCII in alerts (the raw tag trytes are shown if they don't decode to printable ASCII)")
+	initialConnRetries   = flag.Int("initialConnectRetries", 0, "the number of times the initial dial/subscription to the node is retried before giving up")
+	initialConnDelayStr  = flag.String("initialConnectDelay", "5s", "the delay in between retries of the initial dial/subscription to the node")
+	dailyFirstOnly       = flag.Bool("dailyFirstOnly", false, "whether to only alert on the first tx per address and calendar day, further txs are summarized in the next day's first alert")
+	maintenanceWindows   = flag.String("maintenanceWindows", "", "the recurring maintenance windows during which alerts are suppressed (comma separated, '[days ]HH:MM-HH:MM', e.g. 'Sun 02:00-04:00,Mon-Fri 23:30-00:30')")
+	maintenanceTimezone  = flag.String("maintenanceTimezone", "Local", "the timezone (e.g. 'Europe/Berlin') of the -maintenanceWindows")
+	offHours             = flag.String("offHours", "", "the recurring off hours during which only alerts of at least the -offHoursMinSeverity are sent immediately, the others are deferred to a digest (same format as -maintenanceWindows, e.g. 'Mon-Fri 18:00-08:00,Sat-Sun 00:00-00:00')")
+	offHoursTimezone     = flag.String("offHoursTimezone", "Local", "the timezone (e.g. 'Europe/Berlin') of the -offHours and the -offHoursDigestTime")
+	offHoursMinSeverity  = flag.String("offHoursMinSeverity", "error", "the min. severity (per the -pagerDutyRulesFile rules) of the alerts sent immediately during the -offHours")
+	offHoursDigestTime   = flag.String("offHoursDigestTime", "08:00", "the time of day (HH:MM) at which the alerts deferred during the -offHours are sent as a digest per group")
+	spentAddrsFile       = flag.String("spentAddrsFile", "", "the path to the file persisting the bundles the monitored addresses were spent from in, enables an address reuse alert whenever a monitored address is spent from in another bundle")
+	addrsMaxShrink       = flag.Float64("addrsMaxShrink", 0, "the max. percentage (e.g. 50) by which a reload of the -addrsURL/-addrsFile may shrink the loaded addresses, a reload shrinking them by more (e.g. of a truncated file) is refused, keeping the last loaded addresses and notifying the operators (0 for no limit)")
+	maxAddresses         = flag.Int("maxAddresses", 1000000, "the max. number of monitored addresses of all groups including the ones loaded from -addrsURL/-addrsFile, exceeding it fails the startup (or keeps the last loaded addresses on a reload) to catch misconfigured address lists (0 for no limit)")
+	firstSeenFile        = flag.String("firstSeenFile", "", "the path to the file persisting the monitored addresses seen so far, enables a distinct first activity alert for the first tx ever seen on each monitored address")
+	dailyTimezone        = flag.String("dailyTimezone", "Local", "the timezone (e.g. 'Europe/Berlin') defining calendar days for -dailyFirstOnly")
+	addrSetBackend       = flag.String("addrSet", addrSetMap, "the backend of the monitored address sets: 'map' or 'bloom' (bloom filter plus compact sorted set, for huge watch lists)")
+	baselineMultiple     = flag.Float64("baselineMultiple", 0, "the multiple of the typical value of an address (an EMA of the magnitudes of its recent values) a value tx must exceed to be alerted about (0 alerts about all txs)")
+	baselineAlpha        = flag.Float64("baselineAlpha", 0.1, "the smoothing factor of the typical value of an address for -baselineMultiple, the weight of the latest value in between 0 and 1")
+	baselineMinTxs       = flag.Int("baselineMinTxs", 5, "the number of value txs establishing the typical value of an address for -baselineMultiple, all of them are alerted about")
+	bloomFPRate          = flag.Float64("bloomFPRate", 0.001, "the false positive rate of the bloom filter used by the 'bloom' address set")
+	noMatchTimeoutStr    = flag.String("noMatchTimeout", "0", "the duration after which an alert is sent if no monitored address matched within it, as a liveness signal of the monitoring itself (0 disables the alert)")
+	matchRatioWindowStr  = flag.String("matchRatioWindow", "0", "the window over which the ratio of the txs matching a monitored address is compared to its baseline of the preceding windows, alerting about sharp deviations (0 disables the alert)")
+	matchRatioDeviation  = flag.Float64("matchRatioDeviation", 10, "the factor by which the ratio of matched txs of a -matchRatioWindow must rise above or drop below its baseline to be alerted about")
+	matchRatioMinTxs     = flag.Int("matchRatioMinTxs", 1000, "the min. number of txs seen within a -matchRatioWindow for its ratio of matched txs to be evaluated, windows with fewer txs are skipped")
+	reconnectAlertThres  = flag.Int("reconnectAlertThreshold", 0, "the number of reconnect attempts within the reconnect alert window above which a connection instability alert is sent (0 disables the alert)")
+	reconnectAlertWinStr = flag.String("reconnectAlertWindow", "10m", "the window in which reconnect attempts are counted for the connection instability alert")
+	httpMaxIdleConns     = flag.Int("httpMaxIdleConns", 16, "the max. number of idle (keep-alive) connections per host kept open by the notification HTTP client")
+	httpMaxInFlight      = flag.Int("httpMaxInFlightPerHost", 0, "the max. number of concurrent requests per destination host of the notification HTTP client, further requests wait for one of them to complete (0 for no limit)")
+	httpCAFile           = flag.String("httpCAFile", "", "the file of the PEM encoded CA certificates the notification HTTP client solely trusts instead of the system trust store")
+	httpPinnedCerts      = flag.String("httpPinnedCerts", "", "comma separated host=fingerprint pins of the SHA-256 fingerprints (hex) of the certificates accepted from the hosts by the notification HTTP client, rejecting other certificates")
+	httpIdleTimeoutStr   = flag.String("httpIdleConnTimeout", "90s", "how long idle (keep-alive) connections of the notification HTTP client are kept open")
+	httpTimeoutStr       = flag.String("httpTimeout", "30s", "the timeout of a single notification HTTP request (0 disables the timeout)")
+	slackTimeoutStr      = flag.String("slackTimeout", "10s", "the timeout of sending a single notification to Slack (0 only bounds it by -httpTimeout and -notifyDeadline)")
+	webhookTimeoutStr    = flag.String("webhookTimeout", "10s", "the timeout of sending a single notification to the generic webhook (0 only bounds it by -httpTimeout and -notifyDeadline)")
+	slackMinIntervalStr  = flag.String("slackMinInterval", "0", "the min. spacing in between two notifications sent to Slack, pacing e.g. the alerts queued up during an outage (0 disables the spacing)")
+	webhookMinIntervStr  = flag.String("webhookMinInterval", "0", "the min. spacing in between two notifications sent to the generic webhook (0 disables the spacing)")
+	shutdownTimeoutStr   = flag.String("shutdownTimeout", "0", "the deadline for shutting down after SIGINT/SIGTERM, after which pending notifications are abandoned and, if still not done shortly after, the state is flushed and the monitor exits non-zero (0 disables the deadline)")
+	notifyDeadlineStr    = flag.String("notifyDeadline", "0", "the deadline for sending an event to all notification backends, after which the remaining ones are abandoned (0 disables the deadline)")
+	configFile           = flag.String("config", "", "the YAML config file of the options keyed by their flag names (as printed by -printDefaultConfig), options given as flags override the file's")
+	printConfig          = flag.Bool("printDefaultConfig", false, "whether to only print a sample YAML config of every option with its default value to stdout")
+	instanceLabelFlag    = flag.String("instanceLabel", "", "the label identifying this monitor instance (e.g. its region) in every notification and structured event, the hostname if empty")
+	instanceID           = flag.Int("instanceID", 0, "the index of this replica (0 to -replicaCount - 1) when sharding the alerts across replicas")
+	replicaCount         = flag.Int("replicaCount", 1, "the number of replicas across which the alerts are sharded by address (bundle in bundle reassembly mode), 1 disables sharding")
+	shardOverlap         = flag.Int("shardOverlap", 0, "the number of additional replicas also handling every shard, for redundancy")
+	startupJitterStr     = flag.String("startupJitter", "0", "the max. random delay before connecting to the node, staggering the startup of replicas")
+	deliverySemantics    = flag.String("deliverySemantics", deliveryBestEffort, "the delivery semantics of alerts: 'best-effort' (up to 3 attempts of the chat and webhook sends), 'at-least-once' (retry failed sends, possibly duplicating alerts) or 'at-most-once' (persist alerts as sent to -deliveryStateFile before sending them, never duplicating alerts)")
+	queueDir             = flag.String("queueDir", "", "the directory persisting the requests of notifications until they're delivered with at-least-once delivery, redelivering the ones left over by a crash or failed for good on startup")
+	notifyQueueSize      = flag.Int("notifyQueueSize", 0, "the number of events queued for a background worker sending the notifications, so that slow backends don't hold up the processing of the stream (0 sends them synchronously)")
+	queueOverflowPolicy  = flag.String("queueOverflowPolicy", overflowBlock, "the policy applied to events once the -notifyQueueSize is reached: 'block', 'drop-oldest', 'drop-newest' or 'spill-to-disk'")
+	spillFile            = flag.String("spillFile", "", "the path to the file to which events are appended with the 'spill-to-disk' -queueOverflowPolicy, from which they're queued again once the queue drained")
+	undeliveredFile      = flag.String("undeliveredFile", "", "the path to a file to which events are appended (one JSON object per line) if none of their notification backends accepted them")
+	redeliverUndelivered = flag.Bool("redeliverUndelivered", false, "whether to resend the events of the -undeliveredFile to their groups' targets once the backends accept notifications again")
+	deliveryStateFile    = flag.String("deliveryStateFile", "", "the path to the file persisting the IDs of the sent alerts with at-most-once delivery")
+	redisAddr            = flag.String("redisAddr", "", "the address (host:port) of a Redis shared by replicas, enables deduplicating alerts across them")
+	redisDedupTTLStr     = flag.String("redisDedupTTL", "1h", "the window in which alerts are deduplicated across replicas via -redisAddr")
+	webhookTemplatesFile = flag.String("webhookTemplatesFile", "", "the path to a JSON file of generic webhook body templates (text/template syntax rendering JSON) by event kind, overriding the built-in payloads")
+	templatesFile        = flag.String("templatesFile", "", "the path to a JSON file of Slack msg templates (text/template syntax) by event kind, overriding the built-in msgs")
+	strict               = flag.Bool("strict", false, "whether to fail fast on any parse error, malformed frame, tx hash mismatch or suspicious tx (and to refuse to start with a clock skew above -maxClockSkew or a failed self-test of the tx parsing) by exiting non-zero instead of tolerating it, for test and staging environments")
+	ntpServer            = flag.String("ntpServer", "", "the NTP server (host[:port]) to check the system clock against on startup, stamping alerts with the NTP corrected time advanced by the monotonic clock (empty uses the unchecked system clock)")
+	maxClockSkewStr      = flag.String("maxClockSkew", "1s", "the skew of the system clock against the -ntpServer above which a warning is logged (strict mode refuses to start instead)")
+	validateOnly         = flag.Bool("validate", false, "whether to only validate the configuration, printing any problems and exiting non-zero if there are any, without connecting to the node")
+	watchGroupsFile      = flag.String("groupsFile", "", "the path to a JSON file defining additional watch groups with their own addresses, filters and notification targets")
 )
 
 const (
-	trytesSubTopic = "trytes"
+	trytesSubTopic   = "trytes"
+	txTrytesSubTopic = "tx_trytes"
+)
+
+const (
+	frameFormatTrytes = "trytes"
+	frameFormatJSON   = "json"
 )
 
 func mustParseDuration(str string, name string) time.Duration {
 	dur, err := time.ParseDuration(str)
 	if err != nil {
-		log.Fatalf("unable to parse %s string '%s': %s", name, str, err)
+		fatalf("unable to parse %s string '%s': %s", name, str, err)
 	}
 	return dur
 }
@@ -49,140 +220,297 @@ func mustParseDuration(str string, name string) time.Duration {
 func main() {
 	flag.Parse()
 
-	connRetryInterval := mustParseDuration(*connRetryIntervalStr, "connection retry interval")
-	dialTimeout := mustParseDuration(*dialTimeoutStr, "dial timeout")
+	if *printConfig {
+		printDefaultConfig(os.Stdout)
+		return
+	}
 
-	monitorAddrSplit := strings.Split(*monitorAddrsStr, ",")
-	monitorAddrs := make(map[string]struct{})
-	for _, monitorAddr := range monitorAddrSplit {
-		monitorAddrs[strings.TrimSpace(monitorAddr)] = struct{}{}
+	groups, problems := loadConfig()
+	if *validateOnly {
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		infof("configuration is valid")
+		return
 	}
+	if len(problems) > 0 {
+		fatalf("aborting due to %d configuration problem(s)", len(problems))
+	}
+
+	runSelfTest()
+	if *ntpServer != "" {
+		checkClock(*ntpServer, mustParseDuration(*maxClockSkewStr, "max. clock skew"))
+	}
+	instanceLabel = resolveInstanceLabel(*instanceLabelFlag)
+	queue, err := initNotifications()
+	if err != nil {
+		fatalf("%s", err)
+	}
+
+	m, err := newMonitor(groups)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	if *replayFile != "" {
+		if err := replayRecording(m, *replayFile); err != nil {
+			fatalf("replay failed: %s", err)
+		}
+		return
+	}
+
+	recorder, closeSinks := openSinks(groups)
+	defer closeSinks()
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
+	shutdownTimeout := mustParseDuration(*shutdownTimeoutStr, "shutdown timeout")
 	go func() {
 		<-sigs
 		cancelFunc()
-	}()
-
-	sub := zmq4.NewSub(ctx, zmq4.WithDialerTimeout(dialTimeout), zmq4.WithDialerRetry(1))
-	defer func() {
-		if err := sub.Close(); err != nil {
-			log.Printf("could not close ZMQ socket successfully: %s", err)
+		if shutdownTimeout > 0 {
+			enforceShutdownDeadline(shutdownTimeout)
 		}
 	}()
 
-	log.Printf("dialing to ZMQ socket %s", *nodeURI)
-	if err := sub.Dial(*nodeURI); err != nil {
-		log.Fatalf("can't dial ZMQ URI: %s", err)
+	if err := m.start(ctx); err != nil {
+		fatalf("%s", err)
+	}
+	defer m.Close()
+	if prices != nil {
+		go prices.watch(ctx)
+	}
+	if queue != nil {
+		go queue.redeliver(ctx)
 	}
 
-	log.Printf("subscribing to '%s' topic", trytesSubTopic)
-	if err := sub.SetOption(zmq4.OptionSubscribe, trytesSubTopic); err != nil {
-		log.Fatalf("subscription failed: %s", err)
+	streams, frames, err := openStreams(ctx)
+	defer closeStreams(streams)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		fatalf("%s", err)
 	}
+	m.run(ctx, frames, recorder)
+}
 
-	log.Println("address watcher started")
-	defer log.Println("address watcher shutdown")
-	for ctx.Err() == nil {
-		msg, err := sub.Recv()
+// openSinks opens the -recordFile, the sinks of the matched txs and the queue of the notification worker, as
+// configured. The returned func closes them, draining the queue before the sinks are closed.
+func openSinks(groups []*watchGroup) (*frameRecorder, func()) {
+	dialTimeout := mustParseDuration(*dialTimeoutStr, "dial timeout")
+	var closers []func()
+	var recorder *frameRecorder
+	if *recordFile != "" {
+		var err error
+		maxAge := mustParseDuration(*recordMaxAgeStr, "record max. age")
+		recorder, err = newFrameRecorder(*recordFile, int64(*recordMaxSizeMB)<<20, maxAge, *recordMaxArchives)
 		if err != nil {
-			if !errors.Is(err, io.EOF) {
-				log.Printf("could not receive message: %v", err)
-				continue
-			}
+			fatalf("unable to record ZMQ stream: %s", err)
+		}
+		closers = append(closers, closeOnce("recording", recorder))
+	}
+
+	if *kafkaBrokers != "" {
+		kafkaSink = newKafkaProducer(parseAddrList(*kafkaBrokers), *kafkaTopic, dialTimeout)
+		closers = append(closers, closeOnce("kafka producer", kafkaSink))
+	}
 
-			log.Println("the remote server closed the connection")
-			reconnect(sub, connRetryInterval)
-			log.Println("successfully reconnected")
-			continue
+	if *unixSocketOut != "" {
+		unixSocketSink = newUnixSocketWriter(*unixSocketOut, dialTimeout)
+		closers = append(closers, func() { unixSocketSink.Close() })
+	}
+	if *fifoOut != "" {
+		fifoSink = newFIFOWriter(*fifoOut, dialTimeout)
+		closers = append(closers, func() { fifoSink.Close() })
+	}
+	if *notifyQueueSize > 0 {
+		var spill *undeliveredLog
+		if *queueOverflowPolicy == overflowSpill {
+			var err error
+			if spill, err = openUndeliveredLog(*spillFile, "spilled", groups, true); err != nil {
+				fatalf("%s", err)
+			}
+		}
+		notifyWorker = newNotificationWorker(*notifyQueueSize, *queueOverflowPolicy, spill)
+		// drained before the sinks are closed
+		closers = append(closers, func() { notifyWorker.Close() })
+	}
+	return recorder, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
 		}
+	}
+}
 
-		tx, err := extractTransaction(string(msg.Bytes()))
-		if err != nil {
-			log.Printf("unable to parse transaction from ZMQ stream: %s", err)
-			continue
+// openStreams opens a stream of the -node URIs, or one per node with the -nodeMode 'fanin', and connects them after
+// the -startupJitter. The frames of all streams are received on the returned channel until the given context is done,
+// the streams are returned for closing them even if connecting failed.
+func openStreams(ctx context.Context) ([]*stream, <-chan streamFrame, error) {
+	connRetryInterval := mustParseDuration(*connRetryIntervalStr, "connection retry interval")
+	dialTimeout := mustParseDuration(*dialTimeoutStr, "dial timeout")
+	initialConnDelay := mustParseDuration(*initialConnDelayStr, "initial connect delay")
+	startupJitter := mustParseDuration(*startupJitterStr, "startup jitter")
+	if *reconnectAlertThres > 0 {
+		reconnects = newReconnectTracker(*reconnectAlertThres, mustParseDuration(*reconnectAlertWinStr, "reconnect alert window"))
+	}
+	connRetryMaxInterval = mustParseDuration(*connRetryMaxIntStr, "connection retry max. interval")
+	if maxDowntime := mustParseDuration(*maxDowntimeStr, "max. downtime"); maxDowntime > 0 {
+		downtimes = newDowntimeTracker(maxDowntime, mustParseDuration(*downtimeWindowStr, "downtime window"))
+		go downtimes.watch(ctx)
+	}
+	if *reconnectRateLimit > 0 {
+		reconnectLimiter = newTokenBucket(*reconnectRateLimit, *reconnectBurst)
+	}
+
+	nodes := parseAddrList(*nodeURI)
+	var streams []*stream
+	if *nodeMode == nodeModeFanIn {
+		for _, node := range nodes {
+			streams = append(streams, newStream(ctx, []string{node}, node, dialTimeout))
 		}
+	} else {
+		streams = append(streams, newStream(ctx, nodes, "", dialTimeout))
+	}
 
-		if tx.Value == 0 && *monitorOnlyValueTx {
-			continue
+	if startupJitter > 0 {
+		jitter := rand.New(rand.NewSource(time.Now().UnixNano() + int64(*instanceID)))
+		delay := time.Duration(jitter.Int63n(int64(startupJitter)))
+		infof("delaying startup by %v", delay)
+		select {
+		case <-ctx.Done():
+			return streams, nil, ctx.Err()
+		case <-time.After(delay):
 		}
+	}
 
-		if _, monitored := monitorAddrs[tx.Address]; monitored {
-			log.Printf("seen tx %s on monitored address %s", tx.Hash, tx.Address)
-			if err := sendSlackMessage(tx.Hash, tx.Address, tx.Bundle); err != nil {
-				log.Printf("could not send slack webhook payload: %s", err)
-			}
-			continue
+	for _, s := range streams {
+		if err := s.connect(ctx, *initialConnRetries, initialConnDelay); err != nil {
+			return streams, nil, err
 		}
+	}
 
-		if *logAnySeenTxs {
-			log.Println(tx.Hash, tx.Address)
+	// the Monitor isn't safe for concurrent use, the frames of all streams are processed by its run loop
+	frames := make(chan streamFrame)
+	idleProbeInterval := mustParseDuration(*idleProbeIntervalStr, "idle probe interval")
+	maxIdle := mustParseDuration(*maxIdleStr, "max. idle duration")
+	for _, s := range streams {
+		go s.receive(ctx, frames, connRetryInterval)
+		if idleProbeInterval > 0 {
+			go s.probeWhenIdle(ctx, idleProbeInterval, connRetryInterval)
+		}
+		if maxIdle > 0 {
+			go s.reconnectWhenSilent(ctx, maxIdle, connRetryInterval)
+		}
+		if *preferPrimary && len(s.nodes) > 1 {
+			go s.returnToPrimary(ctx, connRetryInterval, connRetryInterval)
 		}
 	}
+	return streams, frames, nil
 }
 
-type slackWebhookPayload struct {
-	Text string `json:"text"`
+// closeStreams closes the given streams.
+func closeStreams(streams []*stream) {
+	for _, s := range streams {
+		if err := s.Close(); err != nil {
+			errorf("could not close ZMQ socket successfully: %s", err)
+		}
+	}
 }
 
-var webhooktemplate = `monitoring:
-- saw tx <%s|%s>
-- address <%s|%s>
-- bundle <%s|%s>
-`
-
-func sendSlackMessage(txHash string, address string, bundle string) error {
-	txURI := fmt.Sprintf("%s/%s", *txExplorerURI, txHash)
-	bundleURI := fmt.Sprintf("%s/%s", *bundleExplorerURI, bundle)
-	addrURI := fmt.Sprintf("%s/%s", *addrExplorerURI, address)
-	jsonWebHookPayload, err := json.Marshal(&slackWebhookPayload{
-		Text: fmt.Sprintf(webhooktemplate, txURI, txHash, addrURI, address, bundleURI, bundle)},
-	)
-	if err != nil {
-		return fmt.Errorf("unable to serialize slack webhook payload: %w", err)
+// slackSeverityColors are the attachment colors of the alert severities.
+var slackSeverityColors = map[string]string{"info": "good", "warning": "warning", "error": "danger", "critical": "danger"}
+
+// slackColor returns the attachment color of the given alert according to its severity, raised to at least
+// 'warning' for anomalies. Empty if Slack colors are disabled.
+func slackColor(in *severityInput, anomaly bool) string {
+	if !*slackColors {
+		return ""
 	}
-	res, err := http.Post(*slackWebhookURI, "application/json", bytes.NewReader(jsonWebHookPayload))
-	if err != nil {
-		return fmt.Errorf("unable to POST slack webhook payload: %w", err)
+	severity, _ := evaluateSeverity(in)
+	if anomaly && severity == "info" {
+		severity = "warning"
 	}
-	if res.StatusCode != 200 {
-		defer res.Body.Close()
-		bodyContent, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return fmt.Errorf("unable to extract error from response content from POSTing slack webhook payload: %w", err)
+	return slackSeverityColors[severity]
+}
+
+// truncatedSuffix marks msgs which had to be cut off at the max. msg length.
+const truncatedSuffix = "...(truncated)"
+
+// truncateMsg appends the given footer to the given msg, cutting the msg off so that both fit into the given max.
+// length. The length is counted in chars as the chat platforms do, so that no multi-byte char is split. A footer
+// leaving no room for the msg gets the msg cut off entirely, exceeding the max. length by the suffix and footer.
+func truncateMsg(text string, footer string, max int) string {
+	if utf8.RuneCountInString(text)+utf8.RuneCountInString(footer) > max {
+		cut := max - utf8.RuneCountInString(truncatedSuffix) - utf8.RuneCountInString(footer)
+		if cut < 0 {
+			cut = 0
 		}
-		return fmt.Errorf("unable to POST slack webhook payload: %s", bodyContent)
+		text = string([]rune(text)[:cut]) + truncatedSuffix
+	}
+	return text + footer
+}
+
+// slackLimiter throttles all msgs sent to Slack, nil if unlimited.
+var slackLimiter *tokenBucket
+
+// the placeholders by which explorer URIs may position the ID of the linked entity,
+// URIs without a placeholder get the ID appended as their last path segment.
+const (
+	hashPlaceholder = "{hash}"
+	addrPlaceholder = "{address}"
+)
+
+// explorerURL returns the URL of the entity with the given ID on the explorer with the given URI.
+func explorerURL(uri string, id string) string {
+	if strings.Contains(uri, hashPlaceholder) || strings.Contains(uri, addrPlaceholder) {
+		return strings.NewReplacer(hashPlaceholder, id, addrPlaceholder, id).Replace(uri)
 	}
+	return strings.TrimSuffix(uri, "/") + "/" + id
+}
 
-	return nil
+// explorerLink renders a Slack link to the given entity on the explorer,
+// followed by a link to the same entity on the mirror explorer if one is defined.
+// If explorer links are disabled, the plain ID is rendered instead.
+func explorerLink(explorerURI string, mirrorURI string, id string) string {
+	if !*formatExplorerLinks {
+		return id
+	}
+	link := fmt.Sprintf("<%s|%s>", withLinkSuffix(explorerURL(explorerURI, id)), id)
+	if mirrorURI != "" {
+		link += fmt.Sprintf(" (<%s|mirror>)", withLinkSuffix(explorerURL(mirrorURI, id)))
+	}
+	return link
 }
 
-func reconnect(sub zmq4.Socket, connRetryInterval time.Duration) {
-	for {
-		log.Println("trying to reconnect...")
-		if err := sub.Dial(*nodeURI); err != nil {
-			log.Printf("dial attempt failed: %s...retrying in %v", err, connRetryInterval)
-			time.Sleep(connRetryInterval)
-			continue
-		}
-		if err := sub.SetOption(zmq4.OptionSubscribe, "trytes"); err != nil {
-			log.Printf("subscription failed: %s...retrying in %v", err, connRetryInterval)
-			time.Sleep(connRetryInterval)
-			continue
-		}
-		break
+// withLinkSuffix appends the -explorerLinkSuffix to the given explorer URL, joining a query suffix to the
+// URL's query if it already has one.
+func withLinkSuffix(url string) string {
+	suffix := *explorerLinkSuffix
+	if strings.HasPrefix(suffix, "?") && strings.Contains(url, "?") {
+		suffix = "&" + suffix[1:]
 	}
+	return url + suffix
 }
 
-func extractTransaction(trytesTopicFrame string) (*transaction.Transaction, error) {
-	trytesTopicFrame = strings.TrimPrefix(trytesTopicFrame, "trytes ")
-	frameSplit := strings.Split(trytesTopicFrame, " ")
-	tx, err := transaction.AsTransactionObject(frameSplit[0], frameSplit[1])
-	if err != nil {
-		return nil, err
+// truncateLines appends as many of the given lines to the header as fit into max characters,
+// ending the text with a footer stating the number of omitted lines if not all of them fit.
+func truncateLines(header string, lines []string, max int) string {
+	var text strings.Builder
+	text.WriteString(header)
+	// counted in chars like truncateMsg
+	length := utf8.RuneCountInString(header)
+	for i, line := range lines {
+		footer := fmt.Sprintf("...and %d more\n", len(lines)-i)
+		lineLength := utf8.RuneCountInString(line)
+		remaining := len(lines) - i - 1
+		if length+lineLength > max || (remaining > 0 && length+lineLength+utf8.RuneCountInString(footer) > max) {
+			text.WriteString(footer)
+			break
+		}
+		text.WriteString(line)
+		length += lineLength
 	}
-	return tx, nil
+	return text.String()
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a recurring time range on a set of weekdays, in minutes since midnight.
+// Windows ending before they start span midnight and belong to the weekday they start on.
+type maintenanceWindow struct {
+	days       [7]bool
+	start, end int
+}
+
+// maintenanceSchedule is a set of maintenance windows during which alerts are suppressed.
+type maintenanceSchedule struct {
+	loc     *time.Location
+	windows []maintenanceWindow
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseMaintenanceSchedule parses the given comma separated list of windows in the form '[days ]HH:MM-HH:MM',
+// where the optional days are a weekday ('Sun') or a range of weekdays ('Mon-Fri'), defaulting to every day.
+func parseMaintenanceSchedule(str string, loc *time.Location) (*maintenanceSchedule, error) {
+	schedule := &maintenanceSchedule{loc: loc}
+	for _, entry := range parseAddrList(str) {
+		var window maintenanceWindow
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			for i := range window.days {
+				window.days[i] = true
+			}
+		case 2:
+			if err := parseWeekdays(fields[0], &window.days); err != nil {
+				return nil, fmt.Errorf("invalid maintenance window '%s': %w", entry, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid maintenance window '%s'", entry)
+		}
+
+		times := strings.Split(fields[len(fields)-1], "-")
+		if len(times) != 2 {
+			return nil, fmt.Errorf("invalid maintenance window '%s': time range must be 'HH:MM-HH:MM'", entry)
+		}
+		var err error
+		if window.start, err = parseClock(times[0]); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window '%s': %w", entry, err)
+		}
+		if window.end, err = parseClock(times[1]); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window '%s': %w", entry, err)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+func parseWeekdays(str string, days *[7]bool) error {
+	bounds := strings.Split(strings.ToLower(str), "-")
+	first, ok := weekdays[bounds[0]]
+	if !ok || len(bounds) > 2 {
+		return fmt.Errorf("unknown weekday(s) '%s'", str)
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, ok = weekdays[bounds[1]]; !ok {
+			return fmt.Errorf("unknown weekday '%s'", bounds[1])
+		}
+	}
+	for day := first; ; day = (day + 1) % 7 {
+		days[day] = true
+		if day == last {
+			return nil
+		}
+	}
+}
+
+// parseClock parses the given 'HH:MM' time of day into minutes since midnight.
+func parseClock(str string) (int, error) {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s'", str)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// active reports whether the given time lies within one of the maintenance windows.
+func (s *maintenanceSchedule) active(now time.Time) bool {
+	now = now.In(s.loc)
+	minute := now.Hour()*60 + now.Minute()
+	today, yesterday := now.Weekday(), (now.Weekday()+6)%7
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// spans midnight
+		if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceEvent is the generic webhook payload of entering or leaving a maintenance window.
+type maintenanceEvent struct {
+	Event string    `json:"event"`
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+}
+
+var maintenanceTemplate = `monitoring:
+- maintenance window %s, alerts are %s
+`
+
+// watch notifies the operators whenever a maintenance window is entered or left, until the given context is done.
+func (s *maintenanceSchedule) watch(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	inWindow := false
+	for {
+		if active := s.active(time.Now()); active != inWindow {
+			inWindow = active
+			state, alerts := "left", "sent again"
+			if active {
+				state, alerts = "entered", "suppressed"
+			}
+			infof("maintenance window %s, alerts are %s", state, alerts)
+			event := &maintenanceEvent{Event: "maintenance", State: state, Time: alertTime()}
+			notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(maintenanceTemplate, state, alerts)), event)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
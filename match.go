@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// addrMatcher decides whether a tx's address is monitored.
+// Rules are evaluated in the following order, the first applicable rule wins:
+//  1. ignored addresses are never matched (deny beats allow)
+//  2. exactly monitored addresses are matched
+//  3. addresses starting with a monitored prefix are matched (exact beats prefix)
+//  4. everything else is not matched
+//
+// Matched addresses which require a tag are only matched by txs whose tag starts with it. If a tag match is required,
+// matched addresses are only matched by txs whose decoded tag matches the tag pattern.
+type addrMatcher struct {
+	exact    addrLookup
+	prefixes []string
+	ignored  map[string]struct{}
+	// the tag (prefix) required by an address
+	tags map[string]string
+	// the pattern the decoded tag is matched against to extract references, if set
+	tagPattern      *regexp.Regexp
+	requireTagMatch bool
+}
+
+// matchDecision describes the outcome of evaluating an address against the addrMatcher's rules.
+type matchDecision struct {
+	Matched bool
+	Reason  string
+}
+
+// match evaluates the given address of a tx with the given tag.
+func (m *addrMatcher) match(addr string, tag string) matchDecision {
+	decision := m.matchAddr(addr)
+	if !decision.Matched {
+		return decision
+	}
+	if required, has := m.tags[addr]; has && !strings.HasPrefix(tag, required) {
+		return matchDecision{Matched: false, Reason: fmt.Sprintf("%s but tag doesn't start with the required tag %s", decision.Reason, required)}
+	}
+	if m.requireTagMatch && !m.tagPattern.MatchString(displayTag(tag)) {
+		return matchDecision{Matched: false, Reason: fmt.Sprintf("%s but decoded tag doesn't match the required pattern %s", decision.Reason, m.tagPattern)}
+	}
+	return decision
+}
+
+// matchTx evaluates the given tx by its address and tag, falling back to its obsolete tag (if different) with
+// -matchObsoleteTag.
+func (m *addrMatcher) matchTx(tx *transaction.Transaction) matchDecision {
+	decision := m.match(tx.Address, tx.Tag)
+	if decision.Matched || !*matchObsoleteTag || tx.ObsoleteTag == tx.Tag {
+		return decision
+	}
+	if obsolete := m.match(tx.Address, tx.ObsoleteTag); obsolete.Matched {
+		obsolete.Reason += " (by its obsolete tag)"
+		return obsolete
+	}
+	return decision
+}
+
+// tagCaptures matches the given tag, decoded as by displayTag, against the tag pattern and returns the whole match
+// as '0' and the captured groups by their name, or by their index if unnamed. Nil if there's no pattern or match.
+func (m *addrMatcher) tagCaptures(tag string) map[string]string {
+	if m.tagPattern == nil {
+		return nil
+	}
+	submatches := m.tagPattern.FindStringSubmatch(displayTag(tag))
+	if submatches == nil {
+		return nil
+	}
+	captures := make(map[string]string, len(submatches))
+	for i, name := range m.tagPattern.SubexpNames() {
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		captures[name] = submatches[i]
+	}
+	return captures
+}
+
+func (m *addrMatcher) matchAddr(addr string) matchDecision {
+	if _, ignored := m.ignored[addr]; ignored {
+		return matchDecision{Matched: false, Reason: "address is on the ignore list"}
+	}
+	if m.exact.has(addr) {
+		return matchDecision{Matched: true, Reason: "address is monitored exactly"}
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(addr, prefix) {
+			return matchDecision{Matched: true, Reason: fmt.Sprintf("address matches monitored prefix %s", prefix)}
+		}
+	}
+	return matchDecision{Matched: false, Reason: "address is not monitored"}
+}
+
+// parseAddrList splits the given comma separated list, trimming and skipping empty entries.
+func parseAddrList(str string) []string {
+	var list []string
+	for _, entry := range strings.Split(str, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+// parseAddrTags parses the given comma separated list of 'address=tag' entries.
+func parseAddrTags(str string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, entry := range parseAddrList(str) {
+		split := strings.SplitN(entry, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("entry '%s' is not of the form 'address=tag'", entry)
+		}
+		tags[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
+	}
+	return tags, nil
+}
+
+// formatTagMatch renders the given tag captures as 'name=value' pairs, the whole match first.
+func formatTagMatch(captures map[string]string) string {
+	names := make([]string, 0, len(captures))
+	for name := range captures {
+		if name != "0" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	formatted := captures["0"]
+	for _, name := range names {
+		formatted += fmt.Sprintf(" %s=%s", name, captures[name])
+	}
+	return formatted
+}
+
+func addrSet(addrs []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
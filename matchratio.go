@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the number of preceding windows whose mean ratio is the baseline, a baseline is established once all of them
+	// passed
+	matchRatioBaselineWindows = 6
+	// the min. number of matched txs (expected ones for a drop) of a window for a deviation to be alerted about, so
+	// that the sporadic matches of quiet addresses don't alert
+	matchRatioMinMatches = 10
+)
+
+// matchRatioWatchdog detects that the fraction of the txs matching a monitored address deviates sharply from its
+// recent baseline, e.g. as everything matches after a parsing regression or nothing as the addresses are wrong.
+type matchRatioWatchdog struct {
+	window    time.Duration
+	deviation float64
+	minTxs    int64
+	// the txs seen and matched within the current window, accessed atomically
+	total   int64
+	matches int64
+	// the ratios of the preceding windows, the oldest first
+	ratios []float64
+	// the direction of the deviation alerted about, empty if none
+	alerted string
+}
+
+// matchRatioEvent is the generic webhook payload of a match ratio alert.
+type matchRatioEvent struct {
+	Event string `json:"event"`
+	// 'high' if the ratio rose above the baseline, 'low' if it dropped below it
+	Direction string    `json:"direction"`
+	Window    string    `json:"window"`
+	Ratio     float64   `json:"ratio"`
+	Baseline  float64   `json:"baseline"`
+	Matched   int64     `json:"matched"`
+	Total     int64     `json:"total"`
+	Time      time.Time `json:"time"`
+}
+
+var matchRatioTemplate = `monitoring:
+- %d of %d txs (%.4f%%) matched a monitored address within the last %v, deviating from the baseline of %.4f%%
+`
+
+func newMatchRatioWatchdog(window time.Duration, deviation float64, minTxs int) *matchRatioWatchdog {
+	return &matchRatioWatchdog{window: window, deviation: deviation, minTxs: int64(minTxs)}
+}
+
+// seen records a tx seen on the stream, matched whether it matched a monitored address.
+func (w *matchRatioWatchdog) seen(matched bool) {
+	atomic.AddInt64(&w.total, 1)
+	if matched {
+		atomic.AddInt64(&w.matches, 1)
+	}
+}
+
+// evaluate ends the current window, returning the event to alert about if its ratio deviates from the baseline by
+// more than the deviation factor while no alert was sent for the deviation yet. Windows with less than the min. txs
+// are skipped.
+func (w *matchRatioWatchdog) evaluate(now time.Time) *matchRatioEvent {
+	total, matches := atomic.SwapInt64(&w.total, 0), atomic.SwapInt64(&w.matches, 0)
+	if total < w.minTxs {
+		return nil
+	}
+	ratio := float64(matches) / float64(total)
+	defer func() {
+		w.ratios = append(w.ratios, ratio)
+		if len(w.ratios) > matchRatioBaselineWindows {
+			w.ratios = w.ratios[1:]
+		}
+	}()
+	if len(w.ratios) < matchRatioBaselineWindows {
+		return nil
+	}
+	var baseline float64
+	for _, r := range w.ratios {
+		baseline += r
+	}
+	baseline /= float64(len(w.ratios))
+
+	direction := ""
+	switch {
+	case ratio > baseline*w.deviation && matches >= matchRatioMinMatches:
+		direction = "high"
+	case ratio < baseline/w.deviation && baseline*float64(total) >= matchRatioMinMatches:
+		direction = "low"
+	}
+	if direction == "" {
+		if w.alerted != "" {
+			w.alerted = ""
+			infof("the ratio of matched txs is back at its baseline: %d of %d txs matched within the last %v", matches, total, w.window)
+		}
+		return nil
+	}
+	if direction == w.alerted {
+		return nil
+	}
+	w.alerted = direction
+	return &matchRatioEvent{
+		Event: "match_ratio", Direction: direction, Window: w.window.String(),
+		Ratio: ratio, Baseline: baseline, Matched: matches, Total: total, Time: now,
+	}
+}
+
+// watch evaluates the ratio every window, notifying the operators about deviations, until the given context is done.
+// As the deviating ratios become part of the baseline, a lasting change (e.g. added addresses) stops deviating after
+// a few windows.
+func (w *matchRatioWatchdog) watch(ctx context.Context) {
+	ticker := time.NewTicker(w.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		event := w.evaluate(alertTime())
+		if event == nil {
+			continue
+		}
+		warnf("the ratio of matched txs deviates from its baseline: %d of %d txs (%.4f%%) matched within the last %v, baseline %.4f%%",
+			event.Matched, event.Total, event.Ratio*100, w.window, event.Baseline*100)
+		notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(matchRatioTemplate, event.Matched, event.Total, event.Ratio*100, w.window, event.Baseline*100)), event)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	suspiciousTxsSeen        = expvar.NewInt("suspicious_txs_seen")
+	suspiciousTxsSkipped     = expvar.NewInt("suspicious_txs_skipped")
+	parseErrors              = expvar.NewInt("parse_errors")
+	malformedFrames          = expvar.NewInt("malformed_frames")
+	invalidTxHashes          = expvar.NewInt("invalid_tx_hashes")
+	unverifiedFrames         = expvar.NewInt("unverified_frames")
+	kafkaProduceErrors       = expvar.NewInt("kafka_produce_errors")
+	unixSocketWriteErrors    = expvar.NewInt("unix_socket_write_errors")
+	fifoEventsDropped        = expvar.NewInt("fifo_events_dropped")
+	reattachmentsCorrelated  = expvar.NewInt("reattachments_correlated")
+	staleTxsSkipped          = expvar.NewInt("stale_txs_skipped")
+	unknownAgeTxs            = expvar.NewInt("unknown_age_txs")
+	belowBaselineSuppressed  = expvar.NewInt("below_baseline_alerts_suppressed")
+	bundlesAggregated        = expvar.NewInt("bundles_aggregated")
+	debouncedCancelled       = expvar.NewInt("debounced_alerts_cancelled")
+	conflictingSpends        = expvar.NewInt("conflicting_spends")
+	addressReuses            = expvar.NewInt("address_reuses")
+	zeroValueTxs             = expvar.NewInt("zero_value_txs")
+	reattachmentCacheEntries = expvar.NewInt("reattachment_cache_entries")
+	sentAlertsCacheEntries   = expvar.NewInt("sent_alerts_cache_entries")
+	duplicateAlerts          = expvar.NewInt("duplicate_alerts_skipped")
+	shadowMatches            = expvar.NewInt("shadow_matches")
+	maintenanceSuppressed    = expvar.NewInt("maintenance_alerts_suppressed")
+	mutedSuppressed          = expvar.NewInt("muted_alerts_suppressed")
+	deferredAlerts           = expvar.NewInt("deferred_alerts")
+	queuedNotifications      = expvar.NewInt("queued_notifications")
+	hostConcurrencyWaits     = expvar.NewInt("host_concurrency_waits")
+	undeliveredEvents        = expvar.NewInt("undelivered_events")
+	droppedEvents            = expvar.NewInt("dropped_events")
+	spilledEvents            = expvar.NewInt("spilled_events")
+	latestMilestoneIndex     = expvar.NewInt("latest_milestone_index")
+	pendingConfirmations     = expvar.NewInt("pending_confirmations")
+	unconfirmedAlertsDropped = expvar.NewInt("unconfirmed_alerts_dropped")
+	inclusionPollsDropped    = expvar.NewInt("inclusion_polls_dropped")
+	latestMilestoneTime      = expvar.NewInt("latest_milestone_time")
+	recordingBytes           = expvar.NewInt("recording_bytes")
+	recordingFrames          = expvar.NewInt("recording_frames")
+	streamLagMillis          = expvar.NewInt("stream_lag_ms")
+	txsSeen                  = expvar.NewInt("txs_seen")
+	txsMatched               = expvar.NewInt("txs_matched")
+	notificationFailures     = expvar.NewInt("notification_failures")
+	streamReconnects         = expvar.NewInt("reconnects")
+	forcedReconnects         = expvar.NewInt("forced_reconnects")
+)
+
+// parseErrorsByKind counts the frames which couldn't be parsed by the kind of parse error.
+var parseErrorsByKind = expvar.NewMap("parse_errors_by_kind")
+
+// streamLagHistogram counts the received txs by the bucket of their lag behind real time.
+var streamLagHistogram = expvar.NewMap("stream_lag_buckets")
+
+// connectionUp is the number of nodes subscribed to (i.e. 1 while subscribed to a single node, 0 while not)
+// and -1 while still initializing, i.e. until the first subscription succeeded.
+var connectionUp = expvar.NewInt("connection_up")
+
+// ready is set to 1 once the first subscription to a node succeeded.
+var ready int32
+
+var (
+	subscribedMu sync.Mutex
+	subscribed   = make(map[string]struct{})
+)
+
+func init() {
+	connectionUp.Set(-1)
+}
+
+// recordConnectionState updates the connection gauge and the readiness according to the given state
+// of the connection to the given node.
+func recordConnectionState(node string, state connState) {
+	recordConnChange(node, state)
+	subscribedMu.Lock()
+	defer subscribedMu.Unlock()
+	switch state {
+	case connStateSubscribed:
+		subscribed[node] = struct{}{}
+		atomic.StoreInt32(&ready, 1)
+	case connStateDisconnected, connStateReconnecting:
+		delete(subscribed, node)
+	default:
+		return
+	}
+	connectionUp.Set(int64(len(subscribed)))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// milestoneTracker tracks the latest milestone published by the node on the milestone topic, as a freshness
+// signal tying the monitor's health to the ledger's progress rather than just to the flow of msgs.
+type milestoneTracker struct {
+	topic   string
+	timeout time.Duration
+	// the latest milestone index, accessed atomically
+	index int64
+	// the last milestone hash, for topics publishing hashes (e.g. 'lmhs') instead of indexes
+	hash string
+	// the unix nanos of the last advance of the milestone, accessed atomically
+	lastAdvance int64
+}
+
+// milestoneStalledEvent is the generic webhook payload of a milestone stalled alert.
+type milestoneStalledEvent struct {
+	Event       string    `json:"event"`
+	Topic       string    `json:"topic"`
+	Index       int64     `json:"index"`
+	Window      string    `json:"window"`
+	LastAdvance time.Time `json:"lastAdvance"`
+	Time        time.Time `json:"time"`
+}
+
+var milestoneStalledTemplate = `monitoring:
+- the latest milestone (index %d on topic '%s') didn't advance within the last %v (since %s)
+`
+
+func newMilestoneTracker(topic string, timeout time.Duration) *milestoneTracker {
+	return &milestoneTracker{topic: topic, timeout: timeout, lastAdvance: time.Now().UnixNano()}
+}
+
+// handles tells whether the given frame was published on the milestone topic.
+func (t *milestoneTracker) handles(frame string) bool {
+	return strings.HasPrefix(frame, t.topic+" ")
+}
+
+// observe records the milestone of the given frame published on the milestone topic. The last token of the frame
+// is the milestone: an index (e.g. 'lmi <previous> <latest>') which advances if greater than the latest one,
+// or a hash (e.g. 'lmhs <hash>') which advances if different from the last one.
+func (t *milestoneTracker) observe(frame string, now time.Time) error {
+	tokens := strings.Fields(frame)
+	if len(tokens) < 2 {
+		return fmt.Errorf("unable to parse milestone from ZMQ stream: frame on topic '%s' has no milestone", t.topic)
+	}
+	milestone := tokens[len(tokens)-1]
+	index, err := strconv.ParseInt(milestone, 10, 64)
+	switch {
+	case err == nil:
+		if index <= atomic.LoadInt64(&t.index) {
+			return nil
+		}
+		atomic.StoreInt64(&t.index, index)
+		latestMilestoneIndex.Set(index)
+	case milestone == t.hash:
+		return nil
+	default:
+		t.hash = milestone
+	}
+	atomic.StoreInt64(&t.lastAdvance, now.UnixNano())
+	latestMilestoneTime.Set(now.Unix())
+	return nil
+}
+
+// watch notifies the operators once the milestone didn't advance within the timeout, until the given
+// context is done. Further alerts are only sent after the milestone advanced again.
+func (t *milestoneTracker) watch(ctx context.Context) {
+	interval := t.timeout / 4
+	if interval > 15*time.Second {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	alerted := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		lastAdvance := time.Unix(0, atomic.LoadInt64(&t.lastAdvance))
+		index := atomic.LoadInt64(&t.index)
+		stalled := time.Since(lastAdvance) > t.timeout
+		switch {
+		case stalled && !alerted:
+			alerted = true
+			warnf("the latest milestone (index %d) didn't advance within the last %v (since %s)", index, t.timeout, lastAdvance.Format(time.RFC3339))
+			event := &milestoneStalledEvent{
+				Event: "milestone_stalled", Topic: t.topic, Index: index,
+				Window: t.timeout.String(), LastAdvance: lastAdvance, Time: alertTime(),
+			}
+			notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(milestoneStalledTemplate, index, t.topic, t.timeout, lastAdvance.Format(time.RFC3339))), event)
+		case !stalled && alerted:
+			alerted = false
+			infof("the latest milestone is advancing again (index %d)", index)
+		}
+	}
+}
@@ -0,0 +1,895 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// Monitor runs the frames received from the ZMQ streams through parsing, validation, matching and notification to the
+// targets of the matching watch groups. It isn't safe for concurrent use.
+type Monitor struct {
+	groups    []*watchGroup
+	assembler *bundleAssembler
+	// remoteAddrs loads the default group's addresses from the -addrsURL or -addrsFile, if set
+	remoteAddrs *remoteAddrList
+	// controlledAddrs are the default group's addresses managed via the control API, if set
+	controlledAddrs *runtimeAddrs
+	// daily lets only the first alert per address and day through, if set
+	daily *dailyFilter
+	// maintenance suppresses alerts during its windows, if set
+	maintenance *maintenanceSchedule
+	// baseline lets only the alerts of value txs large relative to their address's typical value through, if set
+	baseline *baselineFilter
+	// aggregator buffers the tx alerts sharing a bundle to alert about them as one, if set
+	aggregator *bundleAggregator
+	// debouncer holds the tx alerts to cancel or merge them with the alerts of related txs, if set
+	debouncer *alertDebouncer
+	// offHours defers the alerts below its min. severity raised off hours to a digest, if set
+	offHours *offHoursGate
+	// shard restricts the alerts to the ones handled by this replica, if set
+	shard *shard
+	// reattachments drops the alerts of reattached bundles, if set
+	reattachments *reattachmentFilter
+	// firstSeen tracks the monitored addresses seen so far to send first activity alerts, if set
+	firstSeen *firstSeenStore
+	// noMatch is told about every match to detect the lack of matches, if set
+	noMatch *noMatchWatchdog
+	// matchRatio is told about every tx and whether it matched to detect deviations of the ratio, if set
+	matchRatio *matchRatioWatchdog
+	// activity counts the matched txs per monitored address to report the most active ones, if set
+	activity *activityTracker
+	// milestones tracks the frames published on the milestone topic, if set
+	milestones *milestoneTracker
+	// conflicts detects monitored addresses spending value in different bundles, if set
+	conflicts *conflictDetector
+	// spentAddrs detects monitored addresses spent from in more than one bundle, if set
+	spentAddrs *spentAddrsStore
+	// confirmations holds the tx alerts until their txs are confirmed, if set
+	confirmations *confirmationGate
+	// inclusion polls the confirmation of the alerted txs via the node API, if set
+	inclusion *inclusionPoller
+	// shadow holds the candidate addresses which are only logged and counted, if set
+	shadow addrLookup
+	// report collects the matches instead of notifying about them, if set
+	report *replayReport
+	// notifier receives the alerts which passed all filters, the groups' notification targets (notifyTargets) if nil
+	notifier func(group *watchGroup, payload interface{})
+	// mutes are the addresses muted via the API, none if nil
+	mutes *muteList
+	// recentAlerts is the cache of the alerts sent within the -dedupWindow, if set
+	recentAlerts *seenCache
+	// sentAlerts persists the IDs of the alerts sent with at-most-once delivery, if set
+	sentAlerts *sentLog
+	// closeSentAlerts closes the sentAlerts, if set
+	closeSentAlerts func()
+	// dedup deduplicates the alerts across replicas, if set
+	dedup *redisDedup
+	// parseErrLogs throttles the logs of the frames which couldn't be parsed, if set
+	parseErrLogs *logThrottle
+	// lag estimates the lag of the stream behind real time, if set
+	lag *streamLag
+	// maxAge skips the txs attached longer ago, if positive
+	maxAge time.Duration
+	// verifier verifies the frames received from the node before they're processed, if set
+	verifier frameVerifier
+}
+
+// newMonitor builds the Monitor of the given watch groups as configured by the flags, initializing the groups and
+// loading the default group's addresses from their source, if any. Replays only need the Monitor built, its watchers
+// and servers are started by start.
+func newMonitor(groups []*watchGroup) (*Monitor, error) {
+	httpTimeout := mustParseDuration(*httpTimeoutStr, "http timeout")
+	var remoteAddrs *remoteAddrList
+	if *addrsURL != "" {
+		// the default group's addresses are dropped by its init
+		remoteAddrs = newRemoteAddrList(*addrsURL, groups[0].Addrs, httpTimeout)
+	}
+	if *addrsFile != "" {
+		remoteAddrs = newFileAddrList(*addrsFile, groups[0].Addrs)
+	}
+	if remoteAddrs != nil {
+		if *maxAddresses > 0 {
+			// the loaded addresses share the limit with the other groups' ones
+			remoteAddrs.maxAddrs = *maxAddresses - monitoredAddrCount(groups[1:])
+		}
+		remoteAddrs.maxShrink = *addrsMaxShrink
+		if err := remoteAddrs.refresh(); err != nil {
+			return nil, fmt.Errorf("unable to load addresses from %s: %w", addrsSourceFlag(), err)
+		}
+	}
+	// the static addresses of the default group, listed by the control API unless loaded from a source, and the
+	// number of the other groups' addresses sharing the -maxAddresses with it, as the groups' init drops them
+	staticAddrs := normalizeAddrs(groups[0].Addrs)
+	otherAddrs := monitoredAddrCount(groups[1:])
+	for _, group := range groups {
+		group.init()
+		group.setNotifiers(*notifier, *spendSlackWebhookURI)
+	}
+	registerTemplateRefs(groups)
+	if *undeliveredFile != "" {
+		var err error
+		if undelivered, err = openUndeliveredLog(*undeliveredFile, "undelivered", groups, *redeliverUndelivered); err != nil {
+			return nil, err
+		}
+	}
+	if remoteAddrs != nil {
+		groups[0].matcher.exact = remoteAddrs
+		if *notifyAddrChanges {
+			// the -addrsURL may carry credentials, so the source is named by its flag
+			source := addrsSourceFlag()
+			remoteAddrs.onChange = func(added []string, removed []string) {
+				notifyAddrsChanged(source, added, removed)
+			}
+		}
+	}
+	var controlledAddrs *runtimeAddrs
+	if *controlAddr != "" {
+		list := func() []string { return staticAddrs }
+		if remoteAddrs != nil {
+			list = remoteAddrs.addrs
+		}
+		controlledAddrs = newRuntimeAddrs(groups[0].matcher.exact, list)
+		if *maxAddresses > 0 {
+			controlledAddrs.maxAddrs = *maxAddresses - otherAddrs
+		}
+		groups[0].matcher.exact = controlledAddrs
+	}
+
+	m := &Monitor{
+		groups:          groups,
+		assembler:       newBundleAssembler(mustParseDuration(*bundleTimeoutStr, "bundle timeout")),
+		remoteAddrs:     remoteAddrs,
+		controlledAddrs: controlledAddrs,
+		notifier:        notifyTargets,
+		mutes:           newMuteList(),
+		maxAge:          mustParseDuration(*maxAgeStr, "max. age"),
+	}
+	if *redisAddr != "" {
+		dialTimeout := mustParseDuration(*dialTimeoutStr, "dial timeout")
+		m.dedup = newRedisDedup(*redisAddr, mustParseDuration(*redisDedupTTLStr, "redis dedup TTL"), dialTimeout, *deliverySemantics == deliveryAtMostOnce)
+	}
+	if shadowAddrs := parseAddrList(*shadowAddrsStr); len(shadowAddrs) > 0 {
+		m.shadow = newAddrLookup(normalizeAddrs(shadowAddrs))
+	}
+	if *dailyFirstOnly {
+		loc, _ := time.LoadLocation(*dailyTimezone)
+		m.daily = newDailyFilter(loc)
+	}
+
+	if *correlateReattaches {
+		m.reattachments = newReattachmentFilter(mustParseDuration(*reattachWindowStr, "reattachment window"), *dedupMaxEntries)
+	}
+
+	if *nodePublicKey != "" {
+		key, _ := parseNodePublicKey(*nodePublicKey)
+		m.verifier = &ed25519FrameVerifier{key: key}
+	}
+
+	if aggregateWindow := mustParseDuration(*bundleAggregateStr, "bundle aggregate window"); aggregateWindow > 0 {
+		m.aggregator = newBundleAggregator(aggregateWindow)
+	}
+
+	if debounceWindow := mustParseDuration(*debounceWindowStr, "debounce window"); debounceWindow > 0 {
+		related, _ := parseDebounceRelated(*debounceRelated)
+		m.debouncer = newAlertDebouncer(debounceWindow, related)
+	}
+
+	if *baselineMultiple > 0 {
+		m.baseline = newBaselineFilter(*baselineMultiple, *baselineAlpha, *baselineMinTxs)
+	}
+
+	if *replicaCount > 1 {
+		m.shard = &shard{instance: uint64(*instanceID), replicas: uint64(*replicaCount), overlap: uint64(*shardOverlap)}
+	}
+
+	if *maintenanceWindows != "" {
+		loc, _ := time.LoadLocation(*maintenanceTimezone)
+		m.maintenance, _ = parseMaintenanceSchedule(*maintenanceWindows, loc)
+	}
+
+	if *offHours != "" {
+		loc, _ := time.LoadLocation(*offHoursTimezone)
+		schedule, _ := parseMaintenanceSchedule(*offHours, loc)
+		digestAt, _ := parseClock(*offHoursDigestTime)
+		m.offHours = newOffHoursGate(schedule, *offHoursMinSeverity, digestAt)
+	}
+
+	// replays run the detectors as well, on in-memory copies of their stores
+	if err := m.initAddrDetectors(*firstSeenFile, *spentAddrsFile, mustParseDuration(*conflictWindowStr, "conflict window"), *replayFile != ""); err != nil {
+		return nil, err
+	}
+
+	if interval := mustParseDuration(*parseErrLogIntervStr, "parse error log interval"); interval > 0 {
+		m.parseErrLogs = newLogThrottle(interval, levelError, logFields{Event: "parse_error"})
+	}
+	return m, nil
+}
+
+// start opens the Monitor's delivery state and starts its watchdogs, trackers and servers, which run until the given
+// context is done. Close closes the delivery state once done.
+func (m *Monitor) start(ctx context.Context) error {
+	if *deliverySemantics == deliveryAtMostOnce {
+		sentAlerts, err := openSentLog(*deliveryStateFile, *dedupMaxEntries)
+		if err != nil {
+			return fmt.Errorf("unable to load sent alerts: %w", err)
+		}
+		m.sentAlerts = sentAlerts
+		m.closeSentAlerts = closeOnce("delivery state file", sentAlerts)
+	}
+
+	if dedupWindow := mustParseDuration(*dedupWindowStr, "dedup window"); dedupWindow > 0 && !*dedupDisable {
+		m.recentAlerts = newSeenCache(dedupWindow)
+		go m.recentAlerts.janitor(ctx)
+	}
+
+	if activityRetention := mustParseDuration(*activityRetentionStr, "activity retention"); activityRetention > 0 {
+		activitySeries = newActivityStore(activityRetention)
+	}
+	if *pprofAddr != "" {
+		startDebugServer(ctx, *pprofAddr, m.groups, m.mutes)
+	}
+	if m.controlledAddrs != nil {
+		startControlServer(ctx, *controlAddr, *controlToken, m.controlledAddrs)
+	}
+	if *metricsAddr != "" {
+		startMetricsServer(ctx, *metricsAddr, m.groups)
+	}
+
+	if m.maintenance != nil {
+		go m.maintenance.watch(ctx)
+	}
+
+	if m.offHours != nil {
+		go m.offHours.watch(ctx)
+	}
+
+	if noMatchTimeout := mustParseDuration(*noMatchTimeoutStr, "no match timeout"); noMatchTimeout > 0 {
+		m.noMatch = newNoMatchWatchdog(noMatchTimeout)
+		go m.noMatch.watch(ctx)
+	}
+
+	if matchRatioWindow := mustParseDuration(*matchRatioWindowStr, "match ratio window"); matchRatioWindow > 0 {
+		m.matchRatio = newMatchRatioWatchdog(matchRatioWindow, *matchRatioDeviation, *matchRatioMinTxs)
+		go m.matchRatio.watch(ctx)
+	}
+
+	if *minConfirmations > 0 {
+		m.confirmations = newConfirmationGate(*minConfirmations, mustParseDuration(*confirmTimeoutStr, "confirmation timeout"), m.notifyTx)
+	}
+	if *confirmURI != "" {
+		httpTimeout := mustParseDuration(*httpTimeoutStr, "http timeout")
+		m.inclusion = newInclusionPoller(ctx, newNodeAPI(*confirmURI, httpTimeout), mustParseDuration(*confirmTimeoutStr, "confirmation timeout"))
+	}
+
+	if topNInterval := mustParseDuration(*topNIntervalStr, "top N interval"); topNInterval > 0 {
+		m.activity = newActivityTracker(topNInterval, *topN)
+		go m.activity.watch(ctx)
+	}
+
+	m.lag = newStreamLag(mustParseDuration(*maxStreamLagStr, "max. stream lag"))
+
+	if *milestoneTopic != "" {
+		m.milestones = newMilestoneTracker(*milestoneTopic, mustParseDuration(*milestoneTimeoutStr, "milestone timeout"))
+		if m.milestones.timeout > 0 {
+			go m.milestones.watch(ctx)
+		}
+	}
+
+	if addrsURLRefresh := mustParseDuration(*addrsURLRefreshStr, "addrs URL refresh interval"); *addrsURL != "" && addrsURLRefresh > 0 {
+		go m.remoteAddrs.refreshPeriodically(ctx, addrsURLRefresh)
+	}
+	if m.remoteAddrs != nil {
+		hups := make(chan os.Signal, 1)
+		signal.Notify(hups, syscall.SIGHUP)
+		go m.remoteAddrs.reloadOnSignal(ctx, hups)
+	}
+	if *addrsFileWatch {
+		if err := m.remoteAddrs.reloadOnChange(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the delivery state opened by start, if any.
+func (m *Monitor) Close() {
+	if m.closeSentAlerts != nil {
+		m.closeSentAlerts()
+	}
+}
+
+// maxFlushInterval bounds the interval of the ticks flushing the buffered aggregates and held alerts.
+const maxFlushInterval = 15 * time.Second
+
+// flushInterval returns the interval of the ticks flushing the alerts buffered for the given window.
+func flushInterval(window time.Duration) time.Duration {
+	if interval := window / 4; interval < maxFlushInterval {
+		return interval
+	}
+	return maxFlushInterval
+}
+
+// run processes the frames received on the given channel and the injected ones until the given context is done,
+// recording the received ones with the given recorder if set. The buffered bundle aggregates and held alerts are
+// flushed on ticks in between, as the Monitor isn't safe for concurrent use.
+func (m *Monitor) run(ctx context.Context, frames <-chan streamFrame, recorder *frameRecorder) {
+	var aggregateTicks <-chan time.Time
+	if m.aggregator != nil {
+		ticker := time.NewTicker(flushInterval(m.aggregator.window))
+		defer ticker.Stop()
+		aggregateTicks = ticker.C
+	}
+	var debounceTicks <-chan time.Time
+	if m.debouncer != nil {
+		ticker := time.NewTicker(flushInterval(m.debouncer.window))
+		defer ticker.Stop()
+		debounceTicks = ticker.C
+	}
+
+	infof("address watcher started")
+	defer infof("address watcher shutdown")
+	for {
+		var f streamFrame
+		select {
+		case <-ctx.Done():
+			if m.aggregator != nil {
+				m.flushAggregates(time.Now().Add(m.aggregator.window))
+			}
+			if m.debouncer != nil {
+				m.flushDebounced(time.Now().Add(m.debouncer.window))
+			}
+			return
+		case now := <-aggregateTicks:
+			m.flushAggregates(now)
+			continue
+		case now := <-debounceTicks:
+			m.flushDebounced(now)
+			continue
+		case f = <-frames:
+		case f = <-injectedFrames:
+		}
+
+		if recorder != nil && f.node != injectedNode {
+			if err := recorder.record(f.frame); err != nil {
+				errorf("could not record message: %s", err)
+			}
+		}
+
+		if err := m.processFrame(string(f.frame), f.node); err != nil {
+			parseErrors.Add(1)
+			strictFail("%s", err)
+			if m.parseErrLogs != nil {
+				m.parseErrLogs.log(err.Error(), time.Now())
+				continue
+			}
+			logEvent(levelError, logFields{Event: "parse_error"}, "%s", err)
+		}
+	}
+}
+
+// ProcessFrame processes the given unlabeled frame, the returned error is only non-nil if the frame couldn't be parsed.
+func (m *Monitor) ProcessFrame(frame string) error {
+	return m.processFrame(frame, "")
+}
+
+// processFrame processes the given frame received from the given node (empty if unlabeled),
+// the returned error is only non-nil if the frame couldn't be parsed.
+func (m *Monitor) processFrame(frame string, node string) error {
+	// injected frames are authenticated by the API token instead
+	if m.verifier != nil && node != injectedNode {
+		verified, err := m.verifier.verify(frame)
+		if err != nil {
+			unverifiedFrames.Add(1)
+			strictFail("dropped frame: %s", err)
+			logEvent(levelError, logFields{Event: "unverified_frame"}, "dropped frame: %s", err)
+			return nil
+		}
+		frame = verified
+	}
+	if m.milestones != nil && m.milestones.handles(frame) {
+		return m.milestones.observe(frame, time.Now())
+	}
+	if m.confirmations != nil && m.confirmations.handles(frame) {
+		return m.confirmations.observe(frame, time.Now())
+	}
+	tx, err := extractTransaction(frame)
+	if errors.Is(err, errHashMismatch) {
+		invalidTxHashes.Add(1)
+		strictFail("dropped tx: %s", err)
+		logEvent(levelError, logFields{Event: "parse_error"}, "dropped tx: %s", err)
+		return nil
+	}
+	if errors.Is(err, errMalformedFrame) {
+		malformedFrames.Add(1)
+	}
+	if err != nil {
+		parseErrorsByKind.Add(parseErrorKind(err), 1)
+		return fmt.Errorf("unable to parse transaction from ZMQ stream: %w", err)
+	}
+	txsSeen.Add(1)
+
+	anomalies := txAnomalies(tx, time.Now())
+	if len(anomalies) > 0 {
+		suspiciousTxsSeen.Add(1)
+		strictFail("suspicious tx %s on address %s: %s", tx.Hash, tx.Address, strings.Join(anomalies, ", "))
+		if *suspiciousTxsPolicy == suspiciousTxsSkip {
+			suspiciousTxsSkipped.Add(1)
+			if m.matchesAnyGroup(tx) {
+				logEvent(levelWarn, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "warning: skipped suspicious tx %s on monitored address %s with value %d: %s", tx.Hash, tx.Address, tx.Value, strings.Join(anomalies, ", "))
+			} else if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "skipped suspicious tx %s on address %s: %s", tx.Hash, tx.Address, strings.Join(anomalies, ", "))
+			}
+			return nil
+		}
+	}
+
+	if m.lag != nil && node != injectedNode {
+		m.lag.observe(tx, time.Now())
+	}
+
+	if m.maxAge > 0 {
+		if age, known := attachmentAge(tx, time.Now()); known && age > m.maxAge {
+			staleTxsSkipped.Add(1)
+			if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "skipped tx %s on address %s: attached %v ago, before the -maxAge", tx.Hash, tx.Address, age.Truncate(time.Second))
+			}
+			return nil
+		} else if !known {
+			unknownAgeTxs.Add(1)
+		}
+	}
+
+	if *bundleReassembly {
+		if txs := m.assembler.add(tx); txs != nil {
+			alerted := false
+			for _, group := range m.groups {
+				if m.shard != nil && !m.shard.owns(txs[0].Bundle) {
+					if *explainMatch {
+						logEvent(levelInfo, logFields{Event: "bundle_skipped", Bundle: txs[0].Bundle, Group: group.Name}, "skipped bundle %s for group %s: handled by another replica", txs[0].Bundle, group.Name)
+					}
+					continue
+				}
+				summary := bundleAlert(group, txs)
+				var spend *spendSummary
+				if *spendAlerts {
+					spend = spendAlert(group, txs)
+				}
+				if (summary != nil || spend != nil) && alerted && *multiMatchPolicy == multiMatchFirst {
+					if *explainMatch {
+						logEvent(levelInfo, logFields{Event: "bundle_skipped", Bundle: txs[0].Bundle, Group: group.Name}, "skipped bundle %s for group %s: already matched by a group of higher priority", txs[0].Bundle, group.Name)
+					}
+					continue
+				}
+				alerted = alerted || summary != nil || spend != nil
+				if (summary != nil || spend != nil) && m.reattachments != nil && m.reattachments.reattached(group.Name, txs[0].Bundle, "", time.Now()) {
+					reattachmentsCorrelated.Add(1)
+					logEvent(levelInfo, logFields{Event: "bundle_skipped", Bundle: txs[0].Bundle, Group: group.Name}, "skipped alert for bundle %s (group %s): reattachment of an already alerted bundle", txs[0].Bundle, group.Name)
+					continue
+				}
+				if summary != nil {
+					summary.Node = node
+					logEvent(levelInfo, logFields{Event: "bundle_matched", Bundle: summary.Bundle, Group: group.Name}, "seen bundle %s transferring %d touching %d monitored address(es) receiving %d (group %s)", summary.Bundle, summary.Value, len(summary.Addresses), summary.Received, group.Name)
+					m.notifyBundle(group, summary)
+				}
+				if spend != nil {
+					spend.Node = node
+					logEvent(levelInfo, logFields{Event: "spend_matched", Bundle: spend.Bundle, Group: group.Name}, "seen bundle %s spending from %d monitored address(es) (group %s)", spend.Bundle, len(spend.Inputs), group.Name)
+					m.notifySpend(group, spend)
+				}
+			}
+		}
+	}
+
+	if m.shadow != nil && m.shadow.has(tx.Address) && (tx.Value != 0 || !*monitorOnlyValueTx) {
+		shadowMatches.Add(1)
+		logEvent(levelInfo, logFields{Event: "shadow_tx", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "seen tx %s on shadow address %s (not alerted)", tx.Hash, tx.Address)
+		if m.report != nil {
+			m.report.shadowTxs = append(m.report.shadowTxs, tx)
+		}
+	}
+
+	matched, firstActivity := false, false
+	var conflict *conflictEvent
+	var reuse *addrReuseEvent
+	unusual, baseline := true, 0.0
+	anyMatch := false
+	for _, group := range m.groups {
+		if tx.Value == 0 && group.OnlyValue && !group.ZeroValueAlerts {
+			if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "skipped tx %s on address %s for group %s: not a value tx", tx.Hash, tx.Address, group.Name)
+			}
+			continue
+		}
+
+		decision := group.matcher.matchTx(tx)
+		if *explainMatch {
+			logEvent(levelInfo, logFields{Event: "match_decision", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "match decision for tx %s on address %s for group %s: matched=%v (%s)", tx.Hash, tx.Address, group.Name, decision.Matched, decision.Reason)
+		}
+		if !decision.Matched {
+			continue
+		}
+		if m.noMatch != nil {
+			m.noMatch.matched(time.Now())
+		}
+		anyMatch = true
+		if reason := group.filteredValue(tx.Value); reason != "" {
+			if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "skipped tx %s on address %s for group %s: %s", tx.Hash, tx.Address, group.Name, reason)
+			}
+			continue
+		}
+		if m.shard != nil && !m.shard.owns(tx.Address) {
+			if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "skipped tx %s on address %s for group %s: handled by another replica", tx.Hash, tx.Address, group.Name)
+			}
+			continue
+		}
+
+		if matched && *multiMatchPolicy == multiMatchFirst {
+			if *explainMatch {
+				logEvent(levelInfo, logFields{Event: "tx_skipped", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "skipped tx %s on address %s for group %s: already matched by a group of higher priority", tx.Hash, tx.Address, group.Name)
+			}
+			continue
+		}
+
+		if !matched && m.firstSeen != nil {
+			firstActivity = m.firstSeen.markSeen(tx.Address)
+		}
+		if !matched && m.conflicts != nil && tx.Value < 0 {
+			conflict = m.conflicts.observe(tx, time.Now())
+		}
+		if !matched && m.spentAddrs != nil && tx.Value < 0 {
+			reuse = m.spentAddrs.markSpent(tx)
+		}
+		if !matched && m.activity != nil {
+			m.activity.observe(group.Name, tx.Address, tx.Value)
+		}
+		if !matched && activitySeries != nil {
+			activitySeries.observe(group.Name, tx.Address, tx.Value, time.Now())
+		}
+		if !matched && m.baseline != nil && tx.Value != 0 {
+			unusual, baseline = m.baseline.observe(tx.Address, tx.Value)
+		}
+		matched = true
+		logEvent(levelInfo, logFields{Event: "tx_matched", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "seen tx %s on monitored address %s (group %s)", tx.Hash, tx.Address, group.Name)
+		recordRecentMatch(group.Name, tx.Hash, tx.Address, tx.Value)
+		if firstActivity {
+			logEvent(levelInfo, logFields{Event: "first_activity", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "first activity ever on monitored address %s (group %s)", tx.Address, group.Name)
+			m.notifyFirstActivity(group, newFirstActivityEvent(group, tx))
+		}
+		if conflict != nil {
+			event := *conflict
+			event.Group = group.Name
+			m.notifyConflict(group, &event)
+		}
+		if reuse != nil {
+			event := *reuse
+			event.Group = group.Name
+			m.notifyAddrReuse(group, &event)
+		}
+		if tx.Value == 0 && group.ZeroValueAlerts {
+			event := newZeroValueEvent(group, tx)
+			event.Node = node
+			m.notifyZeroValue(group, event)
+			continue
+		}
+		if *bundleReassembly {
+			continue
+		}
+
+		if m.reattachments != nil && m.reattachments.reattached(group.Name, tx.Bundle, tx.Address, time.Now()) {
+			reattachmentsCorrelated.Add(1)
+			logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "skipped alert for tx %s on monitored address %s (group %s): reattachment of already alerted bundle %s", tx.Hash, tx.Address, group.Name, tx.Bundle)
+			continue
+		}
+
+		if !unusual {
+			belowBaselineSuppressed.Add(1)
+			logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "suppressed alert for tx %s on monitored address %s (group %s): value within %vx the address's baseline of %.0f", tx.Hash, tx.Address, group.Name, m.baseline.multiple, baseline)
+			continue
+		}
+
+		event := newTxEvent(group, tx, frame)
+		event.Node = node
+		if baseline > 0 {
+			event.Baseline = baseline
+		}
+		event.Suspicious = anomalies
+		if m.daily != nil {
+			allowed, summary := m.daily.allow(group.Name, tx.Address, time.Now())
+			if !allowed {
+				logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle, Group: group.Name}, "suppressed alert for tx %s on monitored address %s (group %s): already alerted today", tx.Hash, tx.Address, group.Name)
+				continue
+			}
+			event.PreviousDay = summary
+		}
+		if m.confirmations != nil {
+			m.confirmations.hold(group, event, time.Now())
+			continue
+		}
+		if m.aggregator != nil {
+			m.aggregator.add(group, event, time.Now())
+			continue
+		}
+		if m.debouncer != nil {
+			m.debounce(group, event)
+			continue
+		}
+		m.notifyTx(group, event)
+	}
+	if anyMatch {
+		txsMatched.Add(1)
+	}
+	if m.matchRatio != nil {
+		m.matchRatio.seen(anyMatch)
+	}
+	if matched {
+		return nil
+	}
+
+	if *logAnySeenTxs {
+		if !*logSeenTxDetails {
+			logEvent(levelInfo, logFields{Event: "tx_seen", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "%s %s", tx.Hash, tx.Address)
+			return nil
+		}
+		tag := tx.Tag
+		if *decodeTags {
+			tag = displayTag(tx.Tag)
+		}
+		logEvent(levelInfo, logFields{Event: "tx_seen", TxHash: tx.Hash, Address: tx.Address, Bundle: tx.Bundle}, "%s %s %d %s %s", tx.Hash, tx.Address, tx.Value, tag, tx.Bundle)
+	}
+	return nil
+}
+
+// matchesAnyGroup reports whether the given tx's address is monitored by any watch group.
+func (m *Monitor) matchesAnyGroup(tx *transaction.Transaction) bool {
+	for _, group := range m.groups {
+		if group.matcher.matchTx(tx).Matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseErrorKind returns the kind of the given error returned by extractTransaction, as counted in the metrics.
+func parseErrorKind(err error) string {
+	switch {
+	case errors.Is(err, errEmptyFrame):
+		return "empty_frame"
+	case errors.Is(err, errMissingHash):
+		return "missing_hash"
+	case errors.Is(err, errInvalidTrytes):
+		return "invalid_trytes"
+	case errors.Is(err, errInvalidJSON):
+		return "invalid_json"
+	case errors.Is(err, errTxParse):
+		return "tx_parse"
+	}
+	return "other"
+}
+
+func (m *Monitor) notifyTx(group *watchGroup, event *txEvent) {
+	if m.report != nil {
+		m.report.addTx(group, event.Transaction)
+		return
+	}
+	if m.inMaintenance() {
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: event.Hash, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed alert for tx %s on monitored address %s (group %s): maintenance window", event.Hash, event.Address, group.Name)
+		return
+	}
+	if m.mutes.muted(event.Address, time.Now()) {
+		mutedSuppressed.Add(1)
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: event.Hash, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed alert for tx %s on monitored address %s (group %s): address muted", event.Hash, event.Address, group.Name)
+		return
+	}
+	if !m.claimAlert("tx:" + group.Name + ":" + event.Hash) {
+		return
+	}
+	if m.offHours != nil {
+		summary := fmt.Sprintf("seen tx %s on monitored address %s with value %d", event.Hash, event.Address, event.Value)
+		if m.offHours.deferAlert(group, "tx", txSeverityInput(event), summary, time.Now()) {
+			return
+		}
+	}
+	alertedAt := time.Now()
+	m.notify(group, event)
+	if m.inclusion != nil {
+		m.inclusion.track(group, event, alertedAt)
+	}
+}
+
+func (m *Monitor) notifyBundle(group *watchGroup, summary *bundleSummary) {
+	if m.report != nil {
+		m.report.addBundle(group, summary)
+		return
+	}
+	if m.inMaintenance() {
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Bundle: summary.Bundle, Group: group.Name}, "suppressed alert for bundle %s (group %s): maintenance window", summary.Bundle, group.Name)
+		return
+	}
+	if m.mutes.allMuted(summary.Addresses, time.Now()) {
+		mutedSuppressed.Add(1)
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Bundle: summary.Bundle, Group: group.Name}, "suppressed alert for bundle %s (group %s): all of its monitored addresses muted", summary.Bundle, group.Name)
+		return
+	}
+	if !m.claimAlert("bundle:" + group.Name + ":" + summary.Bundle) {
+		return
+	}
+	if m.offHours != nil {
+		text := fmt.Sprintf("seen bundle %s transferring %d touching %d monitored address(es)", summary.Bundle, summary.Value, len(summary.Addresses))
+		if m.offHours.deferAlert(group, "bundle", bundleSeverityInput(summary), text, time.Now()) {
+			return
+		}
+	}
+	m.notify(group, summary)
+}
+
+func (m *Monitor) notifySpend(group *watchGroup, summary *spendSummary) {
+	if m.report != nil {
+		m.report.addSpend(group, summary)
+		return
+	}
+	if m.inMaintenance() {
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Bundle: summary.Bundle, Group: group.Name}, "suppressed spend alert for bundle %s (group %s): maintenance window", summary.Bundle, group.Name)
+		return
+	}
+	if m.mutes.allMuted(summary.Inputs, time.Now()) {
+		mutedSuppressed.Add(1)
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Bundle: summary.Bundle, Group: group.Name}, "suppressed spend alert for bundle %s (group %s): all of its spending monitored addresses muted", summary.Bundle, group.Name)
+		return
+	}
+	if !m.claimAlert("spend:" + group.Name + ":" + summary.Bundle) {
+		return
+	}
+	if m.offHours != nil {
+		text := fmt.Sprintf("spend of %d monitored address(es) in bundle %s", len(summary.Inputs), summary.Bundle)
+		if m.offHours.deferAlert(group, "spend", spendSeverityInput(summary), text, time.Now()) {
+			return
+		}
+	}
+	m.notify(group, summary)
+}
+
+// initAddrDetectors sets up the detectors of the monitored addresses' history, each if enabled: the first activity
+// of the first seen store of the given file, the conflicting spends within the given window and the address reuse of
+// the spent addresses store of the given file. With inMemory (e.g. for replays), the stores' files are only read.
+func (m *Monitor) initAddrDetectors(firstSeenPath string, spentAddrsPath string, conflictWindow time.Duration, inMemory bool) error {
+	if firstSeenPath != "" {
+		store, err := loadFirstSeenStore(firstSeenPath, inMemory)
+		if err != nil {
+			return fmt.Errorf("unable to load first seen addresses: %w", err)
+		}
+		m.firstSeen = store
+	}
+	if conflictWindow > 0 {
+		m.conflicts = newConflictDetector(conflictWindow)
+	}
+	if spentAddrsPath != "" {
+		store, err := loadSpentAddrsStore(spentAddrsPath, inMemory)
+		if err != nil {
+			return fmt.Errorf("unable to load spent addresses: %w", err)
+		}
+		m.spentAddrs = store
+	}
+	return nil
+}
+
+// notify sends the given alert payload of the given group to the Monitor's notifier.
+func (m *Monitor) notify(group *watchGroup, payload interface{}) {
+	if m.notifier == nil {
+		notifyTargets(group, payload)
+		return
+	}
+	m.notifier(group, payload)
+}
+
+// notifyTargets sends the given alert payload of the given group to the group's notification targets.
+func notifyTargets(group *watchGroup, payload interface{}) {
+	switch payload := payload.(type) {
+	case *txEvent:
+		group.notifyTx(payload)
+	case *bundleSummary:
+		group.notifyBundle(payload)
+	case *spendSummary:
+		group.notifySpend(payload)
+	case *firstActivityEvent:
+		group.notifyFirstActivity(payload)
+	case *zeroValueEvent:
+		group.notifyZeroValue(payload)
+	case *conflictEvent:
+		group.notifyConflict(payload)
+	case *addrReuseEvent:
+		group.notifyAddrReuse(payload)
+	default:
+		errorf("could not notify about alert payload %T: unknown kind of alert", payload)
+	}
+}
+
+func (m *Monitor) notifyFirstActivity(group *watchGroup, event *firstActivityEvent) {
+	if m.report != nil {
+		m.report.addFirstActivity(group, event)
+		return
+	}
+	if m.inMaintenance() {
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed first activity alert for address %s (group %s): maintenance window", event.Address, group.Name)
+		return
+	}
+	if m.mutes.muted(event.Address, time.Now()) {
+		mutedSuppressed.Add(1)
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed first activity alert for address %s (group %s): address muted", event.Address, group.Name)
+		return
+	}
+	if !m.claimAlert("firstActivity:" + group.Name + ":" + event.Address) {
+		return
+	}
+	m.notify(group, event)
+}
+
+func (m *Monitor) notifyZeroValue(group *watchGroup, event *zeroValueEvent) {
+	zeroValueTxs.Add(1)
+	logEvent(levelInfo, logFields{Event: "zero_value", TxHash: event.Tx, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "seen zero-value tx %s on monitored address %s (group %s)", event.Tx, event.Address, group.Name)
+	if m.report != nil {
+		m.report.addZeroValue(group, event)
+		return
+	}
+	if m.inMaintenance() {
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: event.Tx, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed zero-value alert for tx %s on monitored address %s (group %s): maintenance window", event.Tx, event.Address, group.Name)
+		return
+	}
+	if m.mutes.muted(event.Address, time.Now()) {
+		mutedSuppressed.Add(1)
+		logEvent(levelInfo, logFields{Event: "alert_suppressed", TxHash: event.Tx, Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "suppressed zero-value alert for tx %s on monitored address %s (group %s): address muted", event.Tx, event.Address, group.Name)
+		return
+	}
+	if !m.claimAlert("zeroValue:" + group.Name + ":" + event.Tx) {
+		return
+	}
+	m.notify(group, event)
+}
+
+// notifyConflict sends the given conflicting spend alert, which as a security event is neither suppressed by
+// maintenance windows nor deferred off hours.
+func (m *Monitor) notifyConflict(group *watchGroup, event *conflictEvent) {
+	conflictingSpends.Add(1)
+	logEvent(levelError, logFields{Event: "conflicting_spend", Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "error: conflicting spends of monitored address %s in bundles %s and %s (group %s)", event.Address, event.ConflictingBundle, event.Bundle, group.Name)
+	if m.report != nil {
+		m.report.addConflict(group, event)
+		return
+	}
+	if !m.claimAlert("conflict:" + group.Name + ":" + event.ConflictingBundle + ":" + event.Bundle) {
+		return
+	}
+	m.notify(group, event)
+}
+
+// notifyAddrReuse sends the given address reuse alert, which like a conflicting spend puts the funds of the address at
+// risk, so as a security event it's neither suppressed by maintenance windows or mutes nor deferred off hours.
+func (m *Monitor) notifyAddrReuse(group *watchGroup, event *addrReuseEvent) {
+	addressReuses.Add(1)
+	logEvent(levelWarn, logFields{Event: "address_reuse", Address: event.Address, Bundle: event.Bundle, Group: group.Name}, "warning: monitored address %s spent from for the %s time, in bundle %s (group %s)", event.Address, ordinal(event.Spends), event.Bundle, group.Name)
+	if m.report != nil {
+		m.report.addAddrReuse(group, event)
+		return
+	}
+	if !m.claimAlert("reuse:" + group.Name + ":" + event.Address + ":" + event.Bundle) {
+		return
+	}
+	m.notify(group, event)
+}
+
+// inMaintenance reports whether alerts are currently suppressed by a maintenance window, counting the suppression.
+func (m *Monitor) inMaintenance() bool {
+	if m.maintenance == nil || !m.maintenance.active(time.Now()) {
+		return false
+	}
+	maintenanceSuppressed.Add(1)
+	return true
+}
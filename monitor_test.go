@@ -0,0 +1,749 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-zeromq/zmq4"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+func TestMultiMatchPolicy(t *testing.T) {
+	defer func(policy string) { *multiMatchPolicy = policy }(*multiMatchPolicy)
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for policy, expected := range map[string][]string{
+		multiMatchAll:   {"exchange", "addrs", "prefixes"},
+		multiMatchFirst: {"exchange"},
+	} {
+		*multiMatchPolicy = policy
+		groups := []*watchGroup{
+			{Name: "ignoring", Addrs: []string{addr}, IgnoreAddrs: []string{addr}},
+			{Name: "exchange", Addrs: []string{addr}},
+			{Name: "addrs", Addrs: []string{addr}},
+			{Name: "prefixes", AddrPrefixes: []string{"AAA"}},
+		}
+		for _, group := range groups {
+			group.init()
+		}
+		m := &Monitor{groups: groups, report: newReplayReport(groups)}
+		if err := m.ProcessFrame(frame); err != nil {
+			t.Fatalf("%s: unexpected error: %s", policy, err)
+		}
+
+		var alerted []string
+		for _, group := range m.report.groups {
+			if len(m.report.txs[group]) != 0 {
+				alerted = append(alerted, group)
+			}
+		}
+		if strings.Join(alerted, ",") != strings.Join(expected, ",") {
+			t.Errorf("%s: expected alerts for groups %v but got %v", policy, expected, alerted)
+		}
+	}
+}
+
+func TestSeenCacheExpiry(t *testing.T) {
+	cache := newSeenCache(10 * time.Minute)
+	start := time.Now()
+	if !cache.add("tx:default:A", start) {
+		t.Fatal("first alert: expected to be new")
+	}
+	if cache.add("tx:default:A", start.Add(9*time.Minute)) {
+		t.Error("alert within the window: expected to be a duplicate")
+	}
+	if !cache.add("tx:default:B", start.Add(9*time.Minute)) {
+		t.Error("other alert: expected to be new")
+	}
+	cache.expire(start.Add(10 * time.Minute))
+	if len(cache.seen) != 1 {
+		t.Errorf("expected the expired alert to be forgotten, %d alert(s) left", len(cache.seen))
+	}
+	if !cache.add("tx:default:A", start.Add(10*time.Minute)) {
+		t.Error("alert after the window: expected to be new again")
+	}
+}
+
+func TestProcessFrameSkipsReplayedTxs(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	now := time.Now()
+	frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: 2}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := []*watchGroup{{Name: "default", Addrs: []string{addr}}}
+	groups[0].init()
+	var alerted []*txEvent
+	m := &Monitor{groups: groups, recentAlerts: newSeenCache(10 * time.Minute), notifier: func(group *watchGroup, payload interface{}) {
+		alerted = append(alerted, payload.(*txEvent))
+	}}
+
+	// the tx is received again as the node replays its recent txs after a reconnect
+	for _, f := range []string{frame, frame, other} {
+		if err := m.ProcessFrame(f); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(alerted) != 2 || alerted[0].Value != 1 || alerted[1].Value != 2 {
+		t.Errorf("expected the replayed tx's alert to be skipped once but got %d alert(s)", len(alerted))
+	}
+}
+
+func TestProcessFrameSkipsMutedAndSentAlerts(t *testing.T) {
+	muted, other := strings.Repeat("A", consts.HashTrytesSize), strings.Repeat("B", consts.HashTrytesSize)
+	now := time.Now()
+	var frames []string
+	for _, req := range []*injectRequest{{Address: muted, Value: 1}, {Address: other, Value: 2}} {
+		frame, _, err := buildInjectedFrame(req, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	sentAlerts, err := openSentLog(t.TempDir()+"/sent", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sentAlerts.Close()
+	groups := []*watchGroup{{Name: "default", Addrs: []string{muted, other}}}
+	groups[0].init()
+	var alerted []*txEvent
+	m := &Monitor{groups: groups, mutes: newMuteList(), sentAlerts: sentAlerts, notifier: func(group *watchGroup, payload interface{}) {
+		alerted = append(alerted, payload.(*txEvent))
+	}}
+	m.mutes.mute(muted, now.Add(time.Hour))
+
+	// the other tx is received again, its alert already being marked as sent
+	for _, f := range append(frames, frames[1]) {
+		if err := m.ProcessFrame(f); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(alerted) != 1 || alerted[0].Address != other {
+		t.Errorf("expected only the first alert of the unmuted address but got %d alert(s)", len(alerted))
+	}
+}
+
+func TestFanOutRetries(t *testing.T) {
+	// the webhook sends are retried with the default best-effort delivery, the Retry-After of 2s being capped
+	retry := retryPolicy{backoff: 10 * time.Millisecond, maxRetryAfter: 100 * time.Millisecond}
+	for _, tt := range []struct {
+		name     string
+		rejected int
+		header   string
+		minDelay time.Duration
+	}{
+		{"server error", http.StatusInternalServerError, "", retry.backoff},
+		{"throttled with Retry-After", http.StatusTooManyRequests, "2", retry.maxRetryAfter},
+	} {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.Header().Set("Retry-After", tt.header)
+				w.WriteHeader(tt.rejected)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		event := &noMatchEvent{Event: "no_match"}
+		n := webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, srv.URL, event)
+		})
+		n.retry = retry
+		start := time.Now()
+		fanOut(event, []notification{n})
+		elapsed := time.Since(start)
+		srv.Close()
+
+		if got := atomic.LoadInt32(&attempts); got != 2 {
+			t.Errorf("%s: expected 2 attempts but got %d", tt.name, got)
+		}
+		if elapsed < tt.minDelay {
+			t.Errorf("%s: expected the retry after at least %v but it came after %v", tt.name, tt.minDelay, elapsed)
+		}
+		if elapsed >= time.Second {
+			t.Errorf("%s: expected the retry within the policy's bounds but it came after %v", tt.name, elapsed)
+		}
+	}
+
+	// a send failing without a response, retried after the requested delay if longer than the backoff
+	var attempts int32
+	n := webhookNotification(func(ctx context.Context) error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return &throttledError{err: errors.New("unable to POST webhook payload: 429"), retryAfter: 50 * time.Millisecond}
+		}
+		return nil
+	})
+	n.retry = retry
+	start := time.Now()
+	fanOut(&noMatchEvent{Event: "no_match"}, []notification{n})
+	if elapsed := time.Since(start); atomic.LoadInt32(&attempts) != 2 || elapsed < 50*time.Millisecond {
+		t.Errorf("expected the throttled send to be retried after 50ms but got %d attempt(s) after %v", atomic.LoadInt32(&attempts), elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.April, 6, 12, 0, 0, 0, time.UTC)
+	for header, expected := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"3600":                          time.Hour,
+		"Tue, 06 Apr 2021 12:00:30 GMT": 30 * time.Second,
+		"Tue, 06 Apr 2021 11:00:00 GMT": 0,
+		"soon":                          0,
+	} {
+		if got := parseRetryAfter(header, now); got != expected {
+			t.Errorf("Retry-After '%s': expected %v but got %v", header, expected, got)
+		}
+	}
+}
+
+func TestReconnectFailsOver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pub := zmq4.NewPub(ctx)
+	defer pub.Close()
+	if err := pub.Listen("tcp://127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	up := "tcp://" + pub.Addr().String()
+	// a port nothing listens on anymore
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "tcp://" + l.Addr().String()
+	l.Close()
+
+	s := newStream(ctx, []string{down, up}, "", time.Second)
+	defer s.Close()
+	done := make(chan struct{})
+	go func() {
+		s.reconnect(0, time.Hour, false)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the reconnect to fail over to the reachable node without waiting the retry interval")
+	}
+	if s.node() != up {
+		t.Errorf("expected the stream to be connected to %s but it's on %s", up, s.node())
+	}
+	if generation := atomic.LoadUint64(&s.generation); generation != 1 {
+		t.Errorf("expected the reconnect to start generation 1 but it's %d", generation)
+	}
+}
+
+func TestProcessFrameValueFilters(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	for _, tt := range []struct {
+		name    string
+		group   watchGroup
+		value   int64
+		alerted bool
+	}{
+		{"zero value", watchGroup{}, 0, true},
+		{"zero value with -onlyValue", watchGroup{OnlyValue: true}, 0, false},
+		{"value with -onlyValue", watchGroup{OnlyValue: true}, 1, true},
+		{"below the min. value", watchGroup{MinValue: 100}, 99, false},
+		{"outgoing above the min. value", watchGroup{MinValue: 100}, -100, true},
+		{"incoming with direction in", watchGroup{Direction: directionIn}, 5, true},
+		{"outgoing with direction in", watchGroup{Direction: directionIn}, -5, false},
+		{"zero value with direction out", watchGroup{Direction: directionOut}, 0, false},
+	} {
+		frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: tt.value}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := tt.group
+		group.Name, group.Addrs = "default", []string{addr}
+		group.init()
+		groups := []*watchGroup{&group}
+		m := &Monitor{groups: groups, report: newReplayReport(groups)}
+		if err := m.ProcessFrame(frame); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+		if alerted := len(m.report.txs["default"]) != 0; alerted != tt.alerted {
+			t.Errorf("%s: expected alerted=%v but got %v", tt.name, tt.alerted, alerted)
+		}
+	}
+}
+
+func TestAddrMatcherRules(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	other := strings.Repeat("B", consts.HashTrytesSize)
+	for _, tt := range []struct {
+		name    string
+		group   watchGroup
+		addr    string
+		tag     string
+		matched bool
+	}{
+		{"exact", watchGroup{Addrs: []string{addr}}, addr, "", true},
+		{"exact with checksum", watchGroup{Addrs: []string{addr + "YLFHUOJUY"}}, addr, "", true},
+		{"not monitored", watchGroup{Addrs: []string{addr}}, other, "", false},
+		{"prefix", watchGroup{AddrPrefixes: []string{"BBB"}}, other, "", true},
+		{"ignored beats exact", watchGroup{Addrs: []string{addr}, IgnoreAddrs: []string{addr}}, addr, "", false},
+		{"ignored beats prefix", watchGroup{AddrPrefixes: []string{"AAA"}, IgnoreAddrs: []string{addr}}, addr, "", false},
+		{"required tag", watchGroup{Addrs: []string{addr}, AddrTags: map[string]string{addr: "FOO"}}, addr, "FOOBAR", true},
+		{"missing required tag", watchGroup{Addrs: []string{addr}, AddrTags: map[string]string{addr: "FOO"}}, addr, "BAR", false},
+	} {
+		group := tt.group
+		group.init()
+		if decision := group.matcher.match(tt.addr, tt.tag); decision.Matched != tt.matched {
+			t.Errorf("%s: expected matched=%v but got %v (%s)", tt.name, tt.matched, decision.Matched, decision.Reason)
+		}
+	}
+}
+
+func TestNotifyTxSlackMessage(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	frame, tx, err := buildInjectedFrame(&injectRequest{Address: addr, Value: -42}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alerted []interface{}
+	groups := []*watchGroup{{Name: "default", Addrs: []string{addr}}}
+	groups[0].init()
+	m := &Monitor{groups: groups, notifier: func(group *watchGroup, payload interface{}) {
+		alerted = append(alerted, payload)
+	}}
+	if err := m.ProcessFrame(frame); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(alerted) != 1 {
+		t.Fatalf("expected 1 alert but got %d", len(alerted))
+	}
+	event, ok := alerted[0].(*txEvent)
+	if !ok {
+		t.Fatalf("expected a tx alert but got %T", alerted[0])
+	}
+	text := slackTxText(event)
+	for _, expected := range []string{tx.Hash, addr, tx.Bundle, "outgoing value"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected the slack msg to contain '%s': %s", expected, text)
+		}
+	}
+}
+
+func TestInclusionPollerBatchesDueTxs(t *testing.T) {
+	var calls [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req getInclusionStatesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("unable to parse getInclusionStates request: %s", err)
+		}
+		calls = append(calls, req.Transactions)
+		states := make([]bool, len(req.Transactions))
+		for i, hash := range req.Transactions {
+			states[i] = hash == "CONFIRMED"
+		}
+		_ = json.NewEncoder(w).Encode(&getInclusionStatesResponse{States: states})
+	}))
+	defer srv.Close()
+	p := &inclusionPoller{api: newNodeAPI(srv.URL, time.Second), timeout: time.Hour}
+	group := &watchGroup{Name: "default"}
+	alertedAt := time.Now()
+	txEventOf := func(hash string) *txEvent {
+		return &txEvent{Transaction: &transaction.Transaction{Hash: hash}}
+	}
+	p.track(group, txEventOf("CONFIRMED"), alertedAt)
+	p.track(group, txEventOf("PENDING"), alertedAt)
+	// alerted later, not due at the first poll of the others
+	p.track(group, txEventOf("LATER"), alertedAt.Add(5*time.Second))
+
+	p.tick(context.Background(), alertedAt.Add(inclusionPollInterval))
+	if len(calls) != 1 || strings.Join(calls[0], ",") != "CONFIRMED,PENDING" {
+		t.Fatalf("expected a single poll of the due txs but got %v", calls)
+	}
+	if len(p.pending) != 2 {
+		t.Fatalf("expected the confirmed tx to no longer be pending, %d tx(s) pending", len(p.pending))
+	}
+
+	p.tick(context.Background(), alertedAt.Add(p.timeout))
+	if len(calls) != 2 || strings.Join(calls[1], ",") != "PENDING,LATER" {
+		t.Errorf("expected a single poll of the due txs but got %v", calls[1:])
+	}
+	if len(p.pending) != 1 || p.pending[0].event.Hash != "LATER" {
+		t.Errorf("expected only the tx alerted later to be pending after the timeout of the others")
+	}
+}
+
+func TestProcessFrameSuspiciousTxs(t *testing.T) {
+	defer func(policy string) { *suspiciousTxsPolicy = policy }(*suspiciousTxsPolicy)
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	// attached by a wallet whose clock is ahead
+	frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: 1}, time.Now().Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for policy, alerted := range map[string]bool{suspiciousTxsFlag: true, suspiciousTxsSkip: false} {
+		*suspiciousTxsPolicy = policy
+		groups := []*watchGroup{{Name: "default", Addrs: []string{addr}}}
+		groups[0].init()
+		m := &Monitor{groups: groups, report: newReplayReport(groups)}
+		if err := m.ProcessFrame(frame); err != nil {
+			t.Fatalf("%s: unexpected error: %s", policy, err)
+		}
+		if got := len(m.report.txs["default"]) != 0; got != alerted {
+			t.Errorf("%s: expected alerted=%v but got %v", policy, alerted, got)
+		}
+	}
+}
+
+func TestTokenBucketWaitHonorsContext(t *testing.T) {
+	bucket := newTokenBucket(0.001, 1)
+	if err := bucket.wait(context.Background()); err != nil {
+		t.Fatalf("expected the burst token to be available: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bucket.wait(ctx); err == nil {
+		t.Error("expected an error once the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to end with the context but it took %v", elapsed)
+	}
+}
+
+// from the get-vanilla, post-vanilla and post-x-www-form-urlencoded cases of the AWS SigV4 test suite
+func TestSignAWSRequestTestVectors(t *testing.T) {
+	creds := &awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name, method, contentType, body, expected string
+	}{
+		{"get-vanilla", http.MethodGet, "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", http.MethodPost, "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-x-www-form-urlencoded", http.MethodPost, "application/x-www-form-urlencoded", "Param1=value1",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	} {
+		req, err := http.NewRequest(tc.method, "https://example.amazonaws.com/", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		signAWSRequest(req, []byte(tc.body), creds, "us-east-1", "service", now)
+		if auth := req.Header.Get("Authorization"); auth != tc.expected {
+			t.Errorf("%s: expected authorization '%s', got '%s'", tc.name, tc.expected, auth)
+		}
+	}
+}
+
+func TestResolveAWSCredentialsViaIMDS(t *testing.T) {
+	var tokenRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			atomic.AddInt32(&tokenRequests, 1)
+			_, _ = w.Write([]byte("token"))
+		case r.Header.Get("X-aws-ec2-metadata-token") != "token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = w.Write([]byte("monitor-role\n"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/monitor-role":
+			_ = json.NewEncoder(w).Encode(&awsMetadataCredentials{
+				AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", Token: "session", Expiration: time.Now().Add(time.Hour),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	for name, value := range map[string]string{
+		"AWS_ACCESS_KEY_ID": "", "AWS_SHARED_CREDENTIALS_FILE": t.TempDir() + "/credentials", "AWS_WEB_IDENTITY_TOKEN_FILE": "",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "", "AWS_CONTAINER_CREDENTIALS_FULL_URI": "",
+		"AWS_EC2_METADATA_DISABLED": "", "AWS_EC2_METADATA_SERVICE_ENDPOINT": srv.URL,
+	} {
+		defer func(name, value string, set bool) {
+			if set {
+				_ = os.Setenv(name, value)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}(name, os.Getenv(name), os.Getenv(name) != "")
+		_ = os.Setenv(name, value)
+	}
+	defer func() { awsCredentialsCache.creds = nil }()
+
+	for i := 0; i < 2; i++ {
+		creds, err := resolveAWSCredentials(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.accessKeyID != "ASIAEXAMPLE" || creds.secretAccessKey != "secret" || creds.sessionToken != "session" {
+			t.Fatalf("unexpected credentials %+v", creds)
+		}
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Fatalf("expected the cached credentials to be reused, got %d token requests", n)
+	}
+}
+
+func TestPriceFeedRefreshesInBackground(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("0.25\n"))
+	}))
+	defer srv.Close()
+	feed := newPriceFeed(srv.URL, time.Hour, time.Second)
+	if fiat := feed.fiatValue(iotasPerMiota); fiat != nil {
+		t.Fatalf("expected no fiat value before the first fetch, got %f", *fiat)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go feed.watch(ctx)
+	deadline := time.Now().Add(time.Second)
+	for feed.fiatValue(iotasPerMiota) == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fiat := feed.fiatValue(4 * iotasPerMiota); fiat == nil || *fiat != 1 {
+		t.Fatalf("expected a fiat value of 1 after the fetch, got %v", fiat)
+	}
+}
+
+func TestFirstSeenStoreAppendsAddrs(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	path := t.TempDir() + "/first_seen"
+	// the JSON array of an older release, compacted on load
+	if err := os.WriteFile(path, []byte(`["`+strings.Repeat("B", consts.HashTrytesSize)+`"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := loadFirstSeenStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := []*watchGroup{{Name: "default", Addrs: []string{addr}}}
+	groups[0].init()
+	m := &Monitor{groups: groups, report: newReplayReport(groups), firstSeen: store}
+	frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.ProcessFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(m.report.firstActivities["default"]); n != 1 {
+		t.Fatalf("expected a single first activity alert, got %d", n)
+	}
+
+	// an append torn by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("CCC"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := loadFirstSeenStore(path, false); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Repeat("A", consts.HashTrytesSize) + "\n" + strings.Repeat("B", consts.HashTrytesSize) + "\n"; string(content) != expected {
+		t.Fatalf("expected the compacted addresses %q, got %q", expected, content)
+	}
+}
+
+func TestTruncateMsgKeepsRunes(t *testing.T) {
+	text := truncateMsg(strings.Repeat("€", 200), "\nfooter", 100)
+	if !utf8.ValidString(text) {
+		t.Fatalf("expected a valid UTF-8 msg, got %q", text)
+	}
+	if n := utf8.RuneCountInString(text); n != 100 {
+		t.Fatalf("expected a msg of 100 chars, got %d", n)
+	}
+	if !strings.HasSuffix(text, truncatedSuffix+"\nfooter") {
+		t.Fatalf("expected the truncated suffix and the footer, got %q", text)
+	}
+	if text := truncateMsg("short", "\nfooter", 100); text != "short\nfooter" {
+		t.Fatalf("expected the short msg untouched, got %q", text)
+	}
+}
+
+func TestTruncateMsgLongFooter(t *testing.T) {
+	// e.g. the footer of a long -instanceLabel
+	footer := "\n_instance " + strings.Repeat("x", 90) + "_"
+	if text := truncateMsg(strings.Repeat("a", 200), footer, 100); text != truncatedSuffix+footer {
+		t.Fatalf("expected the msg cut off entirely, got %q", text)
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	const uri, webhookURI = "https://example.com/hook", "https://example.com/webhook"
+	if _, ok := newNotifier(notifierSlack, uri, webhookURI).(*SlackNotifier); !ok {
+		t.Fatal("expected a Slack notifier")
+	}
+	if _, ok := newNotifier(notifierDiscord, uri, webhookURI).(*DiscordNotifier); !ok {
+		t.Fatal("expected a Discord notifier")
+	}
+	if _, ok := newNotifier(notifierGeneric, uri, webhookURI).(*GenericWebhookNotifier); !ok {
+		t.Fatal("expected a generic webhook notifier")
+	}
+	if n := newNotifier(notifierGeneric, webhookURI, webhookURI); n != nil {
+		t.Fatalf("expected no notifier for the generic webhook URI, got %T", n)
+	}
+	if n := newNotifier(notifierSlack, "", webhookURI); n != nil {
+		t.Fatalf("expected no notifier without a URI, got %T", n)
+	}
+}
+
+func TestGenericWebhookNotifierPostsTxEvent(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	tx := &transaction.Transaction{Hash: strings.Repeat("H", consts.HashTrytesSize), Value: 5}
+	event := &txEvent{Transaction: tx, Group: "exchange"}
+	n := &GenericWebhookNotifier{URI: server.URL}
+	if err := n.Notify(withTxEvent(context.Background(), event), tx); err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["hash"] != tx.Hash || payload["group"] != "exchange" {
+		t.Fatalf("expected the tx with the alert's details, got %v", payload)
+	}
+}
+
+func TestTruncateLinesCountsChars(t *testing.T) {
+	// 10 chars but 30 bytes per line
+	lines := []string{strings.Repeat("€", 9) + "\n", strings.Repeat("€", 9) + "\n", strings.Repeat("€", 9) + "\n"}
+	// the footer of the omitted lines is reserved while further lines follow
+	if text := truncateLines("header\n", lines, 41); text != "header\n"+strings.Join(lines, "") {
+		t.Fatalf("expected all lines to fit into 41 chars, got %q", text)
+	}
+	text := truncateLines("header\n", lines, 40)
+	if text != "header\n"+lines[0]+"...and 2 more\n" {
+		t.Fatalf("expected the lines cut off within 40 chars, got %q", text)
+	}
+}
+
+func TestReplayRunsAddrDetectorsInMemory(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	dir := t.TempDir()
+	firstSeenPath, spentPath, recordingPath := dir+"/first_seen", dir+"/spent.json", dir+"/recording"
+	firstSeen := strings.Repeat("B", consts.HashTrytesSize) + "\n"
+	spent := `{"` + addr + `":{"spends":1,"bundles":["` + strings.Repeat("Z", consts.HashTrytesSize) + `"]}}`
+	if err := os.WriteFile(firstSeenPath, []byte(firstSeen), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(spentPath, []byte(spent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var recording strings.Builder
+	for _, bundle := range []string{strings.Repeat("X", consts.HashTrytesSize), strings.Repeat("Y", consts.HashTrytesSize)} {
+		frame, _, err := buildInjectedFrame(&injectRequest{Address: addr, Value: -5, Bundle: bundle}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		recording.WriteString(base64.StdEncoding.EncodeToString([]byte(frame)) + "\n")
+	}
+	if err := os.WriteFile(recordingPath, []byte(recording.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := []*watchGroup{{Name: "default", Addrs: []string{addr}}}
+	groups[0].init()
+	m := &Monitor{groups: groups}
+	if err := m.initAddrDetectors(firstSeenPath, spentPath, time.Hour, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayRecording(m, recordingPath); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.report.firstActivities["default"]); n != 1 {
+		t.Errorf("expected 1 first activity alert, got %d", n)
+	}
+	if n := len(m.report.conflicts["default"]); n != 1 {
+		t.Errorf("expected 1 conflicting spend alert, got %d", n)
+	}
+	// spent from in the recorded bundle before
+	if n := len(m.report.addrReuses["default"]); n != 2 {
+		t.Errorf("expected 2 address reuse alerts, got %d", n)
+	}
+	for path, expected := range map[string]string{firstSeenPath: firstSeen, spentPath: spent} {
+		if content, err := os.ReadFile(path); err != nil || string(content) != expected {
+			t.Errorf("expected the replay to leave %s untouched, got %q (%v)", path, content, err)
+		}
+	}
+	if _, err := os.Stat(firstSeenPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary file of the first seen store, got %v", err)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	addr := strings.Repeat("A", consts.HashTrytesSize)
+	path := t.TempDir() + "/monitor.yaml"
+	content := `node: tcp://localhost:5556
+addrs:
+  - ` + addr + `
+dialTimeout: 5s
+onlyValue: true
+groups:
+  - name: exchange
+    addrs: [` + addr + `]
+    minValue: 10
+    addrTags:
+      ` + addr + `: HOT
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, problems := loadConfigFile(path)
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if config.Options["addrs"] != addr || config.Options["dialTimeout"] != "5s" || config.Options["onlyValue"] != "true" {
+		t.Fatalf("unexpected options: %v", config.Options)
+	}
+	if len(config.Groups) != 1 || config.Groups[0].Name != "exchange" || config.Groups[0].MinValue != 10 || config.Groups[0].AddrTags[addr] != "HOT" {
+		t.Fatalf("unexpected groups: %+v", config.Groups)
+	}
+
+	if err := os.WriteFile(path, []byte("dialTimeout: 5x\nbogus: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, problems = loadConfigFile(path)
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), "'dialTimeout'") || !strings.Contains(problems[1].Error(), "'bogus'") {
+		t.Fatalf("expected the malformed duration and the unknown option named, got %v", problems)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMuteDuration bounds the duration an address can be muted for, so that a forgotten mute doesn't silence an
+// address for good.
+const maxMuteDuration = 7 * 24 * time.Hour
+
+// muteList holds the monitored addresses muted via the API until their mutes expire. A nil list mutes no address.
+type muteList struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newMuteList() *muteList {
+	return &muteList{until: make(map[string]time.Time)}
+}
+
+// muteRequest is the payload of a mute request.
+type muteRequest struct {
+	Duration string `json:"duration"`
+}
+
+type muteResponse struct {
+	Address string    `json:"address"`
+	Until   time.Time `json:"until"`
+}
+
+// mute mutes the given address until the given time.
+func (l *muteList) mute(addr string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.until[addr] = until
+}
+
+// unmute lifts the mute of the given address, reporting whether it was muted.
+func (l *muteList) unmute(addr string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, has := l.until[addr]
+	delete(l.until, addr)
+	return has && now.Before(until)
+}
+
+// muted reports whether the given address is muted at the given time, dropping its mute once expired.
+func (l *muteList) muted(addr string, now time.Time) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, has := l.until[addr]
+	if !has {
+		return false
+	}
+	if !now.Before(until) {
+		delete(l.until, addr)
+		infof("the mute of monitored address %s expired", addr)
+		return false
+	}
+	return true
+}
+
+// allMuted reports whether all of the given addresses are muted at the given time.
+func (l *muteList) allMuted(addrs []bundleAddrValue, now time.Time) bool {
+	for _, addr := range addrs {
+		if !l.muted(addr.Address, now) {
+			return false
+		}
+	}
+	return len(addrs) > 0
+}
+
+// authorized reports whether the given request carries the given API token as its bearer token.
+func authorized(r *http.Request, apiToken string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(apiToken)) == 1
+}
+
+// serveMute mutes (POST) or unmutes (DELETE) the address of the request's /addresses/{addr}/mute path in the given
+// mute list, if authorized by the given API token.
+func serveMute(w http.ResponseWriter, r *http.Request, apiToken string, mutes *muteList) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, apiToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/addresses/")
+	if !strings.HasSuffix(path, "/mute") {
+		http.NotFound(w, r)
+		return
+	}
+	addr, err := normalizeAddr(strings.TrimSuffix(path, "/mute"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if mutes.unmute(addr, time.Now()) {
+			infof("unmuted monitored address %s", addr)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var req muteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("unable to parse mute request: %s", err), http.StatusBadRequest)
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 || duration > maxMuteDuration {
+		http.Error(w, fmt.Sprintf("duration must be positive and at most %v", maxMuteDuration), http.StatusBadRequest)
+		return
+	}
+	until := time.Now().Add(duration)
+	mutes.mute(addr, until)
+	infof("muted monitored address %s until %s", addr, until.Format(time.RFC3339))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&muteResponse{Address: addr, Until: until}); err != nil {
+		errorf("could not write mute response: %s", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// nodeDSNOptions maps the query parameters of a -nodeDSN to the node connection flags they set.
+var nodeDSNOptions = map[string]string{
+	"dialTimeout":           "dialTimeout",
+	"connRetryInterval":     "connRetryInterval",
+	"connRetryMaxInterval":  "connRetryMaxInterval",
+	"initialConnectRetries": "initialConnectRetries",
+	"initialConnectDelay":   "initialConnectDelay",
+	"idleProbeInterval":     "idleProbeInterval",
+	"maxIdle":               "maxIdle",
+	"nodeMode":              "nodeMode",
+	"preferPrimary":         "preferPrimary",
+	"subscribe":             "topic",
+	"topic":                 "topic",
+	"milestoneTopic":        "milestoneTopic",
+	"frameFormat":           "frameFormat",
+}
+
+// unsupportedNodeDSNOptions are the socket options the ZMQ library doesn't implement for subscriber sockets.
+var unsupportedNodeDSNOptions = map[string]string{
+	"hwm":         "the ZMQ library doesn't apply a high water mark to subscriber sockets",
+	"curveKey":    "the ZMQ library doesn't implement CURVE security",
+	"curveServer": "the ZMQ library doesn't implement CURVE security",
+}
+
+// applyNodeDSN sets -node and the node connection flags from the given DSN, the node URI(s) followed by
+// the options as query parameters, e.g. tcp://host:5556?dialTimeout=5s&subscribe=trytes. Per the given origins of the
+// set flags, flags of the DSN's origin conflict with it, while the command line overrides a DSN of the -config file
+// and a DSN of the command line overrides the file.
+func applyNodeDSN(dsn string, origins flagOrigins) []error {
+	var problems []error
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("-nodeDSN: "+format, args...))
+	}
+	origin := origins["nodeDSN"]
+	// whether the given flag is set by the origin of the DSN or overrides it from the command line
+	conflicts := func(name string) bool { return origins[name] == origin }
+	overridden := func(name string) bool { return origins[name] == originCommandLine && origin != originCommandLine }
+	if conflicts("node") {
+		problemf("can't be combined with -node")
+	}
+
+	nodes, rawQuery := dsn, ""
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		nodes, rawQuery = dsn[:i], dsn[i+1:]
+	}
+	if strings.TrimSpace(nodes) == "" {
+		problemf("no node URI")
+	}
+	options, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		problemf("malformed options: %s", err)
+		return problems
+	}
+	if !conflicts("node") && !overridden("node") && nodes != "" {
+		flag.Set("node", nodes)
+	}
+
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	applied := make(map[string]string)
+	for _, key := range keys {
+		if reason, has := unsupportedNodeDSNOptions[key]; has {
+			problemf("option %q is not supported: %s", key, reason)
+			continue
+		}
+		name, has := nodeDSNOptions[key]
+		if !has {
+			problemf("unknown option %q", key)
+			continue
+		}
+		values := options[key]
+		switch {
+		case len(values) != 1:
+			problemf("option %q given %d times", key, len(values))
+		case overridden(name):
+			// the command line's flag takes precedence
+		case conflicts(name):
+			problemf("option %q conflicts with -%s", key, name)
+		case applied[name] != "":
+			problemf("option %q conflicts with option %q", key, applied[name])
+		default:
+			if err := flag.Set(name, values[0]); err != nil {
+				problemf("option %q: %s", key, err)
+				continue
+			}
+			applied[name] = key
+		}
+	}
+	return problems
+}
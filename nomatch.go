@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// noMatchWatchdog detects that no monitored address matched for too long, which may indicate a problem
+// with the monitoring (e.g. a node filtering txs or wrong addresses) rather than with the connection.
+type noMatchWatchdog struct {
+	timeout time.Duration
+	// the unix nanos of the last match, accessed atomically
+	lastMatch int64
+}
+
+// noMatchEvent is the generic webhook payload of a no match alert.
+type noMatchEvent struct {
+	Event     string    `json:"event"`
+	Window    string    `json:"window"`
+	LastMatch time.Time `json:"lastMatch"`
+	Time      time.Time `json:"time"`
+}
+
+var noMatchTemplate = `monitoring:
+- no monitored address matched within the last %v (since %s)
+`
+
+func newNoMatchWatchdog(timeout time.Duration) *noMatchWatchdog {
+	return &noMatchWatchdog{timeout: timeout, lastMatch: time.Now().UnixNano()}
+}
+
+// matched records a match at the given time.
+func (w *noMatchWatchdog) matched(now time.Time) {
+	atomic.StoreInt64(&w.lastMatch, now.UnixNano())
+}
+
+// watch notifies the operators once no monitored address matched within the timeout, until the given
+// context is done. Further alerts are only sent after matches resumed.
+func (w *noMatchWatchdog) watch(ctx context.Context) {
+	interval := w.timeout / 4
+	if interval > 15*time.Second {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	alerted := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		lastMatch := time.Unix(0, atomic.LoadInt64(&w.lastMatch))
+		silent := time.Since(lastMatch) > w.timeout
+		switch {
+		case silent && !alerted:
+			alerted = true
+			warnf("no monitored address matched within the last %v (since %s)", w.timeout, lastMatch.Format(time.RFC3339))
+			event := &noMatchEvent{Event: "no_match", Window: w.timeout.String(), LastMatch: lastMatch, Time: alertTime()}
+			notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(noMatchTemplate, w.timeout, lastMatch.Format(time.RFC3339))), event)
+		case !silent && alerted:
+			alerted = false
+			infof("monitored addresses are matching again")
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// The chat platforms of -notifier, receiving the alerts sent to the Slack webhook URIs. With 'generic', the Slack
+// webhook URIs receive the events' JSON payloads like the generic webhook URIs instead.
+const (
+	notifierSlack   = "slack"
+	notifierDiscord = "discord"
+	notifierGeneric = "generic"
+)
+
+// Notifier sends alerts to a single webhook URI in the format of its platform.
+type Notifier interface {
+	// Notify sends the alert about the given matched tx, with the details of the alert taken from the event
+	// attached to the ctx by withTxEvent (only those of the bare tx if none is attached).
+	Notify(ctx context.Context, tx *transaction.Transaction) error
+	// notifyEvent sends the alert about any other event: the given chat msg, colored by the given Slack attachment
+	// color unless empty, to chat platforms and the given payload to webhooks.
+	notifyEvent(ctx context.Context, text string, color string, payload interface{}) error
+}
+
+// newNotifier returns the notifier of the given -notifier sending to the given Slack webhook URI, nil if there is
+// none. With 'generic', a URI which equals the given generic webhook URI has none either, as the payloads are sent
+// there already.
+func newNotifier(kind string, uri string, webhookURI string) Notifier {
+	switch {
+	case uri == "" || (kind == notifierGeneric && uri == webhookURI):
+		return nil
+	case kind == notifierDiscord:
+		return &DiscordNotifier{URI: uri}
+	case kind == notifierGeneric:
+		return &GenericWebhookNotifier{URI: uri}
+	}
+	return &SlackNotifier{URI: uri}
+}
+
+type txEventKey struct{}
+
+// withTxEvent attaches the given tx alert's event to the ctx of its Notify calls.
+func withTxEvent(ctx context.Context, event *txEvent) context.Context {
+	return context.WithValue(ctx, txEventKey{}, event)
+}
+
+// txEventOf returns the event of the given tx attached to the ctx, an event of the bare tx if there is none.
+func txEventOf(ctx context.Context, tx *transaction.Transaction) *txEvent {
+	if event, ok := ctx.Value(txEventKey{}).(*txEvent); ok && event.Transaction == tx {
+		return event
+	}
+	return &txEvent{Transaction: tx, ReceivedAt: alertTime()}
+}
+
+// notification is the send of a single event to a single notification backend.
+type notification struct {
+	backend string
+	// bounds the send, 0 if only bounded by the notification deadline
+	timeout time.Duration
+	// spaces out the sends to the backend, nil if unspaced
+	spacer *sendSpacer
+	// paces the retries of failed sends, the defaults if zero
+	retry retryPolicy
+	send  func(ctx context.Context) error
+}
+
+func slackNotification(send func(ctx context.Context) error) notification {
+	return notification{backend: "slack", timeout: slackTimeout, spacer: slackSpacer, send: send}
+}
+
+func webhookNotification(send func(ctx context.Context) error) notification {
+	return notification{backend: "webhook", timeout: webhookTimeout, spacer: webhookSpacer, send: send}
+}
+
+// per backend timeouts and the overall deadline of fanning out an event, 0 if unbounded
+var (
+	slackTimeout     time.Duration
+	webhookTimeout   time.Duration
+	pagerDutyTimeout time.Duration
+	opsgenieTimeout  time.Duration
+	snsTimeout       time.Duration
+	notifyDeadline   time.Duration
+)
+
+// per backend min. spacing in between sends, nil if unspaced
+var (
+	slackSpacer   *sendSpacer
+	webhookSpacer *sendSpacer
+)
+
+// initNotifications configures the notification sends per the flags: the timeouts and spacing per backend, the rate
+// limit of Slack and the HTTP client shared by the sends, persisting the requests to the -queueDir if set. The
+// returned queue is nil unless a -queueDir is set, its leftover requests are only redelivered once started. The
+// -priceURI feed is set up as well.
+func initNotifications() (*notificationQueue, error) {
+	httpIdleTimeout := mustParseDuration(*httpIdleTimeoutStr, "http idle connection timeout")
+	httpTimeout := mustParseDuration(*httpTimeoutStr, "http timeout")
+	slackTimeout = mustParseDuration(*slackTimeoutStr, "slack timeout")
+	webhookTimeout = mustParseDuration(*webhookTimeoutStr, "webhook timeout")
+	pagerDutyTimeout = mustParseDuration(*pagerDutyTimeoutStr, "pagerduty timeout")
+	opsgenieTimeout = mustParseDuration(*opsgenieTimeoutStr, "opsgenie timeout")
+	snsTimeout = mustParseDuration(*snsTimeoutStr, "sns timeout")
+	notifyDeadline = mustParseDuration(*notifyDeadlineStr, "notification deadline")
+	if slackMinInterval := mustParseDuration(*slackMinIntervalStr, "slack min. interval"); slackMinInterval > 0 {
+		slackSpacer = newSendSpacer(slackMinInterval)
+	}
+	if webhookMinInterval := mustParseDuration(*webhookMinIntervStr, "webhook min. interval"); webhookMinInterval > 0 {
+		webhookSpacer = newSendSpacer(webhookMinInterval)
+	}
+	if *slackRateLimit > 0 {
+		slackLimiter = newTokenBucket(*slackRateLimit, *slackBurst)
+	}
+
+	tlsConfig, err := newNotificationTLSConfig(*httpCAFile, *httpPinnedCerts)
+	if err != nil {
+		return nil, err
+	}
+	var queue *notificationQueue
+	if *queueDir != "" {
+		if queue, err = openNotificationQueue(*queueDir); err != nil {
+			return nil, err
+		}
+	}
+	notificationClient = newNotificationClient(*httpMaxIdleConns, *httpMaxInFlight, httpIdleTimeout, httpTimeout, tlsConfig, queue)
+	if *priceURI != "" {
+		prices = newPriceFeed(*priceURI, mustParseDuration(*priceTTLStr, "price TTL"), httpTimeout)
+	}
+	return queue, nil
+}
+
+// sendOnce makes a single attempt of sending the notification, spaced out from the previous sends to its backend
+// and bounded by its backend's timeout, if configured.
+func (n notification) sendOnce(ctx context.Context) error {
+	if n.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.timeout)
+		defer cancel()
+	}
+	if n.spacer != nil && !n.spacer.wait(ctx) {
+		return ctx.Err()
+	}
+	return n.send(ctx)
+}
+
+// fanOut fans out the given event payload to the given notifications, queueing it for the notification worker if
+// the queue is enabled.
+func fanOut(payload interface{}, notifications []notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	if notifyWorker != nil && notifyWorker.enqueue(payload, notifications) {
+		return
+	}
+	fanOutNow(payload, notifications)
+}
+
+// attempts returns the max. number of attempts of the notification's sends per the -deliverySemantics: up to
+// deliveryMaxAttempts with at-least-once delivery, a single one with at-most-once delivery, and with best-effort
+// delivery up to deliveryBestEffortAttempts to the chat and webhook backends, which are cheap to retry.
+func (n notification) attempts() int {
+	switch {
+	case *deliverySemantics == deliveryAtLeastOnce:
+		return deliveryMaxAttempts
+	case *deliverySemantics == deliveryBestEffort && (n.backend == "slack" || n.backend == "webhook"):
+		return deliveryBestEffortAttempts
+	}
+	return 1
+}
+
+// fanOutNow sends the given notifications concurrently, so that a slow backend doesn't delay the others.
+// Every send is spaced out from the previous sends to its backend and bounded by its backend's timeout, if configured,
+// and all of them by the notification deadline and the shutdown deadline, after which the sends which haven't completed
+// yet are abandoned.
+// Failed sends are retried up to their attempts with an exponential backoff, or after the delay requested by a
+// throttling backend via Retry-After if longer. The given event payload is recorded as undelivered if none of the
+// backends accepted it.
+func fanOutNow(payload interface{}, notifications []notification) {
+	ctx, cancel := shutdownCtx, context.CancelFunc(func() {})
+	if notifyDeadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, notifyDeadline)
+	}
+	defer cancel()
+
+	done := make(chan int, len(notifications))
+	// set before the index is sent on done
+	failed := make([]bool, len(notifications))
+	for i := range notifications {
+		go func(i int) {
+			n := notifications[i]
+			delay := n.retry.firstDelay()
+			maxAttempts := n.attempts()
+			for attempt := 1; ; attempt++ {
+				err := n.sendOnce(ctx)
+				if err == nil {
+					break
+				}
+				if attempt >= maxAttempts || ctx.Err() != nil {
+					errorf("could not send %s notification: %s", n.backend, err)
+					notificationFailures.Add(1)
+					failed[i] = true
+					break
+				}
+				wait := delay
+				var throttled *throttledError
+				if errors.As(err, &throttled) {
+					if requested := n.retry.throttledDelay(throttled.retryAfter); requested > wait {
+						// the backend asked to back off for longer
+						wait = requested
+					}
+				}
+				warnf("could not send %s notification: %s...retrying in %v (attempt %d/%d)", n.backend, err, wait, attempt, maxAttempts)
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+				delay *= 2
+			}
+			done <- i
+		}(i)
+	}
+
+	pending := make(map[int]struct{}, len(notifications))
+	for i := range notifications {
+		pending[i] = struct{}{}
+	}
+	delivered := 0
+	for len(pending) > 0 {
+		select {
+		case i := <-done:
+			delete(pending, i)
+			if !failed[i] {
+				delivered++
+			}
+		case <-ctx.Done():
+			reason := fmt.Sprintf("notification deadline of %v exceeded", notifyDeadline)
+			if shutdownCtx.Err() != nil {
+				reason = "shutdown deadline exceeded"
+			}
+			for i := range pending {
+				notificationFailures.Add(1)
+				errorf("abandoned %s notification: %s", notifications[i].backend, reason)
+			}
+			pending = nil
+		}
+	}
+
+	switch delivered {
+	case 0:
+		backends := make([]string, 0, len(notifications))
+		for _, n := range notifications {
+			backends = append(backends, n.backend)
+		}
+		undelivered.record(payload, backends)
+	case len(notifications):
+		undelivered.recovered()
+	}
+}
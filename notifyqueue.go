@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// The policies of -queueOverflowPolicy applied to an event once the notification queue is full.
+const (
+	// waiting for room in the queue, holding up the processing of the stream (latency)
+	overflowBlock = "block"
+	// dropping the oldest queued event to make room for the event (freshness)
+	overflowDropOldest = "drop-oldest"
+	// dropping the event
+	overflowDropNewest = "drop-newest"
+	// appending the event to the -spillFile, from which it's queued again once the queue drained (durability)
+	overflowSpill = "spill-to-disk"
+)
+
+// notificationJob is the fan out of an event queued for the notificationWorker.
+type notificationJob struct {
+	payload       interface{}
+	notifications []notification
+}
+
+// notificationWorker fans out the queued events one after another in the background, so that slow backends don't
+// hold up the processing of the stream until the queue is full, after which its overflow policy applies.
+type notificationWorker struct {
+	jobs   chan notificationJob
+	policy string
+	// the events spilled with the spill-to-disk policy, nil with other policies
+	spill *undeliveredLog
+
+	// guards closed, held by the enqueuers while queueing
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	// set while the queue overflows (accessed atomically), to warn once per overflow
+	overflowing int32
+}
+
+// notifyWorker is the worker of the notification queue, nil if the events are fanned out synchronously.
+var notifyWorker *notificationWorker
+
+// newNotificationWorker starts the worker of a queue of the given size, applying the given validated policy on
+// overflow and spilling to the given log with the spill-to-disk policy.
+func newNotificationWorker(size int, policy string, spill *undeliveredLog) *notificationWorker {
+	w := &notificationWorker{jobs: make(chan notificationJob, size), policy: policy, spill: spill, done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *notificationWorker) run() {
+	defer close(w.done)
+	for job := range w.jobs {
+		fanOutNow(job.payload, job.notifications)
+		if len(w.jobs) == 0 {
+			if atomic.CompareAndSwapInt32(&w.overflowing, 1, 0) {
+				infof("the notification queue drained")
+			}
+			// spilled events are queued again by their redelivery
+			w.spill.recovered()
+		}
+	}
+}
+
+// enqueue queues the fan out of the given event, applying the overflow policy if the queue is full. Reports false if
+// the worker is closed, in which case the event must be fanned out by the caller.
+func (w *notificationWorker) enqueue(payload interface{}, notifications []notification) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	job := notificationJob{payload: payload, notifications: notifications}
+	select {
+	case w.jobs <- job:
+		return true
+	default:
+	}
+
+	if atomic.CompareAndSwapInt32(&w.overflowing, 0, 1) {
+		warnf("warning: the notification queue is full, applying the overflow policy '%s' until it drained", w.policy)
+	}
+	switch w.policy {
+	case overflowDropNewest:
+		droppedEvents.Add(1)
+		errorf("dropped %s event: notification queue full", eventKind(payload))
+		return true
+	case overflowDropOldest:
+		for {
+			select {
+			case w.jobs <- job:
+				return true
+			default:
+			}
+			select {
+			case oldest := <-w.jobs:
+				droppedEvents.Add(1)
+				errorf("dropped queued %s event: notification queue full", eventKind(oldest.payload))
+			default:
+			}
+		}
+	case overflowSpill:
+		// only the events of groups are queued again from the spill file, operator events wait for room instead
+		if redeliverableKinds[eventKind(payload)] {
+			w.spillEvent(job)
+			return true
+		}
+	}
+	w.jobs <- job
+	return true
+}
+
+// spillEvent appends the event of the given job to the spill file, dropping it if that fails.
+func (w *notificationWorker) spillEvent(job notificationJob) {
+	backends := make([]string, 0, len(job.notifications))
+	for _, n := range job.notifications {
+		backends = append(backends, n.backend)
+	}
+	if err := w.spill.append(job.payload, backends); err != nil {
+		droppedEvents.Add(1)
+		errorf("error: could not spill %s event, dropping it: %s", eventKind(job.payload), err)
+		return
+	}
+	spilledEvents.Add(1)
+}
+
+// Close fans out the queued events and stops the worker, the events fanned out afterwards are sent synchronously.
+// The sends are bounded by the shutdown deadline just like the synchronous ones.
+func (w *notificationWorker) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.jobs)
+	}
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// parseCertPins parses the comma separated host=fingerprint pins of -httpPinnedCerts into the SHA-256 fingerprints
+// (of the DER encoded leaf certificate) accepted per host. Fingerprints are hex encoded, optionally colon separated,
+// and a host may be pinned to several of them to rotate its certificate.
+func parseCertPins(str string) (map[string][]string, error) {
+	pins := make(map[string][]string)
+	for _, pin := range strings.Split(str, ",") {
+		pin = strings.TrimSpace(pin)
+		if pin == "" {
+			continue
+		}
+		split := strings.SplitN(pin, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, fmt.Errorf("invalid pin '%s', must be host=fingerprint", pin)
+		}
+		host := strings.ToLower(split[0])
+		fingerprint := strings.ToLower(strings.ReplaceAll(split[1], ":", ""))
+		if raw, err := hex.DecodeString(fingerprint); err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid fingerprint of host %s, must be the hex encoded SHA-256 of the certificate", host)
+		}
+		pins[host] = append(pins[host], fingerprint)
+	}
+	return pins, nil
+}
+
+// newNotificationTLSConfig builds the TLS config of the notification HTTP client trusting only the CAs of the given
+// PEM bundle (the system trust store if empty) and only accepting the pinned certificates from the pinned hosts. It
+// returns nil if neither is given, keeping Go's defaults.
+func newNotificationTLSConfig(caFile string, pinsStr string) (*tls.Config, error) {
+	if caFile == "" && pinsStr == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM encoded certificates in CA bundle %s", caFile)
+		}
+	}
+	pins, err := parseCertPins(pinsStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		// in addition to the verification of the chain
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			accepted, pinned := pins[strings.ToLower(state.ServerName)]
+			if !pinned {
+				return nil
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			fingerprint := hex.EncodeToString(sum[:])
+			for _, pin := range accepted {
+				if fingerprint == pin {
+					return nil
+				}
+			}
+			return fmt.Errorf("certificate of %s with fingerprint %s doesn't match its pinned fingerprints", state.ServerName, fingerprint)
+		}
+	}
+	return config, nil
+}
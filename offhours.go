@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// severityRanks orders the alert severities, for comparisons against the min. severity paging off hours.
+var severityRanks = map[string]int{"info": 0, "warning": 1, "error": 2, "critical": 3}
+
+// offHoursGate defers the alerts below the min. severity raised during the off hours, sending them as a digest
+// per group at the configured time of day instead.
+type offHoursGate struct {
+	schedule    *maintenanceSchedule
+	minSeverity string
+	// the digest time of day, in minutes since midnight in the schedule's timezone
+	digestAt int
+
+	mu       sync.Mutex
+	deferred map[*watchGroup][]deferredAlert
+}
+
+// deferredAlert is an alert deferred to the digest.
+type deferredAlert struct {
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	Summary  string    `json:"summary"`
+	Time     time.Time `json:"time"`
+}
+
+// deferredDigestEvent is the generic webhook payload of the digest of a group's deferred alerts.
+type deferredDigestEvent struct {
+	Event  string          `json:"event"`
+	Group  string          `json:"group"`
+	Alerts []deferredAlert `json:"alerts"`
+	Time   time.Time       `json:"time"`
+}
+
+var deferredDigestTemplate = `deferred alerts (group %s):
+%s`
+
+func newOffHoursGate(schedule *maintenanceSchedule, minSeverity string, digestAt int) *offHoursGate {
+	return &offHoursGate{
+		schedule: schedule, minSeverity: minSeverity, digestAt: digestAt,
+		deferred: make(map[*watchGroup][]deferredAlert),
+	}
+}
+
+// deferAlert defers the given alert of the given group if raised off hours with a severity below the min. one,
+// reporting whether it was deferred.
+func (g *offHoursGate) deferAlert(group *watchGroup, kind string, in *severityInput, summary string, now time.Time) bool {
+	severity, _ := evaluateSeverity(in)
+	if !g.schedule.active(now) || severityRanks[severity] >= severityRanks[g.minSeverity] {
+		return false
+	}
+	g.mu.Lock()
+	g.deferred[group] = append(g.deferred[group], deferredAlert{Kind: kind, Severity: severity, Summary: summary, Time: now})
+	g.mu.Unlock()
+	deferredAlerts.Add(1)
+	infof("deferred %s alert to the digest: %s", severity, summary)
+	return true
+}
+
+// nextDigest returns the first digest time after the given time.
+func (g *offHoursGate) nextDigest(after time.Time) time.Time {
+	after = after.In(g.schedule.loc)
+	next := time.Date(after.Year(), after.Month(), after.Day(), g.digestAt/60, g.digestAt%60, 0, 0, g.schedule.loc)
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// watch sends the digests of the deferred alerts at the digest time every day, until the given context is done.
+func (g *offHoursGate) watch(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	next := g.nextDigest(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.Before(next) {
+				continue
+			}
+			next = g.nextDigest(now)
+			g.sendDigests(now)
+		}
+	}
+}
+
+// sendDigests sends the deferred alerts of every group to its Slack and generic webhooks.
+func (g *offHoursGate) sendDigests(now time.Time) {
+	g.mu.Lock()
+	deferred := g.deferred
+	g.deferred = make(map[*watchGroup][]deferredAlert)
+	g.mu.Unlock()
+
+	for group, alerts := range deferred {
+		infof("sending digest of %d deferred alert(s) (group %s)", len(alerts), group.Name)
+		event := &deferredDigestEvent{Event: "deferred_digest", Group: group.Name, Alerts: alerts, Time: alertTime()}
+		var lines strings.Builder
+		for _, alert := range alerts {
+			fmt.Fprintf(&lines, "- %s: %s (%s)\n", alert.Time.In(g.schedule.loc).Format("Mon 15:04"), alert.Summary, alert.Severity)
+		}
+		text := renderSlackText(event.Event, event, fmt.Sprintf(deferredDigestTemplate, group.Name, lines.String()))
+
+		var notifications []notification
+		if group.notifier != nil {
+			notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+				return group.notifier.notifyEvent(ctx, text, "", event)
+			}))
+		}
+		if group.WebhookURI != "" {
+			notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+				return sendWebhookPayload(ctx, group.WebhookURI, event)
+			}))
+		}
+		fanOut(event, notifications)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// opsgeniePriorities maps the severities of the severity rules to Opsgenie alert priorities.
+var opsgeniePriorities = map[string]string{"critical": "P1", "error": "P2", "warning": "P3", "info": "P5"}
+
+// opsgenieMaxMessageLen is the max. length of the message of an Opsgenie alert.
+const opsgenieMaxMessageLen = 130
+
+// opsgenieMaxDetailLen is the max. length of the event fields put into the details of an Opsgenie alert, as the details
+// are limited in size and e.g. a tx's signature message fragment doesn't help anyone in an alert.
+const opsgenieMaxDetailLen = 256
+
+// opsgenieAlert is the payload of the Opsgenie Alert API's create alert request.
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details"`
+}
+
+// opsgenieDetails flattens the given event payload into the string details of an Opsgenie alert, adding the explorer
+// links of the given alert's tx and address.
+func opsgenieDetails(in *severityInput, event interface{}) map[string]string {
+	details := make(map[string]string)
+	if content, err := json.Marshal(labelEvent(event)); err == nil {
+		var fields map[string]json.RawMessage
+		json.Unmarshal(content, &fields)
+		for name, value := range fields {
+			str := string(value)
+			json.Unmarshal(value, &str)
+			if len(str) <= opsgenieMaxDetailLen {
+				details[name] = str
+			}
+		}
+	}
+	if in.tx != "" {
+		details["txLink"] = withLinkSuffix(explorerURL(*txExplorerURI, in.tx))
+	}
+	if in.address != "" {
+		details["addressLink"] = withLinkSuffix(explorerURL(*addrExplorerURI, in.address))
+	}
+	return details
+}
+
+// sendOpsgenieAlert creates an Opsgenie alert for the given alert characteristics, with the given details. The alert's
+// alias is the dedup key of the severity rules, so that Opsgenie deduplicates the alerts just like PagerDuty.
+func sendOpsgenieAlert(ctx context.Context, summary string, in *severityInput, details interface{}) error {
+	severity, dedupKey := evaluateSeverity(in)
+	message := summary
+	if len(message) > opsgenieMaxMessageLen {
+		message = message[:opsgenieMaxMessageLen-3] + "..."
+	}
+	alert := &opsgenieAlert{
+		Message:     message,
+		Alias:       dedupKey,
+		Description: summary,
+		Source:      *nodeURI,
+		Entity:      instanceLabel,
+		Priority:    opsgeniePriorities[severity],
+		Tags:        []string{"group:" + in.group},
+		Details:     opsgenieDetails(in, details),
+	}
+	jsonAlert, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("unable to serialize Opsgenie alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *opsgenieURI, bytes.NewReader(jsonAlert))
+	if err != nil {
+		return fmt.Errorf("unable to build Opsgenie request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+strings.TrimSpace(*opsgenieAPIKey))
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST Opsgenie alert: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from POSTing Opsgenie alert: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to POST Opsgenie alert: %s", bodyContent))
+	}
+	return nil
+}
+
+func opsgenieNotification(send func(ctx context.Context) error) notification {
+	return notification{backend: "opsgenie", timeout: opsgenieTimeout, send: send}
+}
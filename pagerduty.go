@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+var pagerDutySeverities = map[string]bool{"info": true, "warning": true, "error": true, "critical": true}
+
+// severityRule maps the characteristics of an alert to a PagerDuty severity and dedup key.
+// Empty/zero conditions match any alert.
+type severityRule struct {
+	Group string `json:"group"`
+	// 'in' for alerts about monitored addresses receiving value, 'out' for spending value
+	Direction string `json:"direction"`
+	// the min. absolute value moved
+	MinValue int64  `json:"minValue"`
+	Severity string `json:"severity"`
+	// what to deduplicate the PagerDuty alerts by: 'tx' (default), 'bundle' or 'address'
+	DedupKey string `json:"dedupKey"`
+}
+
+// severityInput holds the characteristics of an alert evaluated by the severity rules.
+type severityInput struct {
+	group     string
+	direction string
+	value     int64
+	tx        string
+	bundle    string
+	address   string
+	// set for alerts which are critical regardless of the rules (e.g. conflicting spends)
+	critical bool
+}
+
+// severityRules are evaluated in order, the first matching rule wins.
+var severityRules []severityRule
+
+// loadSeverityRules reads the JSON array of severity rules from the given file.
+func loadSeverityRules(path string) ([]severityRule, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read severity rules file: %w", err)
+	}
+	var rules []severityRule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse severity rules file: %w", err)
+	}
+	for i, rule := range rules {
+		if !pagerDutySeverities[rule.Severity] {
+			return nil, fmt.Errorf("severity rule %d: unknown severity '%s'", i+1, rule.Severity)
+		}
+		if rule.Direction != "" && rule.Direction != "in" && rule.Direction != "out" {
+			return nil, fmt.Errorf("severity rule %d: unknown direction '%s'", i+1, rule.Direction)
+		}
+		switch rule.DedupKey {
+		case "", "tx", "bundle", "address":
+		default:
+			return nil, fmt.Errorf("severity rule %d: unknown dedup key '%s'", i+1, rule.DedupKey)
+		}
+	}
+	return rules, nil
+}
+
+func (r *severityRule) matches(in *severityInput) bool {
+	if r.Group != "" && r.Group != in.group {
+		return false
+	}
+	if r.Direction != "" && r.Direction != in.direction {
+		return false
+	}
+	return in.value >= r.MinValue
+}
+
+// evaluateSeverity returns the severity and dedup key of the given alert according to the first matching rule,
+// falling back to the default severity and deduplicating by tx.
+func evaluateSeverity(in *severityInput) (string, string) {
+	severity, dedupBy := *pagerDutySeverity, "tx"
+	for i := range severityRules {
+		if severityRules[i].matches(in) {
+			severity = severityRules[i].Severity
+			if severityRules[i].DedupKey != "" {
+				dedupBy = severityRules[i].DedupKey
+			}
+			break
+		}
+	}
+	if in.critical {
+		severity = "critical"
+	}
+	switch dedupBy {
+	case "bundle":
+		return severity, "bundle/" + in.bundle
+	case "address":
+		return severity, "address/" + in.address
+	}
+	return severity, "tx/" + in.tx
+}
+
+func valueDirection(value int64) (string, int64) {
+	if value < 0 {
+		return "out", -value
+	}
+	return "in", value
+}
+
+func txSeverityInput(event *txEvent) *severityInput {
+	direction, value := valueDirection(event.Value)
+	return &severityInput{
+		group: event.Group, direction: direction, value: value,
+		tx: event.Hash, bundle: event.Bundle, address: event.Address,
+	}
+}
+
+func bundleSeverityInput(summary *bundleSummary) *severityInput {
+	var net int64
+	for _, addr := range summary.Addresses {
+		net += addr.Value
+	}
+	direction, value := valueDirection(net)
+	return &severityInput{
+		group: summary.Group, direction: direction, value: value,
+		tx: summary.TailTx, bundle: summary.Bundle, address: summary.Addresses[0].Address,
+	}
+}
+
+func spendSeverityInput(summary *spendSummary) *severityInput {
+	var spent int64
+	for _, input := range summary.Inputs {
+		spent -= input.Value
+	}
+	return &severityInput{
+		group: summary.Group, direction: "out", value: spent,
+		tx: summary.TailTx, bundle: summary.Bundle, address: summary.Inputs[0].Address,
+	}
+}
+
+// pagerDutyEvent is the payload of the PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string      `json:"summary"`
+	Source        string      `json:"source"`
+	Severity      string      `json:"severity"`
+	Group         string      `json:"group"`
+	Component     string      `json:"component,omitempty"`
+	CustomDetails interface{} `json:"custom_details"`
+}
+
+// sendPagerDutyEvent triggers a PagerDuty alert for the given alert characteristics, with the given details.
+func sendPagerDutyEvent(ctx context.Context, summary string, in *severityInput, details interface{}) error {
+	severity, dedupKey := evaluateSeverity(in)
+	event := &pagerDutyEvent{
+		RoutingKey:  *pagerDutyRoutingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: pagerDutyPayload{
+			Summary:       summary,
+			Source:        *nodeURI,
+			Severity:      severity,
+			Group:         in.group,
+			Component:     instanceLabel,
+			CustomDetails: labelEvent(details),
+		},
+	}
+	jsonEvent, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to serialize PagerDuty event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *pagerDutyURI, bytes.NewReader(jsonEvent))
+	if err != nil {
+		return fmt.Errorf("unable to build PagerDuty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST PagerDuty event: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from POSTing PagerDuty event: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to POST PagerDuty event: %s", bodyContent))
+	}
+	return nil
+}
+
+func pagerDutyNotification(send func(ctx context.Context) error) notification {
+	return notification{backend: "pagerduty", timeout: pagerDutyTimeout, send: send}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// iotasPerMiota is the number of iotas (the raw unit of tx values) in one Mi,
+// the unit in which price feeds quote the price.
+const iotasPerMiota = 1000000
+
+// priceFeed fetches the fiat price of one Mi from an HTTP endpoint responding with just the number,
+// refreshing it in the background every TTL. If fetching fails, no price is available until it's fetched
+// again. The alerts only read the cached price, so that a slow endpoint never stalls them.
+type priceFeed struct {
+	mu     sync.Mutex
+	uri    string
+	ttl    time.Duration
+	client *http.Client
+	price  float64
+	ok     bool
+}
+
+// prices is the feed of the fiat price included in alerts, nil if disabled.
+var prices *priceFeed
+
+func newPriceFeed(uri string, ttl time.Duration, timeout time.Duration) *priceFeed {
+	return &priceFeed{uri: uri, ttl: ttl, client: &http.Client{Timeout: timeout}}
+}
+
+// watch fetches the price right away and then every TTL, until the given context is done.
+func (f *priceFeed) watch(ctx context.Context) {
+	ticker := time.NewTicker(f.ttl)
+	defer ticker.Stop()
+	for {
+		f.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches the price and caches it, the fetch not holding the lock.
+func (f *priceFeed) refresh(ctx context.Context) {
+	price, err := f.fetch(ctx)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		errorf("could not fetch price, omitting fiat values: %s", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.price, f.ok = price, err == nil
+}
+
+// fiatValue returns the estimated fiat value of the given amount of iotas, or nil if no price is available.
+func (f *priceFeed) fiatValue(value int64) *float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.ok {
+		return nil
+	}
+	fiat := float64(value) / iotasPerMiota * f.price
+	return &fiat
+}
+
+func (f *priceFeed) fetch(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.uri, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to build price request: %w", err)
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch price: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode != 200 {
+		return 0, fmt.Errorf("unable to fetch price: unexpected status %s", res.Status)
+	}
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read price: %w", err)
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse price: %w", err)
+	}
+	return price, nil
+}
+
+// fiatValueOf returns the estimated fiat value of the given amount of iotas, or nil if the price feed is disabled
+// or no price is available.
+func fiatValueOf(value int64) *float64 {
+	if prices == nil {
+		return nil
+	}
+	return prices.fiatValue(value)
+}
+
+// displayValue renders the given value for slack msgs, followed by its fiat estimate if available.
+func displayValue(value int64, fiat *float64) string {
+	if fiat == nil {
+		return strconv.FormatInt(value, 10)
+	}
+	return fmt.Sprintf("%d (~%.2f %s)", value, *fiat, *priceCurrency)
+}
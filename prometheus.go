@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// prometheusMetric is a metric exposed on /metrics of the -metricsAddr.
+type prometheusMetric struct {
+	name string
+	// 'counter' or 'gauge'
+	kind  string
+	help  string
+	value func() int64
+}
+
+// prometheusMetrics are the metrics exposed in the Prometheus text format, the monitored addresses of the given
+// groups counting the exact addresses (as loaded with -addrsURL/-addrsFile) but not the prefixes.
+func prometheusMetrics(groups []*watchGroup) []prometheusMetric {
+	return []prometheusMetric{
+		{"addr_monitor_txs_seen_total", "counter", "The txs received from the stream.", txsSeen.Value},
+		{"addr_monitor_txs_matched_total", "counter", "The txs matching a monitored address of any watch group.", txsMatched.Value},
+		{"addr_monitor_notification_failures_total", "counter", "The notifications which couldn't be sent to a backend.", notificationFailures.Value},
+		{"addr_monitor_reconnects_total", "counter", "The reconnects to the nodes after the connection was lost.", streamReconnects.Value},
+		{"addr_monitor_parse_errors_total", "counter", "The frames which couldn't be parsed.", parseErrors.Value},
+		{"addr_monitor_monitored_addresses", "gauge", "The number of monitored addresses.", func() int64 {
+			count := 0
+			for _, group := range groups {
+				count += group.matcher.exact.len()
+			}
+			return int64(count)
+		}},
+	}
+}
+
+// serveMetrics writes the given metrics in the Prometheus text exposition format.
+func serveMetrics(w http.ResponseWriter, r *http.Request, metrics []prometheusMetric) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value())
+	}
+	if err := buf.Flush(); err != nil {
+		errorf("could not write metrics response: %s", err)
+	}
+}
+
+// startMetricsServer serves the metrics of the given watch groups for Prometheus on /metrics of the given address
+// until the context is done.
+func startMetricsServer(ctx context.Context, addr string, groups []*watchGroup) {
+	metrics := prometheusMetrics(groups)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		serveMetrics(w, r, metrics)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Close(); err != nil {
+			errorf("could not close metrics server successfully: %s", err)
+		}
+	}()
+
+	go func() {
+		infof("serving metrics on %s/metrics", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf("metrics server failed: %s", err)
+		}
+	}()
+}
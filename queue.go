@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// notificationQueue persists the requests of the notifications being sent to a directory, one file per request,
+// until they're delivered. Requests left over by a crash or by sends which failed for good are redelivered on startup.
+type notificationQueue struct {
+	dir string
+}
+
+// queuedRequest is a persisted notification request.
+type queuedRequest struct {
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func openNotificationQueue(dir string) (*notificationQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create notification queue directory: %w", err)
+	}
+	return &notificationQueue{dir: dir}, nil
+}
+
+// persist persists the given request and returns its ID, which is derived from the request so that retries of a
+// send overwrite the same entry. Returns an empty ID if the queue is disabled or the request couldn't be persisted.
+func (q *notificationQueue) persist(req *http.Request, body []byte) string {
+	if q == nil {
+		return ""
+	}
+	content, err := json.Marshal(&queuedRequest{URI: req.URL.String(), Header: req.Header, Body: body})
+	if err != nil {
+		errorf("could not persist notification: %s", err)
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	id := hex.EncodeToString(sum[:16])
+	path := filepath.Join(q.dir, id+".json")
+	if _, err := os.Stat(path); err == nil {
+		// a retry of a send still queued
+		return id
+	}
+	tmpPath := filepath.Join(q.dir, id+".tmp")
+	if err := ioutil.WriteFile(tmpPath, content, 0600); err != nil {
+		errorf("could not persist notification: %s", err)
+		return ""
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		errorf("could not persist notification: %s", err)
+		return ""
+	}
+	queuedNotifications.Add(1)
+	return id
+}
+
+// remove removes the entry of the delivered request with the given ID.
+func (q *notificationQueue) remove(id string) {
+	if q == nil || id == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(q.dir, id+".json")); err != nil {
+		if !os.IsNotExist(err) {
+			errorf("could not remove delivered notification from queue: %s", err)
+		}
+		return
+	}
+	queuedNotifications.Add(-1)
+}
+
+// queueingTransport persists the notification requests to its queue until they're delivered, i.e. responded to with
+// a 2xx status, unless their ctx is unqueued.
+type queueingTransport struct {
+	next  http.RoundTripper
+	queue *notificationQueue
+}
+
+func (t *queueingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(unqueuedKey{}) != nil || req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	id := t.queue.persist(req, content)
+	res, err := t.next.RoundTrip(req)
+	if err == nil && res.StatusCode >= 200 && res.StatusCode <= 299 {
+		t.queue.remove(id)
+	}
+	return res, err
+}
+
+type unqueuedKey struct{}
+
+// unqueued marks the requests of the given ctx as not to be persisted to the queue, e.g. signed ones which expire
+// before they could be redelivered or redeliveries of queued ones.
+func unqueued(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unqueuedKey{}, true)
+}
+
+// redeliver sends the requests left in the queue, removing every delivered one. Requests which fail again stay
+// in the queue for the next startup.
+func (q *notificationQueue) redeliver(ctx context.Context) {
+	entries, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		errorf("could not list notification queue: %s", err)
+		return
+	}
+	sort.Strings(entries)
+	queuedNotifications.Add(int64(len(entries)))
+	if len(entries) > 0 {
+		infof("redelivering %d queued notification(s)", len(entries))
+	}
+	for _, path := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		if err := q.redeliverEntry(ctx, path); err != nil {
+			errorf("could not redeliver queued notification %s: %s", id, err)
+			continue
+		}
+		q.remove(id)
+	}
+}
+
+func (q *notificationQueue) redeliverEntry(ctx context.Context, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entry queuedRequest
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return fmt.Errorf("unable to parse queue entry: %w", err)
+	}
+	req, err := http.NewRequestWithContext(unqueued(ctx), http.MethodPost, entry.URI, bytes.NewReader(entry.Body))
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+	req.Header = entry.Header
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unable to POST: status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// reattachmentFilter remembers the bundles alerted about within its window, so that reattachments of a bundle,
+// which share its bundle hash but have new tx hashes, aren't alerted about (and tallied) as new transfers.
+// At most maxEntries bundles are remembered, the oldest ones are forgotten first.
+type reattachmentFilter struct {
+	window     time.Duration
+	maxEntries int
+	seen       map[string]struct{}
+	// the remembered keys in the order they were first seen, which is also the order they expire in
+	order []reattachmentEntry
+}
+
+type reattachmentEntry struct {
+	key       string
+	firstSeen time.Time
+}
+
+func newReattachmentFilter(window time.Duration, maxEntries int) *reattachmentFilter {
+	return &reattachmentFilter{window: window, maxEntries: maxEntries, seen: make(map[string]struct{})}
+}
+
+// reattached reports whether the given bundle was already seen for the given group and address within the window,
+// remembering it otherwise. An empty address stands for the bundle as a whole.
+func (f *reattachmentFilter) reattached(group string, bundle string, addr string, now time.Time) bool {
+	for len(f.order) > 0 && now.Sub(f.order[0].firstSeen) > f.window {
+		f.forgetOldest()
+	}
+
+	key := group + "/" + bundle + "/" + addr
+	if _, has := f.seen[key]; has {
+		return true
+	}
+	if len(f.order) >= f.maxEntries {
+		f.forgetOldest()
+	}
+	f.seen[key] = struct{}{}
+	f.order = append(f.order, reattachmentEntry{key: key, firstSeen: now})
+	reattachmentCacheEntries.Set(int64(len(f.order)))
+	return false
+}
+
+func (f *reattachmentFilter) forgetOldest() {
+	delete(f.seen, f.order[0].key)
+	f.order = f.order[1:]
+	reattachmentCacheEntries.Set(int64(len(f.order)))
+}
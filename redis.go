@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisDedup deduplicates alerts across replicas by claiming the ID of every alert in a shared Redis via SET NX,
+// only the replica which successfully claimed an alert sends it.
+type redisDedup struct {
+	mu      sync.Mutex
+	addr    string
+	ttl     time.Duration
+	timeout time.Duration
+	// whether alerts are skipped rather than sent if Redis isn't reachable
+	failClosed bool
+	conn       net.Conn
+	r          *bufio.Reader
+}
+
+func newRedisDedup(addr string, ttl time.Duration, timeout time.Duration, failClosed bool) *redisDedup {
+	return &redisDedup{addr: addr, ttl: ttl, timeout: timeout, failClosed: failClosed}
+}
+
+// claim reports whether the alert with the given ID should be sent by this replica. If Redis isn't reachable,
+// the alert is sent anyway, as a duplicated alert is preferable over a missed one, unless the dedup fails closed.
+func (d *redisDedup) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	claimed, err := d.setNX("addr_monitor:alert:"+id, d.ttl)
+	if err != nil {
+		if d.conn != nil {
+			d.conn.Close()
+			d.conn = nil
+		}
+		if d.failClosed {
+			errorf("could not deduplicate alert %s via redis, skipping it: %s", id, err)
+			return false
+		}
+		errorf("could not deduplicate alert %s via redis, sending it anyway: %s", id, err)
+		return true
+	}
+	return claimed
+}
+
+// setNX sets the given key if it doesn't exist yet, reporting whether it was set.
+func (d *redisDedup) setNX(key string, ttl time.Duration) (bool, error) {
+	if d.conn == nil {
+		conn, err := net.DialTimeout("tcp", d.addr, d.timeout)
+		if err != nil {
+			return false, fmt.Errorf("unable to connect to redis: %w", err)
+		}
+		d.conn, d.r = conn, bufio.NewReader(conn)
+	}
+	if err := d.conn.SetDeadline(time.Now().Add(d.timeout)); err != nil {
+		return false, err
+	}
+
+	args := []string{"SET", key, "1", "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10)}
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := d.conn.Write([]byte(cmd.String())); err != nil {
+		return false, fmt.Errorf("unable to send redis command: %w", err)
+	}
+
+	reply, err := d.r.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("unable to read redis reply: %w", err)
+	}
+	switch reply = strings.TrimRight(reply, "\r\n"); {
+	case reply == "+OK":
+		return true, nil
+	case reply == "$-1":
+		// the key already exists
+		return false, nil
+	case strings.HasPrefix(reply, "-"):
+		return false, fmt.Errorf("redis error: %s", reply[1:])
+	}
+	return false, fmt.Errorf("unexpected redis reply '%s'", reply)
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// remoteAddrList is a set of monitored addresses fetched from a remote HTTP endpoint or read from a file, combined
+// with a static set of addresses. The set is swapped atomically on every successful refresh and kept as is if a
+// refresh fails.
+type remoteAddrList struct {
+	// the URI or path the addresses are loaded from
+	source string
+	static []string
+	fetch  func() ([]byte, error)
+	// holds the current addrLookupHolder
+	current atomic.Value
+	// holds the []string of the current addresses, listed without waiting for a reload in progress
+	list atomic.Value
+	// serializes the reloads, e.g. on SIGHUP and on a change of the file
+	reloadMu sync.Mutex
+	// the addresses of the last successful load, nil before the first one
+	loaded map[string]struct{}
+	// called with the addresses added and removed by a reload, if set
+	onChange func(added []string, removed []string)
+	// the max. number of addresses including the static ones, 0 for no limit
+	maxAddrs int
+	// the max. percentage by which a reload may shrink the loaded addresses, 0 for no limit
+	maxShrink float64
+	// whether a reload was refused for shrinking the addresses since the last successful load
+	shrinkRefused bool
+}
+
+// addrLookupHolder wraps the addrLookup stored in an atomic.Value, which requires a consistent concrete type.
+type addrLookupHolder struct {
+	addrLookup
+}
+
+// addrsSourceFlag returns the flag the monitored addresses are loaded from, -addrsFile taking precedence.
+func addrsSourceFlag() string {
+	if *addrsFile != "" {
+		return "-addrsFile"
+	}
+	return "-addrsURL"
+}
+
+func newRemoteAddrList(uri string, static []string, timeout time.Duration) *remoteAddrList {
+	client := &http.Client{Timeout: timeout}
+	return newAddrList(uri, static, func() ([]byte, error) {
+		res, err := client.Get(uri)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch addresses: %w", err)
+		}
+		defer closeResponse(res)
+		if res.StatusCode != 200 {
+			return nil, fmt.Errorf("unable to fetch addresses: unexpected status %s", res.Status)
+		}
+		content, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read fetched addresses: %w", err)
+		}
+		return content, nil
+	})
+}
+
+// newFileAddrList creates an address list whose addresses are read from the file at the given path.
+func newFileAddrList(path string, static []string) *remoteAddrList {
+	return newAddrList(path, static, func() ([]byte, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read addresses: %w", err)
+		}
+		return content, nil
+	})
+}
+
+func newAddrList(source string, static []string, fetch func() ([]byte, error)) *remoteAddrList {
+	l := &remoteAddrList{source: source, static: normalizeAddrs(static), fetch: fetch}
+	l.current.Store(addrLookupHolder{newAddrLookup(l.static)})
+	l.list.Store(l.static)
+	return l
+}
+
+func (l *remoteAddrList) has(addr string) bool {
+	return l.current.Load().(addrLookupHolder).has(addr)
+}
+
+func (l *remoteAddrList) len() int {
+	return l.current.Load().(addrLookupHolder).len()
+}
+
+// addrs returns the monitored addresses, the static ones followed by the ones of the last successful load. The
+// returned slice must not be modified.
+func (l *remoteAddrList) addrs() []string {
+	return l.list.Load().([]string)
+}
+
+// refresh loads the addresses from the source and swaps the set if all of them are valid.
+// The source must consist of the addresses separated by newlines and/or commas, blank lines and '#' comments
+// (up to the end of their line) are skipped.
+func (l *remoteAddrList) refresh() error {
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+	content, err := l.fetch()
+	if err != nil {
+		return err
+	}
+
+	var remote []string
+	// the line of every address, for reporting invalid ones
+	var lines []int
+	for i, line := range strings.Split(string(content), "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		for _, addr := range parseAddrList(line) {
+			remote = append(remote, addr)
+			lines = append(lines, i+1)
+		}
+	}
+	if l.maxAddrs > 0 && len(l.static)+len(remote) > l.maxAddrs {
+		return fmt.Errorf("loaded %d address(es), together with the other monitored addresses more than -maxAddresses %d", len(remote), *maxAddresses)
+	}
+	logAddrCleanups(l.source, remote...)
+	for i, addr := range remote {
+		normalized, err := normalizeAddr(addr)
+		if err != nil {
+			return fmt.Errorf("loaded %w on line %d", err, lines[i])
+		}
+		remote[i] = normalized
+	}
+	loaded := make(map[string]struct{}, len(remote))
+	for _, addr := range remote {
+		loaded[addr] = struct{}{}
+	}
+	if duplicates := len(remote) - len(loaded); duplicates > 0 {
+		warnf("warning: %d of the address(es) loaded from %s are duplicates", duplicates, l.source)
+	}
+	if l.loaded != nil && l.maxShrink > 0 && len(loaded) < len(l.loaded) {
+		if shrink := float64(len(l.loaded)-len(loaded)) / float64(len(l.loaded)) * 100; shrink > l.maxShrink {
+			if !l.shrinkRefused {
+				notifyAddrsShrinkRefused(addrsSourceFlag(), len(l.loaded), len(loaded), shrink)
+			}
+			l.shrinkRefused = true
+			return fmt.Errorf("loaded %d address(es) instead of the previous %d, shrinking them by %.0f%% (more than -addrsMaxShrink %v%%)", len(loaded), len(l.loaded), shrink, l.maxShrink)
+		}
+	}
+	addrs := make([]string, 0, len(l.static)+len(remote))
+	addrs = append(addrs, l.static...)
+	addrs = append(addrs, remote...)
+	l.current.Store(addrLookupHolder{newAddrLookup(addrs)})
+	list := append([]string(nil), l.static...)
+	for addr := range loaded {
+		list = append(list, addr)
+	}
+	l.list.Store(list)
+	infof("loaded %d address(es) to monitor from %s", len(remote), l.source)
+	l.shrinkRefused = false
+
+	previous := l.loaded
+	l.loaded = loaded
+	if previous == nil || l.onChange == nil {
+		return nil
+	}
+	added, removed := addrSetDiff(previous, loaded)
+	if len(added) != 0 || len(removed) != 0 {
+		l.onChange(added, removed)
+	}
+	return nil
+}
+
+// addrSetDiff returns the sorted addresses added to and removed from the given previous set by the given current one.
+func addrSetDiff(previous map[string]struct{}, current map[string]struct{}) (added []string, removed []string) {
+	for addr := range current {
+		if _, has := previous[addr]; !has {
+			added = append(added, addr)
+		}
+	}
+	for addr := range previous {
+		if _, has := current[addr]; !has {
+			removed = append(removed, addr)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// addrsChangedEvent is the generic webhook payload of a change of the monitored addresses loaded from a source.
+type addrsChangedEvent struct {
+	Event   string    `json:"event"`
+	Source  string    `json:"source"`
+	Added   []string  `json:"added"`
+	Removed []string  `json:"removed"`
+	Time    time.Time `json:"time"`
+}
+
+// maxListedAddrChanges is the max. number of added and removed addresses each listed in the Slack msg.
+const maxListedAddrChanges = 10
+
+// notifyAddrsChanged notifies the operators about the given addresses added to and removed from the given source.
+func notifyAddrsChanged(source string, added []string, removed []string) {
+	infof("monitored addresses loaded from %s changed: %d added, %d removed", source, len(added), len(removed))
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "monitoring:\n- the monitored addresses loaded from %s changed: %d added, %d removed\n", source, len(added), len(removed))
+	for _, change := range []struct {
+		verb  string
+		addrs []string
+	}{{"added", added}, {"removed", removed}} {
+		for i, addr := range change.addrs {
+			if i == maxListedAddrChanges {
+				fmt.Fprintf(&msg, "- and %d more %s\n", len(change.addrs)-i, change.verb)
+				break
+			}
+			fmt.Fprintf(&msg, "- %s %s\n", change.verb, explorerLink(*addrExplorerURI, *addrMirrorURI, addr))
+		}
+	}
+	event := &addrsChangedEvent{Event: "addrs_changed", Source: source, Added: added, Removed: removed, Time: alertTime()}
+	if event.Added == nil {
+		event.Added = []string{}
+	}
+	if event.Removed == nil {
+		event.Removed = []string{}
+	}
+	notifyOperators(renderSlackText(event.Event, event, msg.String()), event)
+}
+
+// addrsShrinkRefusedEvent is the generic webhook payload of a reload of the monitored addresses refused for shrinking
+// them by more than the -addrsMaxShrink.
+type addrsShrinkRefusedEvent struct {
+	Event    string `json:"event"`
+	Source   string `json:"source"`
+	Previous int    `json:"previous"`
+	Loaded   int    `json:"loaded"`
+	// the percentage by which the reload would have shrunk the addresses
+	Shrink float64   `json:"shrink"`
+	Time   time.Time `json:"time"`
+}
+
+var addrsShrinkRefusedTemplate = `monitoring: *refused to reload the monitored addresses* from %s
+- loaded %d address(es) instead of the previous %d, shrinking them by %.0f%%
+- keeping the last loaded addresses
+`
+
+// notifyAddrsShrinkRefused notifies the operators about a refused reload of the given source shrinking the given
+// previous number of addresses to the given loaded one.
+func notifyAddrsShrinkRefused(source string, previous int, loaded int, shrink float64) {
+	event := &addrsShrinkRefusedEvent{Event: "addrs_shrink_refused", Source: source, Previous: previous, Loaded: loaded, Shrink: shrink, Time: alertTime()}
+	notifyOperators(renderSlackText(event.Event, event, fmt.Sprintf(addrsShrinkRefusedTemplate, source, loaded, previous, shrink)), event)
+}
+
+// reloadOnSignal reloads the addresses whenever the given signal is received, until the given context is done.
+func (l *remoteAddrList) reloadOnSignal(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			infof("reloading the addresses from %s on %s", l.source, sig)
+			if err := l.refresh(); err != nil {
+				warnf("warning: keeping the last loaded addresses: %s", err)
+			}
+		}
+	}
+}
+
+// refreshPeriodically refreshes the addresses at the given interval until the given context is done.
+func (l *remoteAddrList) refreshPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.refresh(); err != nil {
+				warnf("warning: keeping the last loaded addresses: %s", err)
+			}
+		}
+	}
+}
+
+// fileWatchDebounce is how long changes of a watched file have to settle before it's reloaded,
+// coalescing e.g. the multiple events of a Kubernetes ConfigMap update.
+const fileWatchDebounce = time.Second
+
+// reloadOnChange reloads the addresses whenever the file they're read from changes, until the given context is done.
+// The file's directory is watched, as mounted ConfigMaps and Secrets are updated by atomically swapping a symlink.
+func (l *remoteAddrList) reloadOnChange(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to watch addresses file: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(l.source)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("unable to watch addresses file: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		debounce := time.NewTimer(fileWatchDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				// any change within the directory reloads the file, as swapping a symlink (e.g. '..data')
+				// changes it without an event naming it
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				debounce.Reset(fileWatchDebounce)
+			case err := <-watcher.Errors:
+				errorf("could not watch addresses file: %s", err)
+			case <-debounce.C:
+				if err := l.refresh(); err != nil {
+					warnf("warning: keeping the last loaded addresses: %s", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
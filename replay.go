@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// Recordings consist of one base64 (standard encoding) encoded ZMQ message per line.
+
+// replayReport collects what would have been notified while replaying a recording.
+type replayReport struct {
+	frames          int
+	parseErrors     int
+	groups          []string
+	txs             map[string][]*transaction.Transaction
+	bundles         map[string][]*bundleSummary
+	spends          map[string][]*spendSummary
+	conflicts       map[string][]*conflictEvent
+	zeroValues      map[string][]*zeroValueEvent
+	firstActivities map[string][]*firstActivityEvent
+	addrReuses      map[string][]*addrReuseEvent
+	shadowTxs       []*transaction.Transaction
+}
+
+func newReplayReport(groups []*watchGroup) *replayReport {
+	report := &replayReport{
+		txs:             make(map[string][]*transaction.Transaction),
+		bundles:         make(map[string][]*bundleSummary),
+		spends:          make(map[string][]*spendSummary),
+		conflicts:       make(map[string][]*conflictEvent),
+		zeroValues:      make(map[string][]*zeroValueEvent),
+		firstActivities: make(map[string][]*firstActivityEvent),
+		addrReuses:      make(map[string][]*addrReuseEvent),
+	}
+	for _, group := range groups {
+		report.groups = append(report.groups, group.Name)
+	}
+	return report
+}
+
+func (r *replayReport) addTx(group *watchGroup, tx *transaction.Transaction) {
+	r.txs[group.Name] = append(r.txs[group.Name], tx)
+}
+
+func (r *replayReport) addBundle(group *watchGroup, summary *bundleSummary) {
+	r.bundles[group.Name] = append(r.bundles[group.Name], summary)
+}
+
+func (r *replayReport) addSpend(group *watchGroup, summary *spendSummary) {
+	r.spends[group.Name] = append(r.spends[group.Name], summary)
+}
+
+func (r *replayReport) addConflict(group *watchGroup, event *conflictEvent) {
+	r.conflicts[group.Name] = append(r.conflicts[group.Name], event)
+}
+
+func (r *replayReport) addZeroValue(group *watchGroup, event *zeroValueEvent) {
+	r.zeroValues[group.Name] = append(r.zeroValues[group.Name], event)
+}
+
+func (r *replayReport) addFirstActivity(group *watchGroup, event *firstActivityEvent) {
+	r.firstActivities[group.Name] = append(r.firstActivities[group.Name], event)
+}
+
+func (r *replayReport) addAddrReuse(group *watchGroup, event *addrReuseEvent) {
+	r.addrReuses[group.Name] = append(r.addrReuses[group.Name], event)
+}
+
+func (r *replayReport) print(w io.Writer) {
+	fmt.Fprintf(w, "replayed %d frames (%d unparsable)\n", r.frames, r.parseErrors)
+	for _, name := range r.groups {
+		fmt.Fprintf(w, "group %s: %d tx alert(s), %d bundle alert(s), %d spend alert(s)\n", name, len(r.txs[name]), len(r.bundles[name]), len(r.spends[name]))
+		for _, tx := range r.txs[name] {
+			fmt.Fprintf(w, "  tx %s on address %s with value %d\n", tx.Hash, tx.Address, tx.Value)
+		}
+		for _, summary := range r.bundles[name] {
+			fmt.Fprintf(w, "  bundle %s transferring %d touching %d monitored address(es) receiving %d\n", summary.Bundle, summary.Value, len(summary.Addresses), summary.Received)
+		}
+		for _, summary := range r.spends[name] {
+			fmt.Fprintf(w, "  bundle %s spending from %d monitored address(es)\n", summary.Bundle, len(summary.Inputs))
+		}
+		for _, event := range r.conflicts[name] {
+			fmt.Fprintf(w, "  conflicting spends of address %s in bundles %s and %s\n", event.Address, event.ConflictingBundle, event.Bundle)
+		}
+		for _, event := range r.zeroValues[name] {
+			fmt.Fprintf(w, "  zero-value tx %s on address %s\n", event.Tx, event.Address)
+		}
+		for _, event := range r.firstActivities[name] {
+			fmt.Fprintf(w, "  first activity on address %s with tx %s\n", event.Address, event.Tx)
+		}
+		for _, event := range r.addrReuses[name] {
+			fmt.Fprintf(w, "  address %s spent from for the %s time, in bundle %s\n", event.Address, ordinal(event.Spends), event.Bundle)
+		}
+	}
+	if len(r.shadowTxs) > 0 {
+		fmt.Fprintf(w, "shadow addresses: %d tx match(es)\n", len(r.shadowTxs))
+		for _, tx := range r.shadowTxs {
+			fmt.Fprintf(w, "  tx %s on address %s with value %d\n", tx.Hash, tx.Address, tx.Value)
+		}
+	}
+}
+
+// replayRecording runs every frame of the given recording through the given Monitor and prints a report of the
+// alerts which would have been sent. No notifications are sent while replaying.
+func replayRecording(m *Monitor, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open recording: %w", err)
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	// archives of rotated recordings
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("unable to open recording: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	m.report = newReplayReport(m.groups)
+	// the recorded txs are aged by now
+	m.maxAge = 0
+	scanner := bufio.NewScanner(reader)
+	// frames of the trytes topic are ~2.7k bytes, leave plenty of headroom
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		msg, err := base64.StdEncoding.DecodeString(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("unable to decode frame on line %d of recording: %w", line, err)
+		}
+		m.report.frames++
+		if err := m.ProcessFrame(string(msg)); err != nil {
+			m.report.parseErrors++
+			strictFail("%s", err)
+			logEvent(levelError, logFields{Event: "parse_error"}, "%s", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read recording: %w", err)
+	}
+
+	if m.aggregator != nil {
+		m.flushAggregates(time.Now().Add(m.aggregator.window))
+	}
+	if m.debouncer != nil {
+		m.flushDebounced(time.Now().Add(m.debouncer.window))
+	}
+	m.report.print(os.Stdout)
+	return nil
+}
+
+// frameRecorder appends received ZMQ messages to a recording. Once the recording exceeds the max. size or age,
+// it is rotated: the recording is archived as '<path>.<time>.gz' and a new one is started, keeping at most the
+// given number of archives (the oldest ones are pruned first).
+type frameRecorder struct {
+	path        string
+	maxSize     int64
+	maxAge      time.Duration
+	maxArchives int
+
+	w      *bufio.Writer
+	f      *os.File
+	size   int64
+	frames int64
+	opened time.Time
+	// tracks the archiving of rotated recordings in the background
+	archiving sync.WaitGroup
+}
+
+func newFrameRecorder(path string, maxSize int64, maxAge time.Duration, maxArchives int) (*frameRecorder, error) {
+	r := &frameRecorder{path: path, maxSize: maxSize, maxAge: maxAge, maxArchives: maxArchives}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the recording for appending, picking up the size and number of frames of an existing one.
+func (r *frameRecorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open recording: %w", err)
+	}
+	frames, size, err := countLines(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to read recording: %w", err)
+	}
+	r.w, r.f, r.size, r.frames, r.opened = bufio.NewWriter(f), f, size, frames, time.Now()
+	recordingBytes.Set(r.size)
+	recordingFrames.Set(r.frames)
+	return nil
+}
+
+func countLines(f *os.File) (lines int64, size int64, err error) {
+	reader := bufio.NewReader(f)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		size += int64(len(chunk))
+		if err == nil {
+			lines++
+			continue
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return lines, size, nil
+		}
+		return lines, size, err
+	}
+}
+
+func (r *frameRecorder) record(msg []byte) error {
+	if r.dueForRotation(time.Now()) {
+		if err := r.rotate(); err != nil {
+			return fmt.Errorf("unable to rotate recording: %w", err)
+		}
+	}
+	line := base64.StdEncoding.EncodeToString(msg)
+	if _, err := r.w.WriteString(line); err != nil {
+		return err
+	}
+	if err := r.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	r.size += int64(len(line)) + 1
+	r.frames++
+	recordingBytes.Set(r.size)
+	recordingFrames.Set(r.frames)
+	return nil
+}
+
+func (r *frameRecorder) dueForRotation(now time.Time) bool {
+	if r.frames == 0 {
+		return false
+	}
+	return (r.maxSize > 0 && r.size >= r.maxSize) || (r.maxAge > 0 && now.Sub(r.opened) >= r.maxAge)
+}
+
+// rotate moves the recording aside, starts a new one and archives the old one in the background.
+func (r *frameRecorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%s", r.path, time.Now().UTC().Format("20060102T150405.000Z"))
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	infof("rotated recording %s (%d frames, %d bytes) to %s", r.path, r.frames, r.size, rotated)
+	r.archiving.Add(1)
+	go func() {
+		defer r.archiving.Done()
+		if err := archiveRecording(rotated); err != nil {
+			errorf("could not archive rotated recording %s: %s", rotated, err)
+			return
+		}
+		r.pruneArchives()
+	}()
+	return r.open()
+}
+
+// archiveRecording gzip compresses the given rotated recording to '<path>.gz' and removes it.
+func archiveRecording(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// pruneArchives removes the oldest archives of the recording exceeding the max. number of archives.
+func (r *frameRecorder) pruneArchives() {
+	if r.maxArchives <= 0 {
+		return
+	}
+	archives, err := filepath.Glob(r.path + ".*.gz")
+	if err != nil {
+		errorf("could not list archived recordings: %s", err)
+		return
+	}
+	// the timestamps in the names sort chronologically
+	sort.Strings(archives)
+	for len(archives) > r.maxArchives {
+		if err := os.Remove(archives[0]); err != nil {
+			errorf("could not prune archived recording: %s", err)
+		} else {
+			infof("pruned archived recording %s", archives[0])
+		}
+		archives = archives[1:]
+	}
+}
+
+func (r *frameRecorder) closeFile() error {
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// Close closes the recording, waiting for rotated recordings still being archived.
+func (r *frameRecorder) Close() error {
+	err := r.closeFile()
+	r.archiving.Wait()
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// seenCache remembers the alerts sent within the window, so that the alerts of txs received again (e.g. as the node
+// replays its recent txs after a reconnect, or from every node with -nodeMode fanin) aren't sent twice. Entries
+// older than the window are expired by its janitor, bounding its memory.
+type seenCache struct {
+	window time.Duration
+
+	mu sync.Mutex
+	// the time each alert ID was first seen at
+	seen map[string]time.Time
+}
+
+func newSeenCache(window time.Duration) *seenCache {
+	return &seenCache{window: window, seen: make(map[string]time.Time)}
+}
+
+// add records the given alert ID as seen at the given time, reporting whether it wasn't seen within the window yet.
+func (c *seenCache) add(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seen, has := c.seen[id]; has && now.Sub(seen) < c.window {
+		return false
+	}
+	c.seen[id] = now
+	return true
+}
+
+// expire forgets the alert IDs seen longer than the window ago.
+func (c *seenCache) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, seen := range c.seen {
+		if now.Sub(seen) >= c.window {
+			delete(c.seen, id)
+		}
+	}
+}
+
+// janitor expires the cache's entries every window until the given context is done.
+func (c *seenCache) janitor(ctx context.Context) {
+	ticker := time.NewTicker(c.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.expire(now)
+		}
+	}
+}
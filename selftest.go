@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// The fields of the sample tx, as serialized by the pinned iota.go version.
+var (
+	sampleTxAddress = "SAMPLE" + strings.Repeat("A", 75)
+	sampleTxBundle  = "BUNDLE" + strings.Repeat("B", 75)
+	sampleTxTrunk   = "TRUNK" + strings.Repeat("C", 76)
+	sampleTxBranch  = "BRANCH" + strings.Repeat("D", 75)
+	sampleTxTag     = "SAMPLETAG" + strings.Repeat("9", 18)
+	sampleTxNonce   = strings.Repeat("N", 27)
+	sampleTxHash    = "WRIMMBJLZRQSBEUYODLEJZPSSRYI9ZCTNATATYAOHHLAVGKEIYAVMYZZ9BISIAIQZRFISMOOXTPRBNXQY"
+	sampleTxTrytes  = strings.Repeat("9", 2187) + sampleTxAddress +
+		// value -1234567, obsolete tag, timestamp 1617700000, current index 1, last index 3
+		"HMGRY9999999999999999999999" + "OBSOLETE9999999999999999999" + "VFR9TED99" + "A99999999" + "C99999999" +
+		sampleTxBundle + sampleTxTrunk + sampleTxBranch + sampleTxTag +
+		// attachment timestamp 1617700000123, its lower (0) and upper bound
+		"JNRZHORTF" + "999999999" + "MMMMMMMMM" + sampleTxNonce
+)
+
+// selfTestTxParsing parses the sample tx and checks that iota.go populates its fields as expected, as changes of the
+// tx library (e.g. a renamed or reordered field after bumping iota.go) would otherwise silently produce wrong alerts.
+func selfTestTxParsing() error {
+	tx, err := transaction.AsTransactionObject(sampleTxTrytes, sampleTxHash)
+	if err != nil {
+		return fmt.Errorf("unable to parse the sample tx: %w", err)
+	}
+	var mismatches []string
+	check := func(field string, got, expected interface{}) {
+		if got != expected {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %v instead of %v", field, got, expected))
+		}
+	}
+	check("hash", tx.Hash, sampleTxHash)
+	check("address", tx.Address, sampleTxAddress)
+	check("value", tx.Value, int64(-1234567))
+	check("obsolete tag", tx.ObsoleteTag, "OBSOLETE"+strings.Repeat("9", 19))
+	check("timestamp", tx.Timestamp, uint64(1617700000))
+	check("current index", tx.CurrentIndex, uint64(1))
+	check("last index", tx.LastIndex, uint64(3))
+	check("bundle", tx.Bundle, sampleTxBundle)
+	check("trunk tx", tx.TrunkTransaction, sampleTxTrunk)
+	check("branch tx", tx.BranchTransaction, sampleTxBranch)
+	check("tag", tx.Tag, sampleTxTag)
+	check("attachment timestamp", tx.AttachmentTimestamp, int64(1617700000123))
+	check("nonce", tx.Nonce, sampleTxNonce)
+	check("computed hash", transaction.TransactionHash(tx), sampleTxHash)
+	// injected txs are serialized by iota.go
+	if trytes, err := transaction.TransactionToTrytes(tx); err != nil || string(trytes) != sampleTxTrytes {
+		mismatches = append(mismatches, "the sample tx doesn't serialize back to its trytes")
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("the sample tx was parsed unexpectedly: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// runSelfTest runs the self-test of the tx parsing on startup, logging an error if it fails or, in strict mode,
+// refusing to start.
+func runSelfTest() {
+	err := selfTestTxParsing()
+	if err == nil {
+		return
+	}
+	if *strict {
+		fatalf("error: strict mode: self-test of the tx parsing failed, the iota.go version in use is incompatible: %s", err)
+	}
+	errorf("error: self-test of the tx parsing failed, the iota.go version in use is incompatible and alerts may be wrong: %s", err)
+}
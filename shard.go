@@ -0,0 +1,19 @@
+package main
+
+import "hash/fnv"
+
+// shard deterministically partitions the alerts across replicas without any coordination in between them:
+// every address (or bundle) is owned by the replica its hash maps to plus the following overlap replicas.
+type shard struct {
+	instance uint64
+	replicas uint64
+	overlap  uint64
+}
+
+// owns reports whether the given address or bundle hash is handled by this replica.
+func (s *shard) owns(key string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	primary := h.Sum64() % s.replicas
+	return (s.instance+s.replicas-primary)%s.replicas <= s.overlap
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// forcedExitGrace is how long the cleanup may take after the shutdown deadline abandoned the pending sends,
+// before the monitor flushes its state and exits regardless.
+const forcedExitGrace = 2 * time.Second
+
+// shutdownCtx is done once the shutdown deadline passed, abandoning the notifications still being sent.
+var shutdownCtx, expireShutdown = context.WithCancel(context.Background())
+
+// the flushes of the state (e.g. the recording and the delivery state) run on a forced exit
+var (
+	stateFlushesMu sync.Mutex
+	stateFlushes   []func()
+)
+
+// closeOnce returns a func closing the given closer at most once, for both the regular cleanup and a forced exit.
+// The func is registered to run on a forced exit.
+func closeOnce(name string, c io.Closer) func() {
+	var once sync.Once
+	closeFunc := func() {
+		once.Do(func() {
+			if err := c.Close(); err != nil {
+				errorf("could not close %s successfully: %s", name, err)
+			}
+		})
+	}
+	stateFlushesMu.Lock()
+	stateFlushes = append(stateFlushes, closeFunc)
+	stateFlushesMu.Unlock()
+	return closeFunc
+}
+
+// enforceShutdownDeadline bounds the shutdown by the given timeout: once it passed, the notifications still being
+// sent are abandoned, and if the monitor still didn't exit within the forcedExitGrace, its state is flushed and
+// it exits non-zero.
+func enforceShutdownDeadline(timeout time.Duration) {
+	time.AfterFunc(timeout, func() {
+		errorf("shutdown didn't complete within %v, abandoning pending notifications", timeout)
+		expireShutdown()
+		time.AfterFunc(forcedExitGrace, func() {
+			warnf("shutdown still didn't complete %v after the deadline, flushing state and exiting", forcedExitGrace)
+			flushStateAndExit()
+		})
+	})
+}
+
+// flushStateAndExit flushes the state and exits non-zero, without waiting for the pending sends.
+func flushStateAndExit() {
+	stateFlushesMu.Lock()
+	for _, flush := range stateFlushes {
+		flush()
+	}
+	os.Exit(1)
+}
+
+// strictFail fails fast on the given anomaly with -strict, flushing the state and exiting.
+// Does nothing if not strict, tolerating the anomaly.
+func strictFail(format string, args ...interface{}) {
+	if !*strict {
+		return
+	}
+	errorf("error: strict mode: "+format, args...)
+	flushStateAndExit()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// SlackNotifier posts the alerts as msgs to a Slack webhook, with the explorer links in Slack's '<url|text>' markup.
+type SlackNotifier struct {
+	URI string
+}
+
+// Notify posts the chat msg of the given tx alert, as an attachment colored by its severity with -slackColors.
+func (n *SlackNotifier) Notify(ctx context.Context, tx *transaction.Transaction) error {
+	event := txEventOf(ctx, tx)
+	return n.notifyEvent(ctx, slackTxText(event), slackTxColor(event), event)
+}
+
+// notifyEvent posts the given text to the Slack webhook, as an attachment with the given color if not empty.
+func (n *SlackNotifier) notifyEvent(ctx context.Context, text string, color string, _ interface{}) error {
+	text = truncateMsg(text, instanceFooter(), *maxMsgLength)
+	payload := &slackWebhookPayload{Text: text}
+	if color != "" {
+		payload = &slackWebhookPayload{Attachments: []slackAttachment{{Color: color, Text: text, Fallback: text}}}
+	}
+	jsonWebHookPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialize slack webhook payload: %w", err)
+	}
+	if slackLimiter != nil {
+		if err := slackLimiter.wait(ctx); err != nil {
+			return fmt.Errorf("unable to POST slack webhook payload within the rate limit: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URI, bytes.NewReader(jsonWebHookPayload))
+	if err != nil {
+		return fmt.Errorf("unable to build slack webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST slack webhook payload: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode != 200 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from POSTing slack webhook payload: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to POST slack webhook payload: %s", bodyContent))
+	}
+	return nil
+}
+
+type slackWebhookPayload struct {
+	Text        string            `json:"text,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+	Fallback string `json:"fallback"`
+}
+
+var webhooktemplate = `monitoring:
+- saw tx %s
+- address %s
+- bundle %s
+`
+
+// slackTxColor returns the attachment color of the given tx alert.
+func slackTxColor(event *txEvent) string {
+	return slackColor(txSeverityInput(event), len(event.Suspicious) > 0)
+}
+
+// slackTxText renders the chat msg of the given tx alert.
+func slackTxText(event *txEvent) string {
+	txLink := explorerLink(*txExplorerURI, *txMirrorURI, event.Hash)
+	addrLink := explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address)
+	bundleLink := explorerLink(*bundleExplorerURI, *bundleMirrorURI, event.Bundle)
+	text := fmt.Sprintf(webhooktemplate, txLink, addrLink, bundleLink)
+	if event.QRCodeURL != "" {
+		if *formatExplorerLinks {
+			text += fmt.Sprintf("- <%s|QR code> of the address\n", event.QRCodeURL)
+		} else {
+			text += fmt.Sprintf("- QR code of the address %s\n", event.QRCodeURL)
+		}
+	}
+	if event.Value != 0 {
+		direction := "incoming"
+		if event.Value < 0 {
+			direction = "outgoing"
+		}
+		text += fmt.Sprintf("- %s value %s\n", direction, displayValue(event.Value, event.FiatValue))
+	}
+	if *decodeTags {
+		text += fmt.Sprintf("- tag %s\n", event.DecodedTag)
+	}
+	if event.DecodedObsoleteTag != "" {
+		text += fmt.Sprintf("- obsolete tag %s\n", event.DecodedObsoleteTag)
+	}
+	if len(event.TagMatch) > 0 {
+		text += fmt.Sprintf("- tag reference %s\n", formatTagMatch(event.TagMatch))
+	}
+	if event.PreviousDay != nil {
+		text += fmt.Sprintf("- %d further tx(s) on this address on %s\n", event.PreviousDay.SuppressedTxs, event.PreviousDay.Date)
+	}
+	if len(event.Suspicious) > 0 {
+		text += fmt.Sprintf("- warning: suspicious tx (%s)\n", strings.Join(event.Suspicious, ", "))
+	}
+	if event.Baseline > 0 {
+		text += fmt.Sprintf("- %.1fx the address's typical value of %.0f\n", math.Abs(float64(event.Value))/event.Baseline, event.Baseline)
+	}
+	if event.ConfirmedBy != 0 {
+		text += fmt.Sprintf("- confirmed by milestone %d\n", event.ConfirmedBy)
+	}
+	if event.Node != "" {
+		text += fmt.Sprintf("- node %s\n", event.Node)
+	}
+	return renderSlackText("tx", event, text)
+}
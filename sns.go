@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snsRegion returns the -snsRegion, defaulting to the region of the given topic ARN
+// ('arn:aws:sns:<region>:<account>:<topic>').
+func snsRegion(topicARN string) (string, error) {
+	if *snsRegionFlag != "" {
+		return *snsRegionFlag, nil
+	}
+	split := strings.Split(topicARN, ":")
+	if len(split) != 6 || split[0] != "arn" || split[2] != "sns" || split[3] == "" {
+		return "", errors.New("unable to determine the region of topic ARN '" + topicARN + "'")
+	}
+	return split[3], nil
+}
+
+// publishSNS publishes the given event as JSON msg to the -snsTopicARN.
+func publishSNS(ctx context.Context, payload interface{}) error {
+	msg, err := json.Marshal(labelEvent(payload))
+	if err != nil {
+		return fmt.Errorf("unable to serialize SNS msg: %w", err)
+	}
+	region, err := snsRegion(*snsTopicARN)
+	if err != nil {
+		return err
+	}
+	creds, err := resolveAWSCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := *snsEndpoint
+	if endpoint == "" {
+		endpoint = "https://sns." + region + ".amazonaws.com/"
+	}
+
+	form := url.Values{"Action": {"Publish"}, "Version": {"2010-03-31"}, "TopicArn": {*snsTopicARN}, "Message": {string(msg)}}
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(unqueued(ctx), http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to build SNS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signAWSRequest(req, body, creds, region, "sns", time.Now())
+
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to publish SNS msg: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from publishing SNS msg: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to publish SNS msg: %s", bodyContent))
+	}
+	return nil
+}
+
+// signAWSRequest signs the given request with the given body per AWS Signature Version 4.
+func signAWSRequest(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	if creds.sessionToken != "" {
+		headers["x-amz-security-token"] = creds.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	signedHeaders := strings.Join(names, ";")
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func snsNotification(send func(ctx context.Context) error) notification {
+	return notification{backend: "sns", timeout: snsTimeout, send: send}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// stdoutEncoder writes alerts as JSON lines to stdout, the logger writes to stderr.
+var stdoutEncoder = json.NewEncoder(os.Stdout)
+
+// writeStdoutPayload writes the given payload as a single JSON line to stdout.
+func writeStdoutPayload(payload interface{}) error {
+	if err := stdoutEncoder.Encode(payload); err != nil {
+		return fmt.Errorf("unable to write JSON line to stdout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// The modes of handling multiple nodes.
+const (
+	// a single stream using the next node whenever dialing the current one fails
+	nodeModeFailover = "failover"
+	// an independent stream per node, all feeding the pipeline
+	nodeModeFanIn = "fanin"
+)
+
+// stream is a subscription to the ZMQ stream of one of its nodes.
+type stream struct {
+	// the zmq4.Socket subscribed to the node, replaced by a fresh one when the connection went silent
+	sub atomic.Value
+	// builds the stream's sockets
+	newSocket func() zmq4.Socket
+	// the nodes to fail over between, in order
+	nodes []string
+	// the index of the current node, accessed atomically
+	current int32
+	// the label of the frames received by the stream, empty if unlabeled
+	label string
+
+	// serializes reconnects, of which there may be concurrent ones by the receiving and the probing
+	reconnectMu sync.Mutex
+	// incremented by every reconnect, accessed atomically
+	generation uint64
+	// the unix nanos of when the last frame was received, accessed atomically
+	lastFrame int64
+}
+
+// streamFrame is a frame received by a stream.
+type streamFrame struct {
+	frame []byte
+	// the label of the stream which received the frame
+	node string
+}
+
+func newStream(ctx context.Context, nodes []string, label string, dialTimeout time.Duration) *stream {
+	s := &stream{nodes: nodes, label: label}
+	s.newSocket = func() zmq4.Socket {
+		return zmq4.NewSub(ctx, zmq4.WithDialerTimeout(dialTimeout), zmq4.WithDialerRetry(1))
+	}
+	s.sub.Store(s.newSocket())
+	return s
+}
+
+// socket returns the stream's current socket.
+func (s *stream) socket() zmq4.Socket {
+	return s.sub.Load().(zmq4.Socket)
+}
+
+func (s *stream) node() string {
+	return s.nodes[atomic.LoadInt32(&s.current)]
+}
+
+// nodeList identifies the stream by its nodes.
+func (s *stream) nodeList() string {
+	return strings.Join(s.nodes, ",")
+}
+
+// failOver moves on to the next node, if there are multiple.
+func (s *stream) failOver() {
+	if len(s.nodes) == 1 {
+		return
+	}
+	atomic.StoreInt32(&s.current, (atomic.LoadInt32(&s.current)+1)%int32(len(s.nodes)))
+	logEvent(levelWarn, logFields{Event: "failover"}, "failing over to ZMQ socket %s", s.node())
+}
+
+// connect dials the node and subscribes to the topic, retrying up to the given number of times
+// with the given delay in between attempts.
+func (s *stream) connect(ctx context.Context, retries int, delay time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		err := s.dialAndSubscribe()
+		if err == nil {
+			return nil
+		}
+		if attempt > retries {
+			return err
+		}
+		warnf("%s...retrying in %v (attempt %d/%d)", err, delay, attempt, retries)
+		s.failOver()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		paceReconnect()
+	}
+}
+
+func (s *stream) dialAndSubscribe() error {
+	logEvent(levelInfo, logFields{Event: "connect"}, "dialing to ZMQ socket %s", s.node())
+	if err := s.socket().Dial(s.node()); err != nil {
+		return fmt.Errorf("can't dial ZMQ URI: %w", err)
+	}
+	notifyConnectionEvent(s.node(), connStateConnected)
+
+	infof("subscribing to '%s' topic(s)", strings.Join(subscribedTopics(), "', '"))
+	if err := s.subscribe(); err != nil {
+		return fmt.Errorf("subscription failed: %w", err)
+	}
+	notifyConnectionEvent(s.node(), connStateSubscribed)
+	return nil
+}
+
+// subscribedTopics returns the tx topic, the milestone topic, if any, and the confirmation topic if required.
+func subscribedTopics() []string {
+	topics := []string{*subTopic}
+	if *milestoneTopic != "" {
+		topics = append(topics, *milestoneTopic)
+	}
+	if *minConfirmations > 0 {
+		topics = append(topics, confirmationTopic)
+	}
+	return topics
+}
+
+func (s *stream) subscribe() error {
+	for _, topic := range subscribedTopics() {
+		if err := s.socket().SetOption(zmq4.OptionSubscribe, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconnectLimiter paces the reconnect attempts of all streams, so that they don't hit the node (or shared nodes)
+// all at once after a network blip, nil if unlimited.
+var reconnectLimiter *tokenBucket
+
+// paceReconnect blocks until the reconnectLimiter allows the next reconnect attempt, if any.
+func paceReconnect() {
+	if reconnectLimiter != nil {
+		// the reconnects aren't bound by a deadline, the wait can't fail
+		_ = reconnectLimiter.wait(context.Background())
+	}
+}
+
+// connRetryMaxInterval caps the doubling of the interval in between reconnect attempts, the interval stays
+// fixed if it's not above the initial interval.
+var connRetryMaxInterval time.Duration
+
+// nextRetryDelay returns the delay before the reconnect attempt following one after the given delay.
+func nextRetryDelay(delay time.Duration) time.Duration {
+	if delay >= connRetryMaxInterval {
+		return delay
+	}
+	if delay *= 2; delay > connRetryMaxInterval {
+		return connRetryMaxInterval
+	}
+	return delay
+}
+
+// reconnect reconnects to the node, unless another reconnect happened since the given generation was current. With
+// reset, the socket is replaced by a fresh one first, dropping its (silent) connection and unblocking its receive.
+func (s *stream) reconnect(generation uint64, connRetryInterval time.Duration, reset bool) {
+	s.reconnectMu.Lock()
+	defer s.reconnectMu.Unlock()
+	if atomic.LoadUint64(&s.generation) != generation {
+		return
+	}
+	defer atomic.AddUint64(&s.generation, 1)
+
+	if reset {
+		s.resetSocket()
+	}
+	notifyConnectionEvent(s.node(), connStateReconnecting)
+	recordOutage(s.nodeList(), true)
+	s.redial(connRetryInterval)
+	recordOutage(s.nodeList(), false)
+}
+
+// resetSocket replaces the socket by a fresh one, closing the old one along with its connections. The reconnectMu must
+// be held.
+func (s *stream) resetSocket() {
+	old := s.socket()
+	s.sub.Store(s.newSocket())
+	if err := old.Close(); err != nil {
+		errorf("could not close connection to %s: %s", s.node(), err)
+	}
+}
+
+// redial dials and subscribes to the nodes round-robin, starting with the current one, until one of them succeeded.
+// The interval (doubled up to the connRetryMaxInterval) is only waited after every node failed once, failing over
+// right away. The reconnectMu must be held.
+func (s *stream) redial(connRetryInterval time.Duration) {
+	delay := connRetryInterval
+	for ; ; delay = nextRetryDelay(delay) {
+		for range s.nodes {
+			paceReconnect()
+			node := s.node()
+			logEvent(levelInfo, logFields{Event: "reconnect"}, "trying to reconnect...")
+			if err := s.dialAndSubscribe(); err != nil {
+				logEvent(levelWarn, logFields{Event: "reconnect_failed"}, "reconnect attempt to %s failed: %s", node, err)
+				recordReconnectAttempt(node, false)
+				s.failOver()
+				continue
+			}
+			recordReconnectAttempt(node, true)
+			streamReconnects.Add(1)
+			logEvent(levelInfo, logFields{Event: "reconnect"}, "successfully reconnected to %s", node)
+			return
+		}
+		if len(s.nodes) > 1 {
+			logEvent(levelError, logFields{Event: "reconnect_failed"}, "could not reconnect to any of the %d nodes...retrying in %v", len(s.nodes), delay)
+		} else {
+			logEvent(levelError, logFields{Event: "reconnect_failed"}, "could not reconnect...retrying in %v", delay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// returnToPrimary moves the stream back to its first node whenever it's reachable again while the stream is failed
+// over to another node, checking every given interval until the given context is done.
+func (s *stream) returnToPrimary(ctx context.Context, interval time.Duration, connRetryInterval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if atomic.LoadInt32(&s.current) == 0 {
+			continue
+		}
+		probe := s.newSocket()
+		err := probe.Dial(s.nodes[0])
+		probe.Close()
+		if err != nil {
+			continue
+		}
+
+		s.reconnectMu.Lock()
+		// unless a reconnect moved on meanwhile
+		if atomic.LoadInt32(&s.current) != 0 {
+			logEvent(levelInfo, logFields{Event: "failover"}, "primary node %s is reachable again, moving back from %s", s.nodes[0], s.node())
+			s.resetSocket()
+			atomic.StoreInt32(&s.current, 0)
+			s.redial(connRetryInterval)
+			atomic.AddUint64(&s.generation, 1)
+		}
+		s.reconnectMu.Unlock()
+	}
+}
+
+// receive passes the received frames to the given channel, reconnecting whenever the node closes the connection,
+// until the given context is done.
+func (s *stream) receive(ctx context.Context, frames chan<- streamFrame, connRetryInterval time.Duration) {
+	for ctx.Err() == nil {
+		generation := atomic.LoadUint64(&s.generation)
+		msg, err := s.socket().Recv()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				// the socket was closed, on shutdown or to be replaced by a reset
+				continue
+			}
+			if !errors.Is(err, io.EOF) {
+				if ctx.Err() == nil {
+					logEvent(levelError, logFields{Event: "receive_error"}, "could not receive message: %v", err)
+				}
+				continue
+			}
+
+			logEvent(levelWarn, logFields{Event: "connection_closed"}, "the remote server %s closed the connection", s.node())
+			notifyConnectionEvent(s.node(), connStateDisconnected)
+			s.reconnect(generation, connRetryInterval, false)
+			continue
+		}
+		atomic.StoreInt64(&s.lastFrame, time.Now().UnixNano())
+		select {
+		case frames <- streamFrame{frame: msg.Bytes(), node: s.label}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// probeWhenIdle probes the connection whenever no frame was received within the given interval, until the given
+// context is done. As zmq4 doesn't support heartbeats, the probe re-sends the subscription, which fails on a
+// connection silently dropped by the network, in which case the stream reconnects proactively.
+func (s *stream) probeWhenIdle(ctx context.Context, interval time.Duration, connRetryInterval time.Duration) {
+	atomic.StoreInt64(&s.lastFrame, time.Now().UnixNano())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, atomic.LoadInt64(&s.lastFrame))) < interval {
+			continue
+		}
+		generation := atomic.LoadUint64(&s.generation)
+		if err := s.subscribe(); err != nil {
+			errorf("idle connection probe of %s failed: %s", s.node(), err)
+			notifyConnectionEvent(s.node(), connStateDisconnected)
+			s.reconnect(generation, connRetryInterval, false)
+		}
+	}
+}
+
+// reconnectWhenSilent forces a reconnect whenever no msg was received within the given max. idle duration, until the
+// given context is done. A node may keep the connection open while it stopped publishing (e.g. as its ZMQ publisher
+// stalled), in which case the receive blocks forever without the connection ever failing or a probe noticing.
+func (s *stream) reconnectWhenSilent(ctx context.Context, maxIdle time.Duration, connRetryInterval time.Duration) {
+	atomic.StoreInt64(&s.lastFrame, time.Now().UnixNano())
+	interval := maxIdle / 4
+	if interval > 15*time.Second {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&s.lastFrame)))
+		if idle < maxIdle {
+			continue
+		}
+		generation := atomic.LoadUint64(&s.generation)
+		logEvent(levelWarn, logFields{Event: "forced_reconnect"}, "no msg received from %s for %v (above -maxIdle %v), forcing a reconnect", s.node(), idle.Truncate(time.Second), maxIdle)
+		forcedReconnects.Add(1)
+		notifyConnectionEvent(s.node(), connStateDisconnected)
+		s.reconnect(generation, connRetryInterval, true)
+		// the reconnected stream gets the full max. idle duration to deliver
+		atomic.StoreInt64(&s.lastFrame, time.Now().UnixNano())
+	}
+}
+
+func (s *stream) Close() error {
+	return s.socket().Close()
+}
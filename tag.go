@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/iotaledger/iota.go/encoding/ascii"
+)
+
+// decodeTag decodes the given tag trytes into ASCII, returning false if they don't decode to printable ASCII.
+func decodeTag(tag string) (string, bool) {
+	tag = strings.TrimRight(tag, "9")
+	if tag == "" {
+		return "", false
+	}
+	if len(tag)%2 != 0 {
+		tag += "9"
+	}
+	decoded, err := ascii.DecodeTrytes(tag)
+	if err != nil {
+		return "", false
+	}
+	for i := 0; i < len(decoded); i++ {
+		if decoded[i] < 0x20 || decoded[i] > 0x7e {
+			return "", false
+		}
+	}
+	return decoded, true
+}
+
+// displayTag returns the decoded tag if it decodes to printable ASCII, otherwise the raw tag trytes.
+func displayTag(tag string) string {
+	if decoded, ok := decodeTag(tag); ok {
+		return decoded
+	}
+	return tag
+}
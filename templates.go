@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// templateKinds are the kinds of events rendered into Slack msgs, matching the event names of their generic webhook
+// payloads, with an empty payload of each kind to check templates against. The default template has no payload
+// as it may be executed with any of them.
+var templateKinds = map[string]interface{}{
+	"default":                nil,
+	"tx":                     &txEvent{Transaction: &transaction.Transaction{}},
+	"bundle":                 &bundleSummary{},
+	"spend":                  &spendSummary{},
+	"firstActivity":          &firstActivityEvent{},
+	"connection":             &connectionEvent{},
+	"connection_instability": &instabilityEvent{},
+	"downtime":               &downtimeEvent{},
+	"maintenance":            &maintenanceEvent{},
+	"no_match":               &noMatchEvent{},
+	"match_ratio":            &matchRatioEvent{},
+	"milestone_stalled":      &milestoneStalledEvent{},
+	"deferred_digest":        &deferredDigestEvent{},
+	"addrs_changed":          &addrsChangedEvent{},
+	"addrs_shrink_refused":   &addrsShrinkRefusedEvent{},
+	"conflicting_spend":      &conflictEvent{},
+	"address_reuse":          &addrReuseEvent{},
+	"top_addresses":          &topAddressesEvent{},
+	"zero_value":             &zeroValueEvent{},
+	"tx_confirmation":        &txConfirmationEvent{},
+}
+
+// slackTemplates are the user defined Slack msg templates by event kind, the 'default' template is used
+// for kinds without their own template. Kinds without any template use the built-in msgs.
+var slackTemplates map[string]*template.Template
+
+var templateFuncs = template.FuncMap{
+	"txLink": func(hash string) string {
+		return explorerLink(*txExplorerURI, *txMirrorURI, hash)
+	},
+	"bundleLink": func(hash string) string {
+		return explorerLink(*bundleExplorerURI, *bundleMirrorURI, hash)
+	},
+	"addrLink": func(addr string) string {
+		return explorerLink(*addrExplorerURI, *addrMirrorURI, addr)
+	},
+	"txURL": func(hash string) string {
+		return withLinkSuffix(explorerURL(*txExplorerURI, hash))
+	},
+	"bundleURL": func(hash string) string {
+		return withLinkSuffix(explorerURL(*bundleExplorerURI, hash))
+	},
+	"addrURL": func(addr string) string {
+		return withLinkSuffix(explorerURL(*addrExplorerURI, addr))
+	},
+	"join": strings.Join,
+	"json": func(value interface{}) (string, error) {
+		content, err := json.Marshal(value)
+		return string(content), err
+	},
+	"instance": func() string {
+		return instanceLabel
+	},
+}
+
+// webhookTemplates are the user defined generic webhook body templates by event kind, like slackTemplates.
+// Kinds without any template keep their built-in payload.
+var webhookTemplates map[string]*template.Template
+
+// loadSlackTemplates reads the JSON object of Slack msg templates (text/template syntax) by event kind
+// from the given file. The templates are executed with the event's generic webhook payload. Templates keyed by
+// '<kind>@<name>' are named variants, used for the alerts of the groups and addresses referencing the name.
+func loadSlackTemplates(path string) (map[string]*template.Template, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read templates file: %w", err)
+	}
+	var texts map[string]string
+	if err := json.Unmarshal(content, &texts); err != nil {
+		return nil, fmt.Errorf("unable to parse templates file: %w", err)
+	}
+	templates := make(map[string]*template.Template, len(texts))
+	for kind, text := range texts {
+		eventKind := kind
+		if i := strings.IndexByte(kind, '@'); i >= 0 {
+			if eventKind = kind[:i]; kind[i+1:] == "" {
+				return nil, fmt.Errorf("template '%s' has an empty name", kind)
+			}
+		}
+		sample, known := templateKinds[eventKind]
+		if !known {
+			return nil, fmt.Errorf("unknown event kind '%s'", eventKind)
+		}
+		tmpl, err := template.New(kind).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template of event kind '%s': %w", kind, err)
+		}
+		if sample != nil {
+			if err := tmpl.Execute(ioutil.Discard, sample); err != nil {
+				return nil, fmt.Errorf("invalid template of event kind '%s': %w", kind, err)
+			}
+		}
+		templates[kind] = tmpl
+	}
+	return templates, nil
+}
+
+// loadWebhookTemplates reads the JSON object of generic webhook body templates (text/template syntax) by event kind
+// from the given file, checking that they render valid JSON.
+func loadWebhookTemplates(path string) (map[string]*template.Template, error) {
+	templates, err := loadSlackTemplates(path)
+	if err != nil {
+		return nil, err
+	}
+	for kind, tmpl := range templates {
+		eventKind := strings.SplitN(kind, "@", 2)[0]
+		samples := []interface{}{templateKinds[eventKind]}
+		if eventKind == "default" {
+			samples = samples[:0]
+			for _, sample := range templateKinds {
+				if sample != nil {
+					samples = append(samples, sample)
+				}
+			}
+		}
+		for _, sample := range samples {
+			var body bytes.Buffer
+			if err := tmpl.Execute(&body, sample); err != nil {
+				return nil, fmt.Errorf("invalid template of event kind '%s': %w", kind, err)
+			}
+			if !json.Valid(body.Bytes()) {
+				return nil, fmt.Errorf("template of event kind '%s' doesn't render valid JSON: %s", kind, body.String())
+			}
+		}
+	}
+	return templates, nil
+}
+
+// renderWebhookBody renders the given webhook payload with the user defined template of its kind, returning nil
+// if there is no template or rendering fails, in which case the built-in payload is to be sent.
+func renderWebhookBody(payload interface{}) []byte {
+	kind := eventKind(payload)
+	tmpl := lookupTemplate(webhookTemplates, kind, templateName(payload))
+	if tmpl == nil {
+		return nil
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, payload); err != nil {
+		errorf("could not render %s webhook template, using the built-in payload: %s", kind, err)
+		return nil
+	}
+	if !json.Valid(body.Bytes()) {
+		errorf("could not render %s webhook template, using the built-in payload: invalid JSON", kind)
+		return nil
+	}
+	return body.Bytes()
+}
+
+// renderSlackText renders the given event of the given kind with its user defined template,
+// falling back to the given built-in msg if there is no template or rendering fails.
+func renderSlackText(kind string, event interface{}, builtin string) string {
+	tmpl := lookupTemplate(slackTemplates, kind, templateName(event))
+	if tmpl == nil {
+		return builtin
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, event); err != nil {
+		errorf("could not render %s template, using the built-in msg: %s", kind, err)
+		return builtin
+	}
+	return text.String()
+}
+
+// templateRefs are the names of the templates referenced by the watch groups (and their addresses) by group name.
+var templateRefs = make(map[string]*groupTemplateRefs)
+
+type groupTemplateRefs struct {
+	group string
+	// by normalized address
+	addrs map[string]string
+}
+
+// registerTemplateRefs records the names of the templates referenced by the given validated groups.
+func registerTemplateRefs(groups []*watchGroup) {
+	for _, g := range groups {
+		refs := &groupTemplateRefs{group: g.Template, addrs: make(map[string]string, len(g.AddrTemplates))}
+		for addr, name := range g.AddrTemplates {
+			if normalized, err := normalizeAddr(addr); err == nil {
+				refs.addrs[normalized] = name
+			}
+		}
+		templateRefs[g.Name] = refs
+	}
+}
+
+// templateName returns the name of the templates referenced by the address of the given event, falling back to the
+// one referenced by its group, empty if none is referenced. Bundle and spend alerts use their group's templates.
+func templateName(event interface{}) string {
+	var group, addr string
+	switch event := event.(type) {
+	case *txEvent:
+		group, addr = event.Group, event.Address
+	case *bundleSummary:
+		group = event.Group
+	case *spendSummary:
+		group = event.Group
+	case *firstActivityEvent:
+		group, addr = event.Group, event.Address
+	case *conflictEvent:
+		group, addr = event.Group, event.Address
+	case *addrReuseEvent:
+		group, addr = event.Group, event.Address
+	case *zeroValueEvent:
+		group, addr = event.Group, event.Address
+	default:
+		return ""
+	}
+	refs, has := templateRefs[group]
+	if !has {
+		return ""
+	}
+	if name, has := refs.addrs[addr]; has {
+		return name
+	}
+	return refs.group
+}
+
+// lookupTemplate returns the template of the given kind from the given templates, preferring the variants of the
+// given name (if any) over the unnamed templates and the kind's templates over the 'default' ones, nil if none fits.
+func lookupTemplate(templates map[string]*template.Template, kind string, name string) *template.Template {
+	keys := []string{kind, "default"}
+	if name != "" {
+		keys = []string{kind + "@" + name, "default@" + name, kind, "default"}
+	}
+	for _, key := range keys {
+		if tmpl, has := templates[key]; has {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// hasNamedTemplates reports whether any Slack msg or webhook body template has the given name.
+func hasNamedTemplates(name string) bool {
+	for _, templates := range []map[string]*template.Template{slackTemplates, webhookTemplates} {
+		for key := range templates {
+			if strings.HasSuffix(key, "@"+name) {
+				return true
+			}
+		}
+	}
+	return false
+}
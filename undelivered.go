@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// undeliveredLog is the last line of defense against losing events: events which none of their notification backends
+// accepted are appended to a local file, one JSON object per line, and optionally redelivered once the backends
+// accept notifications again.
+type undeliveredLog struct {
+	path string
+	// what the events are, e.g. 'undelivered', as logged
+	label  string
+	groups map[string]*watchGroup
+	// whether to redeliver the events once the backends recovered
+	redeliver bool
+
+	mu sync.Mutex
+	// the number of events in the file which can be redelivered
+	pending int
+	// set while redelivering, accessed atomically
+	redelivering int32
+}
+
+// undeliveredEntry is an event in the undelivered file.
+type undeliveredEntry struct {
+	Time time.Time `json:"time"`
+	// the kind of the event, matching the template kinds
+	Kind     string          `json:"kind"`
+	Backends []string        `json:"backends"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// undelivered is the log of undelivered events, nil if disabled.
+var undelivered *undeliveredLog
+
+func openUndeliveredLog(path string, label string, groups []*watchGroup, redeliver bool) (*undeliveredLog, error) {
+	l := &undeliveredLog{path: path, label: label, groups: make(map[string]*watchGroup, len(groups)), redeliver: redeliver}
+	for _, group := range groups {
+		l.groups[group.Name] = group
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read %s events: %w", label, err)
+	}
+	l.pending = bytes.Count(content, []byte{'\n'})
+	if l.pending > 0 {
+		warnf("warning: %d %s event(s) in %s", l.pending, label, path)
+	}
+	return l, nil
+}
+
+// record records the given event which none of the given backends accepted.
+func (l *undeliveredLog) record(payload interface{}, backends []string) {
+	undeliveredEvents.Add(1)
+	if l == nil {
+		errorf("error: none of the notification backends (%v) accepted the event, dropping it", backends)
+		return
+	}
+	if err := l.append(payload, backends); err != nil {
+		errorf("error: could not record undelivered event: %s", err)
+		return
+	}
+	errorf("error: none of the notification backends (%v) accepted the event, recorded it in %s", backends, l.path)
+}
+
+// append appends the given event for the given backends to the file.
+func (l *undeliveredLog) append(payload interface{}, backends []string) error {
+	event, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	kind := eventKind(payload)
+	line, err := json.Marshal(&undeliveredEntry{Time: time.Now(), Kind: kind, Backends: backends, Event: event})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if redeliverableKinds[kind] {
+		l.pending++
+	}
+	return nil
+}
+
+// redeliverableKinds are the kinds of the events redelivered to the targets of their group.
+var redeliverableKinds = map[string]bool{"tx": true, "bundle": true, "spend": true, "firstActivity": true, "conflicting_spend": true, "address_reuse": true, "zero_value": true}
+
+// eventKind returns the kind of the given event payload.
+func eventKind(payload interface{}) string {
+	switch event := payload.(type) {
+	case *txEvent:
+		return "tx"
+	case *bundleSummary:
+		return event.Event
+	case *spendSummary:
+		return event.Event
+	case *firstActivityEvent:
+		return event.Event
+	case *conflictEvent:
+		return event.Event
+	case *addrReuseEvent:
+		return event.Event
+	case *zeroValueEvent:
+		return event.Event
+	}
+	var event struct {
+		Event string `json:"event"`
+	}
+	if content, err := json.Marshal(payload); err == nil {
+		json.Unmarshal(content, &event)
+	}
+	return event.Event
+}
+
+// recovered is called after the backends accepted an event, redelivering the undelivered events in the background.
+func (l *undeliveredLog) recovered() {
+	if l == nil || !l.redeliver {
+		return
+	}
+	l.mu.Lock()
+	pending := l.pending > 0
+	l.mu.Unlock()
+	if !pending || !atomic.CompareAndSwapInt32(&l.redelivering, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&l.redelivering, 0)
+		l.redeliverAll()
+	}()
+}
+
+// redeliverAll takes the events out of the file and sends them to the targets of their groups again. Events failing
+// again are recorded anew, operator events which aren't bound to a group are only kept in the file.
+func (l *undeliveredLog) redeliverAll() {
+	l.mu.Lock()
+	content, err := ioutil.ReadFile(l.path)
+	if err == nil {
+		err = os.Truncate(l.path, 0)
+	}
+	if err != nil {
+		l.mu.Unlock()
+		errorf("could not redeliver %s events: %s", l.label, err)
+		return
+	}
+	l.pending = 0
+	l.mu.Unlock()
+
+	var kept [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	resent := 0
+	for scanner.Scan() {
+		var entry undeliveredEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			errorf("could not parse %s event: %s", l.label, err)
+		}
+		if entry.Kind == "" || !l.redeliverEntry(&entry) {
+			kept = append(kept, append([]byte(nil), scanner.Bytes()...))
+			continue
+		}
+		resent++
+	}
+	if resent > 0 {
+		infof("resent %d %s event(s), the ones failing again are recorded anew", resent, l.label)
+	}
+	if len(kept) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		errorf("error: could not keep %s events: %s", l.label, err)
+		return
+	}
+	defer f.Close()
+	for _, line := range kept {
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			errorf("error: could not keep %s events: %s", l.label, err)
+			return
+		}
+	}
+}
+
+// redeliverEntry sends the given event to the targets of its group, reporting false if it can't be redelivered.
+func (l *undeliveredLog) redeliverEntry(entry *undeliveredEntry) bool {
+	var group struct {
+		Group string `json:"group"`
+	}
+	json.Unmarshal(entry.Event, &group)
+	g, has := l.groups[group.Group]
+	if !has || !redeliverableKinds[entry.Kind] {
+		return false
+	}
+	infof("redelivering %s event of %s (group %s)", entry.Kind, entry.Time.Format(time.RFC3339), g.Name)
+	var err error
+	switch entry.Kind {
+	case "tx":
+		event := &txEvent{Transaction: &transaction.Transaction{}}
+		if err = json.Unmarshal(entry.Event, event); err == nil {
+			g.notifyTx(event)
+		}
+	case "bundle":
+		summary := &bundleSummary{}
+		if err = json.Unmarshal(entry.Event, summary); err == nil {
+			g.notifyBundle(summary)
+		}
+	case "spend":
+		summary := &spendSummary{}
+		if err = json.Unmarshal(entry.Event, summary); err == nil {
+			g.notifySpend(summary)
+		}
+	case "firstActivity":
+		event := &firstActivityEvent{}
+		if err = json.Unmarshal(entry.Event, event); err == nil {
+			g.notifyFirstActivity(event)
+		}
+	case "conflicting_spend":
+		event := &conflictEvent{}
+		if err = json.Unmarshal(entry.Event, event); err == nil {
+			g.notifyConflict(event)
+		}
+	case "address_reuse":
+		event := &addrReuseEvent{}
+		if err = json.Unmarshal(entry.Event, event); err == nil {
+			g.notifyAddrReuse(event)
+		}
+	case "zero_value":
+		event := &zeroValueEvent{}
+		if err = json.Unmarshal(entry.Event, event); err == nil {
+			g.notifyZeroValue(event)
+		}
+	default:
+		return false
+	}
+	if err != nil {
+		errorf("could not parse %s %s event: %s", l.label, entry.Kind, err)
+		return false
+	}
+	return true
+}
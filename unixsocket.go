@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// unixSocketWriter writes alerts as JSON lines to the Unix domain socket a co-located consumer (e.g. a sidecar)
+// listens on, redialing the socket whenever the consumer restarted.
+type unixSocketWriter struct {
+	path    string
+	timeout time.Duration
+	mu      sync.Mutex
+	conn    net.Conn
+}
+
+// unixSocketSink is the writer of alerts to the Unix domain socket, nil if disabled.
+var unixSocketSink *unixSocketWriter
+
+func newUnixSocketWriter(path string, timeout time.Duration) *unixSocketWriter {
+	return &unixSocketWriter{path: path, timeout: timeout}
+}
+
+// write writes the given payload as a single JSON line to the socket, dialing it if not connected and redialing
+// it once if the write fails on a connection the consumer closed.
+func (w *unixSocketWriter) write(payload interface{}) error {
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialize unix socket payload: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	redialed := w.conn == nil
+	for {
+		if w.conn == nil {
+			conn, err := net.DialTimeout("unix", w.path, w.timeout)
+			if err != nil {
+				return fmt.Errorf("unable to dial unix socket: %w", err)
+			}
+			w.conn = conn
+		}
+		err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		if err == nil {
+			_, err = w.conn.Write(line)
+		}
+		if err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if redialed {
+			return fmt.Errorf("unable to write JSON line to unix socket: %w", err)
+		}
+		redialed = true
+	}
+}
+
+// Close closes the connection to the socket, if any.
+func (w *unixSocketWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+const (
+	suspiciousTxsSkip = "skip"
+	suspiciousTxsFlag = "flag"
+
+	multiMatchAll   = "all"
+	multiMatchFirst = "first"
+
+	// the max. amount a tx's timestamp may lie in the future before it is considered junk
+	maxTimestampDrift = 2 * time.Hour
+)
+
+// iotaGenesis precedes any legit attachment timestamp, earlier ones (e.g. set in seconds instead of millis) are junk.
+var iotaGenesis = time.Date(2016, time.July, 11, 0, 0, 0, 0, time.UTC)
+
+// txAnomalies checks the key fields of the given parsed tx for values which can't be legit
+// and returns a description for each anomaly found.
+func txAnomalies(tx *transaction.Transaction, now time.Time) []string {
+	var anomalies []string
+
+	if tx.Value > int64(consts.TotalSupply) || tx.Value < -int64(consts.TotalSupply) {
+		anomalies = append(anomalies, "value exceeds the total supply")
+	}
+
+	if tx.Timestamp == 0 && tx.AttachmentTimestamp == 0 {
+		anomalies = append(anomalies, "timestamp and attachment timestamp are both zero")
+	}
+
+	if time.Unix(int64(tx.Timestamp), 0).After(now.Add(maxTimestampDrift)) {
+		anomalies = append(anomalies, "timestamp lies in the future")
+	}
+
+	if tx.AttachmentTimestampUpperBound != 0 &&
+		(tx.AttachmentTimestamp < tx.AttachmentTimestampLowerBound || tx.AttachmentTimestamp > tx.AttachmentTimestampUpperBound) {
+		anomalies = append(anomalies, "attachment timestamp is outside of its bounds")
+	}
+
+	return anomalies
+}
+
+// attachmentAge returns the age of the given tx by its attachment timestamp at the given time. Reports false if the
+// age is unknown as the attachment timestamp is zero (e.g. in the JSON frame format) or clearly invalid, i.e. before
+// the IOTA genesis or beyond the max. drift in the future.
+func attachmentAge(tx *transaction.Transaction, now time.Time) (time.Duration, bool) {
+	if tx.AttachmentTimestamp <= 0 {
+		return 0, false
+	}
+	attached := time.Unix(0, tx.AttachmentTimestamp*int64(time.Millisecond))
+	if attached.Before(iotaGenesis) || attached.After(now.Add(maxTimestampDrift)) {
+		return 0, false
+	}
+	return now.Sub(attached), true
+}
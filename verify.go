@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// frameVerifier verifies the authenticity of the frames received from the node before they're trusted.
+type frameVerifier interface {
+	// verify verifies the given frame and returns it without its signature.
+	verify(frame string) (string, error)
+}
+
+// errUnverifiedFrame is returned by frame verifiers for frames whose signature is missing or invalid.
+var errUnverifiedFrame = errors.New("unverified frame")
+
+// ed25519FrameVerifier verifies frames signed by the node with an Ed25519 key, carrying the base64 encoded signature
+// of the rest of the frame as their last token, e.g. 'trytes <trytes> <hash> <signature>'.
+type ed25519FrameVerifier struct {
+	key ed25519.PublicKey
+}
+
+// parseNodePublicKey parses the given hex or base64 encoded Ed25519 public key.
+func parseNodePublicKey(str string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(str); err != nil {
+			return nil, errors.New("must be hex or base64 encoded")
+		}
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("must be an Ed25519 public key of %d bytes but got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (v *ed25519FrameVerifier) verify(frame string) (string, error) {
+	sep := strings.LastIndexByte(frame, ' ')
+	if sep < 0 {
+		return "", fmt.Errorf("%w: missing signature", errUnverifiedFrame)
+	}
+	signature, err := base64.StdEncoding.DecodeString(frame[sep+1:])
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return "", fmt.Errorf("%w: malformed or missing signature", errUnverifiedFrame)
+	}
+	if !ed25519.Verify(v.key, []byte(frame[:sep]), signature) {
+		return "", fmt.Errorf("%w: invalid signature", errUnverifiedFrame)
+	}
+	return frame[:sep], nil
+}
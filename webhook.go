@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// notificationClient is the HTTP client shared by all notifications, reusing connections across them.
+var notificationClient = http.DefaultClient
+
+// newNotificationClient builds an HTTP client keeping up to the given number of idle connections
+// per host alive for the given duration and attempting HTTP/2. If the given max. number of in-flight
+// requests per host is positive, further requests to the host wait until one of them completed. A nil TLS config
+// uses the default one. The requests are persisted to the given queue until they're delivered, unless nil.
+func newNotificationClient(maxIdleConns int, maxInFlightPerHost int, idleConnTimeout time.Duration, timeout time.Duration, tlsConfig *tls.Config, queue *notificationQueue) *http.Client {
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConns,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		TLSClientConfig:       tlsConfig,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if maxInFlightPerHost > 0 {
+		transport = &hostConcurrencyLimiter{next: transport, limit: maxInFlightPerHost, hosts: make(map[string]chan struct{})}
+	}
+	if queue != nil {
+		transport = &queueingTransport{next: transport, queue: queue}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// hostConcurrencyLimiter bounds the number of in-flight requests per destination host, so that a slow receiver
+// shared by many targets (e.g. per address webhooks) is neither overwhelmed nor holds up the sends to other hosts.
+// A request is in flight until its response body is closed.
+type hostConcurrencyLimiter struct {
+	next  http.RoundTripper
+	limit int
+
+	mu sync.Mutex
+	// a semaphore per host
+	hosts map[string]chan struct{}
+}
+
+func (l *hostConcurrencyLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	sem, has := l.hosts[req.URL.Host]
+	if !has {
+		sem = make(chan struct{}, l.limit)
+		l.hosts[req.URL.Host] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+	default:
+		hostConcurrencyWaits.Add(1)
+		select {
+		case sem <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	release := func() { <-sem }
+	res, err := l.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// releasingBody releases the concurrency slot of its request once closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// GenericWebhookNotifier POSTs the alerts' events as JSON to a generic webhook, the tx alerts as the JSON of the tx
+// with the alert's details.
+type GenericWebhookNotifier struct {
+	URI string
+}
+
+// Notify POSTs the given tx alert's event.
+func (n *GenericWebhookNotifier) Notify(ctx context.Context, tx *transaction.Transaction) error {
+	return sendWebhookPayload(ctx, n.URI, txEventOf(ctx, tx))
+}
+
+// notifyEvent POSTs the given payload, the chat msg is left to the chat platforms.
+func (n *GenericWebhookNotifier) notifyEvent(ctx context.Context, _ string, _ string, payload interface{}) error {
+	return sendWebhookPayload(ctx, n.URI, payload)
+}
+
+// sendWebhookPayload POSTs the given payload as JSON to the given generic webhook URI.
+// If gzip compression is enabled, the body is compressed and the Content-Encoding header set accordingly.
+func sendWebhookPayload(ctx context.Context, uri string, payload interface{}) error {
+	jsonWebHookPayload := renderWebhookBody(payload)
+	if jsonWebHookPayload == nil {
+		var err error
+		if jsonWebHookPayload, err = json.Marshal(labelEvent(payload)); err != nil {
+			return fmt.Errorf("unable to serialize webhook payload: %w", err)
+		}
+	}
+
+	body := jsonWebHookPayload
+	if *webhookGzip {
+		var buf bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buf)
+		if _, err := gzipWriter.Write(jsonWebHookPayload); err != nil {
+			return fmt.Errorf("unable to gzip webhook payload: %w", err)
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("unable to gzip webhook payload: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if *webhookGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	res, err := notificationClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to POST webhook payload: %w", err)
+	}
+	defer closeResponse(res)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		bodyContent, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to extract error from response content from POSTing webhook payload: %w", err)
+		}
+		return responseError(res, fmt.Errorf("unable to POST webhook payload: %s", bodyContent))
+	}
+	return nil
+}
+
+// closeResponse drains and closes the given response's body so that its connection can be reused.
+func closeResponse(res *http.Response) {
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
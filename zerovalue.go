@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+// zeroValueEvent is the generic webhook payload of an alert for a zero-value tx on a monitored address of a group
+// with zero-value alerts, e.g. a data msg or the marker of a dust/spam attack.
+type zeroValueEvent struct {
+	Event      string `json:"event"`
+	Group      string `json:"group"`
+	Address    string `json:"address"`
+	Tx         string `json:"tx"`
+	Bundle     string `json:"bundle"`
+	Tag        string `json:"tag"`
+	DecodedTag string `json:"decodedTag,omitempty"`
+	// the tx's signature message fragment decoded as ASCII, empty if it doesn't decode to printable ASCII
+	Message string    `json:"message,omitempty"`
+	Node    string    `json:"node,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var zeroValueTemplate = `monitoring: zero-value tx on monitored address %s
+- tx %s in bundle %s
+- tag %s
+%s`
+
+func newZeroValueEvent(group *watchGroup, tx *transaction.Transaction) *zeroValueEvent {
+	event := &zeroValueEvent{
+		Event: "zero_value", Group: group.Name, Address: tx.Address,
+		Tx: tx.Hash, Bundle: tx.Bundle, Tag: tx.Tag, Time: alertTime(),
+	}
+	if decoded, ok := decodeTag(tx.Tag); ok {
+		event.DecodedTag = decoded
+	}
+	// msgs are encoded into the fragment just like tags
+	if message, ok := decodeTag(tx.SignatureMessageFragment); ok {
+		event.Message = message
+	}
+	return event
+}
+
+func zeroValueSeverityInput(event *zeroValueEvent) *severityInput {
+	return &severityInput{
+		group: event.Group, direction: "in",
+		tx: event.Tx, bundle: event.Bundle, address: event.Address,
+	}
+}
+
+// notifyZeroValue sends the zero-value tx alert to the group's notification targets.
+func (g *watchGroup) notifyZeroValue(event *zeroValueEvent) {
+	var notifications []notification
+	if g.notifier != nil {
+		tag := event.Tag
+		if event.DecodedTag != "" {
+			tag = event.DecodedTag
+		}
+		message := ""
+		if event.Message != "" {
+			message = fmt.Sprintf("- msg: %s\n", event.Message)
+		}
+		text := renderSlackText(event.Event, event, fmt.Sprintf(zeroValueTemplate, explorerLink(*addrExplorerURI, *addrMirrorURI, event.Address),
+			explorerLink(*txExplorerURI, *txMirrorURI, event.Tx), explorerLink(*bundleExplorerURI, *bundleMirrorURI, event.Bundle), tag, message))
+		color := slackColor(zeroValueSeverityInput(event), false)
+		notifications = append(notifications, slackNotification(func(ctx context.Context) error {
+			return g.notifier.notifyEvent(ctx, text, color, event)
+		}))
+	}
+	if g.WebhookURI != "" {
+		notifications = append(notifications, webhookNotification(func(ctx context.Context) error {
+			return sendWebhookPayload(ctx, g.WebhookURI, event)
+		}))
+	}
+	if *pagerDutyRoutingKey != "" {
+		notifications = append(notifications, pagerDutyNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen zero-value tx %s on monitored address %s (group %s)", event.Tx, event.Address, g.Name)
+			return sendPagerDutyEvent(ctx, text, zeroValueSeverityInput(event), event)
+		}))
+	}
+	if *opsgenieAPIKey != "" {
+		notifications = append(notifications, opsgenieNotification(func(ctx context.Context) error {
+			text := fmt.Sprintf("seen zero-value tx %s on monitored address %s (group %s)", event.Tx, event.Address, g.Name)
+			return sendOpsgenieAlert(ctx, text, zeroValueSeverityInput(event), event)
+		}))
+	}
+	if *snsTopicARN != "" {
+		notifications = append(notifications, snsNotification(func(ctx context.Context) error {
+			return publishSNS(ctx, event)
+		}))
+	}
+	fanOut(event, notifications)
+	writeEventSinks(event.Address, event)
+}